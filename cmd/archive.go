@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultArchiveFormat names the directories of --archive-by-date by day
+const defaultArchiveFormat = "YYYY-MM-DD"
+
+// archiveLayout converts the YYYY-MM-DD style of --archive-format to the
+// layout of the time package, the Go layouts such as 2006-01-02 work as is
+var archiveLayout = strings.NewReplacer(
+	"YYYY", "2006",
+	"MM", "01",
+	"DD", "02",
+	"HH", "15",
+	"mm", "04",
+	"ss", "05",
+)
+
+// archivePath inserts the dated directory into the output path of a download,
+// e.g. out/2024-06-01 for out and out/2024-06-01/doc.md for out/doc.md
+func archivePath(output, format string, now time.Time) (string, error) {
+	if format == "" {
+		format = defaultArchiveFormat
+	}
+	name := now.Format(archiveLayout.Replace(format))
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid --archive-format: %s", format)
+	}
+	if strings.EqualFold(filepath.Ext(output), ".md") {
+		return filepath.Join(filepath.Dir(output), name, filepath.Base(output)), nil
+	}
+	return filepath.Join(output, name), nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/Wsine/feishu2md/utils"
+	"github.com/chyroc/lark"
+	"github.com/urfave/cli/v2"
+)
+
+// handleAssetsCommand downloads only the images and the attachments of the
+// documents, the folders and the wikis are traversed as by the download command
+func handleAssetsCommand(urls []string) error {
+	dlOpts.assetsOnly = true
+	dlOpts.format = formatMarkdown
+	return handleDownloadCommand(urls)
+}
+
+// downloadAssets saves the images and the attachments of a docx into a
+// directory named after the document
+func downloadAssets(ctx context.Context, client *core.Client, docx *lark.DocxDocument, blocks []*lark.DocxBlock, opts *DownloadOpts) error {
+	assets := core.DocumentAssets(blocks)
+	if len(assets) == 0 {
+		fmt.Printf("No asset in %s\n", docx.Title)
+		dlReport.export()
+		return nil
+	}
+	dir := filepath.Join(opts.outputDir, opts.namePrefix+utils.SanitizeFileName(docx.Title))
+	files, err := client.DownloadAssets(ctx, assets, dir)
+	for i, filePath := range files {
+		recordManifest(filePath, core.ManifestEntry{ObjToken: assets[i].Token, ObjType: assets[i].Type, Title: docx.Title})
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Downloaded %d asset(s) of %s to %s\n", len(files), docx.Title, dir)
+	dlReport.export()
+	return nil
+}
+
+// assetsCommand downloads only the images and the attachments of the documents
+func assetsCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "assets",
+		Usage:     "Download only the images and the attachments of the documents, keeping their original file names",
+		ArgsUsage: "<url>...",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Value:       "./",
+				Usage:       "Specify the output directory, the assets of a document are saved into a directory named after it",
+				Destination: &dlOpts.outputDir,
+			},
+			&cli.BoolFlag{
+				Name:        "batch",
+				Value:       false,
+				Usage:       "Download the assets of all documents under a folder",
+				Destination: &dlOpts.batch,
+			},
+			&cli.BoolFlag{
+				Name:        "wiki",
+				Value:       false,
+				Usage:       "Download the assets of all documents within the wiki",
+				Destination: &dlOpts.wiki,
+			},
+			&cli.IntFlag{
+				Name:        "concurrency",
+				Value:       defaultConcurrency,
+				Usage:       "Specify the number of documents downloaded at the same time",
+				Destination: &dlOpts.concurrency,
+			},
+			&cli.BoolFlag{
+				Name:        "strip-exif",
+				Usage:       "Remove the exif and the other metadata, e.g. the location, of the downloaded images",
+				Destination: &dlOpts.stripEXIF,
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if ctx.NArg() == 0 {
+				return cli.Exit("Please specify the document/folder/wiki url", exitConfigError)
+			}
+			return handleAssetsCommand(ctx.Args().Slice())
+		},
+	}
+}
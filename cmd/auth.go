@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Wsine/feishu2md/core"
+)
+
+// maxPooledClients bounds the clients kept by the pool, the least recently
+// used one is dropped beyond it
+const maxPooledClients = 64
+
+// clientPool keeps a client for each feishu app used by the requests, so that
+// the tokens and rate limits are shared between the requests of an app
+type clientPool struct {
+	mu      sync.Mutex
+	clients map[string]*pooledClient
+	// defaultClient uses the credentials of the config file
+	defaultClient *core.Client
+}
+
+type pooledClient struct {
+	client *core.Client
+	usedAt time.Time
+}
+
+// appSelection is the app selected by a request
+type appSelection struct {
+	// profile is the name of the profile of the config file, if any
+	profile string
+	// fromHeaders is set for the credentials given in the headers of the
+	// request, they are never persisted
+	fromHeaders bool
+}
+
+func newClientPool(defaultClient *core.Client) *clientPool {
+	return &clientPool{clients: make(map[string]*pooledClient), defaultClient: defaultClient}
+}
+
+// forRequest returns the client of the app selected by the request, either
+// with the X-Feishu-App-Id and X-Feishu-App-Secret headers or the name of a
+// profile of the config file in the X-Feishu-Profile header
+func (p *clientPool) forRequest(r *http.Request) (*core.Client, appSelection, error) {
+	if name := r.Header.Get("X-Feishu-Profile"); name != "" {
+		if !profileAllowed(requestAPIKey(r), name) {
+			return nil, appSelection{}, fmt.Errorf("the API key is not allowed to use the profile %s", name)
+		}
+		client, err := p.forProfile(name)
+		return client, appSelection{profile: name}, err
+	}
+	feishu := core.FeishuConfig{
+		AppId:     r.Header.Get("X-Feishu-App-Id"),
+		AppSecret: r.Header.Get("X-Feishu-App-Secret"),
+	}
+	if feishu.AppId == "" && feishu.AppSecret == "" {
+		return p.defaultClient, appSelection{}, nil
+	}
+	if feishu.AppId == "" || feishu.AppSecret == "" {
+		return nil, appSelection{}, fmt.Errorf("both the app id and the app secret are required")
+	}
+	return p.get(feishu), appSelection{fromHeaders: true}, nil
+}
+
+// forProfile returns the client of a profile of the config file
+func (p *clientPool) forProfile(name string) (*core.Client, error) {
+	profile, ok := dlConfig.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile: %s", name)
+	}
+	return p.get(profile), nil
+}
+
+func (p *clientPool) get(feishu core.FeishuConfig) *core.Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// The secret is part of the key so that a wrong secret never reuses a valid client
+	key := feishu.AppId + "\x00" + feishu.AppSecret
+	pooled, ok := p.clients[key]
+	if !ok {
+		if len(p.clients) >= maxPooledClients {
+			p.evictOldest()
+		}
+		pooled = &pooledClient{client: newClient(feishu)}
+		p.clients[key] = pooled
+	}
+	pooled.usedAt = time.Now()
+	return pooled.client
+}
+
+// evictOldest drops the least recently used client, the caller must hold p.mu
+func (p *clientPool) evictOldest() {
+	var oldest string
+	for key, pooled := range p.clients {
+		if oldest == "" || pooled.usedAt.Before(p.clients[oldest].usedAt) {
+			oldest = key
+		}
+	}
+	delete(p.clients, oldest)
+}
+
+// profileAllowed tells whether a request with the API key may select the
+// profile, only the profiles of the key in server.key_profiles are allowed.
+// Every profile is allowed when the server requires no API key.
+func profileAllowed(apiKey, profile string) bool {
+	if apiKey == "" {
+		return true
+	}
+	return slices.Contains(dlConfig.Server.KeyProfiles[apiKey], profile)
+}
+
+type apiKeyContextKey struct{}
+
+// requestAPIKey returns the API key accepted by requireAPIKey, "" when the
+// server requires no API key
+func requestAPIKey(r *http.Request) string {
+	key, _ := r.Context().Value(apiKeyContextKey{}).(string)
+	return key
+}
+
+// requireAPIKey rejects the requests without one of the configured API keys,
+// given as a bearer token, the X-API-Key header or the api_key query.
+// Every request is accepted when no key is configured.
+func requireAPIKey(keys []string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(keys) == 0 {
+			next(w, r)
+			return
+		}
+		given := r.Header.Get("X-API-Key")
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			given = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if given == "" {
+			given = r.URL.Query().Get("api_key")
+		}
+		for _, key := range keys {
+			if key != "" && subtle.ConstantTimeCompare([]byte(given), []byte(key)) == 1 {
+				next(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, key)))
+				return
+			}
+		}
+		http.Error(w, "invalid or missing API key", http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+// handleChatCommand downloads the documents shared in the messages of a chat
+func handleChatCommand(chatID string) error {
+	if err := loadDownloadConfig(); err != nil {
+		return err
+	}
+	client := newClient(dlConfig.Feishu)
+	urls, err := client.GetChatDocumentURLs(context.Background(), chatID)
+	if err != nil {
+		return fmt.Errorf("failed to list the messages of the chat, is the app a member of it? %w", err)
+	}
+	if len(urls) == 0 {
+		fmt.Println("No document found in the chat")
+		return nil
+	}
+	fmt.Printf("Found %d document(s) in the chat\n", len(urls))
+	dlOpts.format = formatMarkdown
+	return handleDownloadCommand(urls)
+}
+
+// chatCommand downloads the documents shared in a chat
+func chatCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "chat",
+		Usage:     "Download the documents shared in the messages of a chat, the app must be a member of the chat",
+		ArgsUsage: "<chat_id>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Value:       "./",
+				Usage:       "Specify the output directory for the markdown files",
+				Destination: &dlOpts.outputDir,
+			},
+			&cli.IntFlag{
+				Name:        "concurrency",
+				Value:       defaultConcurrency,
+				Usage:       "Specify the number of documents downloaded at the same time",
+				Destination: &dlOpts.concurrency,
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if ctx.NArg() == 0 {
+				return cli.Exit("Please specify the chat id, e.g. oc_xxx", exitConfigError)
+			}
+			return handleChatCommand(ctx.Args().First())
+		},
+	}
+}
@@ -6,6 +6,8 @@ import (
 
 	"github.com/Wsine/feishu2md/core"
 	"github.com/Wsine/feishu2md/utils"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
 )
 
 type ConfigOpts struct {
@@ -48,3 +50,79 @@ func handleConfigCommand() error {
 	}
 	return nil
 }
+
+// missingCredentialsHint explains the minimal authorization required by the OPEN API
+const missingCredentialsHint = `feishu app credentials are not configured.
+Feishu has no open API to read a document anonymously, even with a share link
+that anyone can read. The minimal setup is:
+  1. Create a custom app on https://open.feishu.cn/app and enable the
+     docx:document:readonly and docs:document.media:download permissions
+  2. Run "feishu2md config --appId <app id> --appSecret <app secret>", or set
+     the FEISHU_APP_ID and FEISHU_APP_SECRET environment variables
+  3. Share the document with "anyone in the organization/internet can read",
+     or add the app as a collaborator of the document`
+
+// loadDownloadConfig reads the config file into dlConfig and dlOutput, the
+// credentials fall back to the FEISHU_APP_ID and FEISHU_APP_SECRET environment
+// variables
+func loadDownloadConfig() error {
+	configPath, err := core.GetConfigFilePath()
+	if err != nil {
+		return configError(err)
+	}
+	config, err := core.ReadConfigFromFile(configPath)
+	if os.IsNotExist(err) {
+		config = core.NewConfig("", "")
+	} else if err != nil {
+		return configError(err)
+	}
+	if config.Feishu.AppId == "" && config.Feishu.AppSecret == "" {
+		config.Feishu.AppId = os.Getenv("FEISHU_APP_ID")
+		config.Feishu.AppSecret = os.Getenv("FEISHU_APP_SECRET")
+	}
+	if config.Feishu.AppId == "" || config.Feishu.AppSecret == "" {
+		return configError(errors.New(missingCredentialsHint))
+	}
+	if err := config.Validate(); err != nil {
+		return configError(err)
+	}
+	dlConfig = *config
+	dlOutput = config.Output
+	return nil
+}
+
+// newClient creates a client with the credentials and the HTTP settings of the config
+func newClient(feishu core.FeishuConfig, opts ...core.ClientOption) *core.Client {
+	opts = append(opts,
+		core.WithRequestHeaders(dlConfig.HTTP.UserAgent, dlConfig.HTTP.Headers),
+		core.WithAppCredentials(feishu.Apps))
+	client := core.NewClient(feishu.AppId, feishu.AppSecret, opts...)
+	client.SetImageNaming(dlOutput.ImageNaming)
+	client.SetStripEXIF(dlOutput.StripEXIF)
+	return client
+}
+
+// configCommand reads the config file or sets its fields
+func configCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "config",
+		Usage: "Read config file or set field(s) if provided",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "appId",
+				Value:       "",
+				Usage:       "Set app id for the OPEN API",
+				Destination: &configOpts.appId,
+			},
+			&cli.StringFlag{
+				Name:        "appSecret",
+				Value:       "",
+				Usage:       "Set app secret for the OPEN API",
+				Destination: &configOpts.appSecret,
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			return handleConfigCommand()
+		},
+	}
+}
@@ -5,42 +5,207 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
-	"sync"
+	"time"
+	"unicode"
 
 	"github.com/88250/lute"
 	"github.com/Wsine/feishu2md/core"
 	"github.com/Wsine/feishu2md/utils"
 	"github.com/chyroc/lark"
 	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
 )
 
 type DownloadOpts struct {
-	outputDir string
-	dump      bool
-	batch     bool
-	wiki      bool
+	outputDir   string
+	outputFile  string
+	dump        bool
+	batch       bool
+	wiki        bool
+	allSpaces   bool
+	mySpace     bool
+	maxDocs     int
+	sample      int
+	userToken   string
+	archive     bool
+	archiveFmt  string
+	incremental bool
+	stats       bool
+	traceFile   string
+	notify      string
+	// failOnPermission stops the download at the first document the app has
+	// no permission to read, they are skipped and listed by default
+	failOnPermission bool
+	// ignoredBlocks is the ratio of the blocks missing from the markdown to
+	// warn about, the document fails with failOnIgnoredBlocks
+	ignoredBlocks       float64
+	failOnIgnoredBlocks bool
+	// checksums writes checksums.txt next to the manifest, see the verify command
+	checksums bool
+	// stateDB is the SQLite database recording the files and the runs
+	stateDB      string
+	numPrefix    bool
+	namePrefix   string
+	prune        bool
+	force        bool
+	layout       string
+	blocks       string
+	flavor       string
+	skipEmpty    bool
+	minChars     int
+	searchIndex  bool
+	sitemap      bool
+	inlineEmbeds bool
+	format       string
+	formats      []string
+	chunkSize    int
+	chunkOverlap int
+	metadata     string
+	headingOff   int
+	stripEXIF    bool
+	summarize    bool
+	ocr          bool
+	vector       bool
+	llmEndpoint  string
+	llmModel     string
+	headerFile   string
+	footerFile   string
+	concurrency  int
+	verbose      bool
+	timeout      time.Duration
+	docTimeout   time.Duration
+	titleFilter  string
+	filterTree   bool
+	// objTypes are the comma separated object types of the wiki nodes to export
+	objTypes string
+	// diskQuota and memoryLimit are the limits in MB pausing the new
+	// downloads, 0 for the limits of the config file
+	diskQuota   int
+	memoryLimit int
+	// postProcess are the commands of --post-process, run after the ones of
+	// the config file
+	postProcess cli.StringSlice
+	// assetsOnly downloads the images and the attachments without the markdown
+	assetsOnly bool
+	// deterministic makes the output of two downloads identical byte by byte
+	deterministic bool
+}
+
+var dlOpts = defaultDownloadOpts()
+
+// defaultDownloadOpts returns the options of the flags left unset, the
+// negative values keep the settings of the config file
+func defaultDownloadOpts() DownloadOpts {
+	return DownloadOpts{
+		outputDir:     "./",
+		format:        formatMarkdown,
+		chunkOverlap:  -1,
+		headingOff:    -1,
+		concurrency:   defaultConcurrency,
+		archiveFmt:    defaultArchiveFormat,
+		ignoredBlocks: 0.05,
+	}
 }
 
-var dlOpts = DownloadOpts{}
 var dlConfig core.Config
 
+// dlOutput is the output config of the config file, before the overrides
+// of the wiki spaces and the command line flags
+var dlOutput core.OutputConfig
+
+// applyOutputFlags sets the output config from the command line flags
+func applyOutputFlags(output *core.OutputConfig) {
+	if dlOpts.flavor != "" {
+		output.Flavor = dlOpts.flavor
+	}
+	if dlOpts.inlineEmbeds {
+		output.InlineEmbeds = true
+	}
+	if dlOpts.chunkSize > 0 {
+		output.ChunkSize = dlOpts.chunkSize
+	}
+	if dlOpts.chunkOverlap >= 0 {
+		output.ChunkOverlap = dlOpts.chunkOverlap
+	}
+	if dlOpts.metadata != "" {
+		output.Metadata = dlOpts.metadata
+	}
+	if dlOpts.headingOff >= 0 {
+		output.HeadingOffset = dlOpts.headingOff
+	}
+	if dlOpts.stripEXIF {
+		output.StripEXIF = true
+	}
+}
+
+// outputConfig returns the output config of the config file for the
+// documents of the wiki space or the url, without the command line flags.
+// It only reads the loaded config, so it is safe during the exports.
+func outputConfig(spaceID, url string) (core.OutputConfig, error) {
+	return dlOutput.WithOverrides(dlConfig.Overrides, spaceID, url)
+}
+
+// applyOutputOverrides sets the output config for the documents of the wiki
+// space or the url, the command line flags take precedence over the overrides
+func applyOutputOverrides(spaceID, url string) error {
+	output, err := outputConfig(spaceID, url)
+	if err != nil {
+		return err
+	}
+	applyOutputFlags(&output)
+	dlConfig.Output = output
+	return nil
+}
+
+// dlSummarizer writes the summaries into the front matter when --summarize is set
+var dlSummarizer *core.Summarizer
+
+// dlOCR attaches the text recognized in the images when --ocr is set
+var dlOCR *core.OCR
+
+// dlVector pushes the chunks of the documents to a vector database when --vector is set
+var dlVector core.VectorStore
+var dlEmbedder *core.Embedder
+
+// dlTemplate injects the header and footer templates into the documents
+var dlTemplate *core.DocumentTemplate
+
 func downloadDocument(ctx context.Context, client *core.Client, url string, opts *DownloadOpts) error {
 	// Validate the url to download
-	docType, docToken, err := utils.ValidateDocumentURL(url)
+	resource, err := core.ParseFeishuURL(url)
+	if err == nil && resource.Type == core.ResourceShortLink {
+		// A share link redirects to the document
+		target, expandErr := client.ExpandShortLink(ctx, url)
+		if expandErr != nil {
+			return fmt.Errorf("failed to expand the share link %s: %v", url, expandErr)
+		}
+		resource, err = core.ParseFeishuURL(target)
+	}
 	if err != nil {
 		return err
 	}
+	docType, docToken := resource.Type, resource.Token
+	switch docType {
+	case core.ResourceDocx, core.ResourceDocs, core.ResourceWiki,
+		core.ResourceSheet, core.ResourceBitable, core.ResourceMindnote, core.ResourceFile:
+	default:
+		return fmt.Errorf("not a document URL: %s, use --batch for a folder or --wiki for a wiki space", url)
+	}
 	fmt.Println("Captured document token:", docToken)
+	ctx = core.WithTraceDocument(ctx, docToken)
 
 	// for a wiki page, we need to renew docType and docToken first
-	var nodeTitle string
+	var nodeTitle, nodeToken string
 	if docType == "wiki" {
+		nodeToken = docToken
 		node, err := client.GetWikiNodeInfo(ctx, docToken)
 		if err != nil {
-			err = fmt.Errorf("GetWikiNodeInfo err: %v for %v", err, url)
+			return fmt.Errorf("GetWikiNodeInfo err: %w for %v", err, url)
 		}
-		utils.CheckErr(err)
+		recordNodeTimes(node.ObjToken, node.ObjCreateTime, node.ObjEditTime, node.NodeCreateTime)
 		docType = node.ObjType
 		docToken = node.ObjToken
 		nodeTitle = node.Title
@@ -53,21 +218,59 @@ func downloadDocument(ctx context.Context, client *core.Client, url string, opts
 
 	// Handle non-docx file types (mindnote, file, sheet, bitable)
 	if docType != "docx" {
-		return downloadFile(ctx, client, docToken, nodeTitle, opts.outputDir, docType)
+		return downloadFile(ctx, client, docToken, nodeTitle, opts.outputDir, docType, opts.namePrefix)
 	}
 
 	// Process the download
 	docx, blocks, err := client.GetDocxContent(ctx, docToken)
-	utils.CheckErr(err)
+	if err != nil {
+		return fmt.Errorf("GetDocxContent err: %w for %v", err, url)
+	}
+	if dlOpts.assetsOnly {
+		return downloadAssets(ctx, client, docx, blocks, opts)
+	}
 
 	parser := core.NewParser(dlConfig.Output, client)
 	parser.SetContext(ctx)
 	parser.SetOutputDir(filepath.Join(opts.outputDir, dlConfig.Output.ImageDir))
 
 	title := docx.Title
-	markdown := parser.ParseDocxContent(docx, blocks)
+	var markdown string
+	if opts.blocks != "" {
+		// Only render the subtrees of the given blocks
+		parser.LoadDocxBlocks(blocks)
+		subtrees := make([]string, 0)
+		for _, blockID := range strings.Split(opts.blocks, ",") {
+			subtree, err := parser.ParseSubtree(strings.TrimSpace(blockID))
+			if err != nil {
+				return err
+			}
+			subtrees = append(subtrees, subtree)
+		}
+		markdown = strings.Join(subtrees, "\n")
+	} else {
+		markdown = parser.ParseDocxContent(docx, blocks)
+		if err := checkBlockCount(title, parser.BlockCount()); err != nil {
+			return err
+		}
+	}
+
+	for _, diagnostic := range parser.Diagnostics {
+		fmt.Printf("Warning: %s: %s\n", title, diagnostic)
+	}
+
+	// Skip the documents with nothing but a title
+	if dlOpts.skipEmpty {
+		if n := contentLength(markdown, !dlConfig.Output.OmitPageTitle); n == 0 || n < dlOpts.minChars {
+			dlReport.skip(title, fmt.Sprintf("empty content (%d characters)", n))
+			fmt.Printf("Skipped empty document %s\n", title)
+			return nil
+		}
+	}
 
 	if !dlConfig.Output.SkipImgDownload {
+		// The local files of the image links, to decode the sizes missing from the metadata
+		imgFiles := make(map[string]string)
 		for _, imgToken := range parser.ImgTokens {
 			localLink, err := client.DownloadImage(
 				ctx, imgToken, filepath.Join(opts.outputDir, dlConfig.Output.ImageDir),
@@ -75,17 +278,101 @@ func downloadDocument(ctx context.Context, client *core.Client, url string, opts
 			if err != nil {
 				return err
 			}
-			markdown = strings.Replace(markdown, imgToken, localLink, 1)
+			recordManifest(localLink, core.ManifestEntry{ObjToken: imgToken, ObjType: "image"})
+			if dlOCR != nil {
+				markdown = annotateImageText(ctx, markdown, imgToken, localLink)
+			}
+			imgLink := dlConfig.Output.ImageLink(opts.outputDir, localLink)
+			markdown = strings.Replace(markdown, imgToken, imgLink, 1)
+			imgFiles[imgLink] = localLink
+		}
+		if dlConfig.Output.ImageAttributes {
+			markdown = core.FillImageSizes(markdown, func(src string) (int, int, bool) {
+				file, err := os.Open(imgFiles[src])
+				if err != nil {
+					return 0, 0, false
+				}
+				defer file.Close()
+				return core.ImageSize(file)
+			})
 		}
 	}
 
 	// Format the markdown document
 	engine := lute.New(func(l *lute.Lute) {
 		l.RenderOptions.AutoSpace = true
+		// The formatting drops the hard breaks otherwise
+		l.RenderOptions.SoftBreak2HardBreak = !dlConfig.Output.HardLineBreaks()
 	})
 	result := engine.FormatStr("md", markdown)
+	result = core.ApplyLineBreakStyle(dlConfig.Output.LineBreak, result)
+	result = core.ApplyLinkStyle(dlConfig.Output.LinkStyle, result)
+
+	meta := core.DocumentMeta{
+		NodeToken: nodeToken,
+		ObjToken:  docToken,
+		ObjType:   "docx",
+		Revision:  docx.RevisionID,
+		Title:     title,
+		URL:       url,
+	}
+	fields := make([]core.FrontMatterField, 0)
+	if dlSummarizer != nil {
+		// A failed summary should not fail the export
+		summary, err := dlSummarizer.Summarize(ctx, title, result)
+		if err != nil {
+			fmt.Printf("Failed to summarize %s: %v\n", title, err)
+		} else {
+			fields = append(fields,
+				core.FrontMatterField{Key: "title", Value: title},
+				core.FrontMatterField{Key: "summary", Value: summary.Summary},
+				core.FrontMatterField{Key: "tags", Value: summary.Tags},
+			)
+		}
+	}
+	if dlConfig.Output.OmitPageTitle && len(fields) == 0 {
+		// The title is omitted from the content
+		fields = append(fields, core.FrontMatterField{Key: "title", Value: title})
+	}
+	if dlConfig.Output.Metadata == core.MetadataFrontMatter {
+		fields = append(fields, meta.FrontMatterFields()...)
+	}
+	if dlConfig.Output.DocumentProperties {
+		// Missing properties should not fail the export
+		properties, err := client.GetDocumentProperties(ctx, docToken, "docx")
+		if err != nil {
+			fmt.Printf("Failed to get the properties of %s: %v\n", title, err)
+		} else {
+			fields = append(fields, properties.FrontMatterFields()...)
+		}
+	}
+	// The custom fields take precedence over the document properties, which
+	// take precedence over the metadata and the title
+	fields = append(fields, dlConfig.Output.FrontMatterFields()...)
+	var frontMatter string
+	if len(fields) > 0 {
+		frontMatter = core.FrontMatter(fields...)
+	}
 
 	// Handle the output directory and name
+	mdName := fmt.Sprintf("%s.md", docToken)
+	if opts.outputFile != "" {
+		mdName = opts.outputFile
+	} else if dlConfig.Output.TitleAsFilename {
+		mdName = fmt.Sprintf("%s.md", core.Slugify(title, docToken, dlConfig.Output.Slug))
+	}
+	outputPath := filepath.Join(opts.outputDir, opts.namePrefix+mdName)
+
+	if dlTemplate != nil {
+		result, err = dlTemplate.Wrap(result, documentVars(url, docToken, title, outputPath))
+		if err != nil {
+			return fmt.Errorf("failed to render the header/footer template: %v", err)
+		}
+	}
+	// The html and the chunks are rendered from the content only
+	body := result
+	result = frontMatter + result
+
 	if _, err := os.Stat(opts.outputDir); os.IsNotExist(err) {
 		if err := os.MkdirAll(opts.outputDir, 0o755); err != nil {
 			return err
@@ -111,78 +398,146 @@ func downloadDocument(ctx context.Context, client *core.Client, url string, opts
 	}
 
 	// Write to markdown file
-	mdName := fmt.Sprintf("%s.md", docToken)
-	if dlConfig.Output.TitleAsFilename {
-		mdName = fmt.Sprintf("%s.md", utils.SanitizeFileName(title))
+	formats := dlOpts.formats
+	if len(formats) == 0 {
+		formats = []string{formatMarkdown}
 	}
-	outputPath := filepath.Join(opts.outputDir, mdName)
-	if err = os.WriteFile(outputPath, []byte(result), 0o644); err != nil {
+	basePath := strings.TrimSuffix(outputPath, filepath.Ext(outputPath))
+	if err := writeFormats(ctx, client, docOutput{
+		docToken:  docToken,
+		nodeToken: nodeToken,
+		url:       url,
+		title:     title,
+		revision:  docx.RevisionID,
+		markdown:  result,
+		body:      body,
+		basePath:  basePath,
+	}, formats); err != nil {
 		return err
 	}
-	fmt.Printf("Downloaded markdown file to %s\n", outputPath)
+	if dlConfig.Output.Metadata == core.MetadataSidecar {
+		metaPath, err := core.WriteDocumentMeta(basePath, meta)
+		if err != nil {
+			return err
+		}
+		recordManifest(metaPath, core.ManifestEntry{
+			NodeToken: nodeToken,
+			ObjToken:  docToken,
+			ObjType:   "docx",
+			Title:     title,
+			Revision:  docx.RevisionID,
+		})
+	}
+	if dlVector != nil {
+		if err := pushVectors(ctx, docToken, url, body); err != nil {
+			return fmt.Errorf("failed to push %s to the vector database: %w", title, err)
+		}
+	}
+	dlReport.export()
+	if dlSearchIndex != nil {
+		dlSearchIndex.Add(docToken, title, outputPath, result)
+	}
+	if dlSitemap != nil {
+		dlSitemap.Add(outputPath, docToken, nodeToken, title, parser.DocLinks)
+	}
+	if dlSite != nil {
+		dlSite.add(outputPath, title)
+	}
 
 	return nil
 }
 
+// folderURLToken returns the token of a folder URL
+func folderURLToken(url string) (string, error) {
+	resource, err := core.ParseFeishuURL(url)
+	if err != nil {
+		return "", err
+	}
+	if resource.Type != core.ResourceFolder {
+		return "", fmt.Errorf("not a folder URL: %s", url)
+	}
+	return resource.Token, nil
+}
+
 func downloadDocuments(ctx context.Context, client *core.Client, url string) error {
 	// Validate the url to download
-	folderToken, err := utils.ValidateFolderURL(url)
+	folderToken, err := folderURLToken(url)
+	if err != nil && mySpaceURLRegexp.MatchString(url) {
+		// The root folder of the personal space is listed with an empty token
+		folderToken, err = "", nil
+	}
 	if err != nil {
 		return err
 	}
 	fmt.Println("Captured folder token:", folderToken)
+	dlManifest = core.NewManifest(dlOpts.outputDir)
+	startSearchIndex(dlOpts.outputDir)
+	startSitemap(dlOpts.outputDir)
+	startSite(dlOpts.outputDir, filepath.Base(filepath.Clean(dlOpts.outputDir)))
 
-	// Error channel and wait group
-	errChan := make(chan error)
-	wg := sync.WaitGroup{}
+	pool := newDownloadPool(dlOpts.concurrency)
 
 	// Recursively go through the folder and download the documents
-	var processFolder func(ctx context.Context, folderPath, folderToken string) error
-	processFolder = func(ctx context.Context, folderPath, folderToken string) error {
-		files, err := client.GetDriveFolderFileList(ctx, nil, &folderToken)
-		if err != nil {
-			return err
-		}
+	var processFolder func(ctx context.Context, folderPath, folderToken string, included bool) error
+	processFolder = func(ctx context.Context, folderPath, folderToken string, included bool) error {
 		opts := DownloadOpts{outputDir: folderPath, dump: dlOpts.dump, batch: false}
-		for _, file := range files {
+		// The pages of the folder are requested as the files are submitted
+		files := client.FolderFiles(folderToken)
+		for files.Next(ctx) {
+			file := files.Item()
+			if pool.full() {
+				return nil
+			}
+			export, subtree := dlFilter.match(file.Name, included)
 			if file.Type == "folder" {
-				_folderPath := filepath.Join(folderPath, file.Name)
-				if err := processFolder(ctx, _folderPath, file.Token); err != nil {
-					return err
+				_folderPath := slugDir(folderPath, "", file.Name, file.Token)
+				// A broken folder does not stop the others
+				if err := processFolder(ctx, _folderPath, file.Token, subtree); err != nil {
+					if dlOpts.failOnPermission && core.IsPermissionError(err) {
+						return err
+					}
+					dlReport.failLink(file.Name+"/", file.URL, err)
 				}
-			} else if file.Type == "docx" {
+			} else if file.Type == "docx" && export {
 				// concurrently download the document
-				wg.Add(1)
-				go func(_url string) {
-					if err := downloadDocument(ctx, client, _url, &opts); err != nil {
-						errChan <- err
-					}
-					wg.Done()
-				}(file.URL)
+				url := file.URL
+				pool.submit(ctx, file.Name, url, func(ctx context.Context) error {
+					return downloadDocument(ctx, client, url, &opts)
+				})
 			}
 		}
-		return nil
+		return files.Err()
 	}
-	if err := processFolder(ctx, dlOpts.outputDir, folderToken); err != nil {
+	err = processFolder(ctx, dlOpts.outputDir, folderToken, false)
+	// Wait for the started downloads to finish even if the traversal failed
+	pool.wait()
+	if err != nil {
 		return err
 	}
-
-	// Wait for all the downloads to finish
-	go func() {
-		wg.Wait()
-		close(errChan)
-	}()
-	for err := range errChan {
+	if err := finishSearchIndex(); err != nil {
 		return err
 	}
-	return nil
+	if err := finishSitemap(); err != nil {
+		return err
+	}
+	if err := finishSite(); err != nil {
+		return err
+	}
+	if err := finishManifest(); err != nil {
+		return err
+	}
+	return dlReport.err()
 }
 
 func downloadWiki(ctx context.Context, client *core.Client, url string) error {
-	prefixURL, wikiToken, err := utils.ValidateWikiURL(url)
+	resource, err := core.ParseFeishuURL(url)
 	if err != nil {
 		return err
 	}
+	if resource.Type != core.ResourceWiki && resource.Type != core.ResourceWikiSpace {
+		return fmt.Errorf("not a wiki URL: %s", url)
+	}
+	prefixURL, wikiToken := resource.Host, resource.Token
 
 	var spaceID string
 	// Check if the token is a space_id (from /wiki/settings/ URL) or a node_token (from /wiki/ URL)
@@ -195,7 +550,7 @@ func downloadWiki(ctx context.Context, client *core.Client, url string) error {
 		// It's likely a node_token, get node info to extract space_id
 		node, err := client.GetWikiNodeInfo(ctx, wikiToken)
 		if err != nil {
-			return fmt.Errorf("failed to get wiki node info: %v", err)
+			return fmt.Errorf("failed to get wiki node info: %w", err)
 		}
 		if node.SpaceID == "" {
 			return fmt.Errorf("node does not have a space_id")
@@ -203,113 +558,385 @@ func downloadWiki(ctx context.Context, client *core.Client, url string) error {
 		spaceID = node.SpaceID
 	}
 
-	folderPath, err := client.GetWikiName(ctx, spaceID)
+	wikiName, err := client.GetWikiName(ctx, spaceID)
 	if err != nil {
 		return err
 	}
-	if folderPath == "" {
+	if err := applyOutputOverrides(spaceID, url); err != nil {
+		return err
+	}
+	client.SetImageNaming(dlConfig.Output.ImageNaming)
+	client.SetStripEXIF(dlConfig.Output.StripEXIF)
+	if wikiName == "" {
 		return fmt.Errorf("failed to GetWikiName")
 	}
 	// Combine with output directory
-	folderPath = filepath.Join(dlOpts.outputDir, folderPath)
+	folderPath := filepath.Join(dlOpts.outputDir, wikiName)
+	dlManifest = core.NewManifest(folderPath)
+	startSearchIndex(folderPath)
+	startSitemap(folderPath)
+	startSite(folderPath, wikiName)
 
-	errChan := make(chan error)
+	var wikiLayout *ghWiki
+	switch dlOpts.layout {
+	case layoutDefault, layoutSite:
+	case layoutGhWiki:
+		wikiLayout = newGhWiki()
+	default:
+		return fmt.Errorf("unsupported layout: %s", dlOpts.layout)
+	}
+	wikiRoot := folderPath
 
-	var maxConcurrency = 10 // Set the maximum concurrency level
-	wg := sync.WaitGroup{}
-	semaphore := make(chan struct{}, maxConcurrency) // Create a semaphore with the maximum concurrency level
+	pool := newDownloadPool(dlOpts.concurrency)
+
+	listNodes := func(ctx context.Context, parentNodeToken *string) ([]*lark.GetWikiNodeListRespItem, error) {
+		return client.GetWikiNodeList(ctx, spaceID, parentNodeToken)
+	}
+	if dlOpts.maxDocs <= 0 {
+		// The whole tree is listed concurrently first, unless --max-docs
+		// stops the traversal early
+		tree := fetchWikiTree(ctx, client, spaceID, nil, dlOpts.concurrency)
+		fmt.Printf("Listed %d wiki nodes\n", tree.size())
+		listNodes = func(ctx context.Context, parentNodeToken *string) ([]*lark.GetWikiNodeListRespItem, error) {
+			return tree.list(parentNodeToken)
+		}
+	}
 
 	var downloadWikiNode func(ctx context.Context,
 		client *core.Client,
 		spaceID string,
 		parentPath string,
-		parentNodeToken *string) error
+		parentNodeToken *string,
+		included bool) error
 
 	downloadWikiNode = func(ctx context.Context,
 		client *core.Client,
 		spaceID string,
 		folderPath string,
-		parentNodeToken *string) error {
-		nodes, err := client.GetWikiNodeList(ctx, spaceID, parentNodeToken)
+		parentNodeToken *string,
+		included bool) error {
+		nodes, err := listNodes(ctx, parentNodeToken)
 		if err != nil {
 			return err
 		}
-		for _, n := range nodes {
+		for i, n := range nodes {
+			if pool.full() {
+				return nil
+			}
+			recordNodeTimes(n.ObjToken, n.ObjCreateTime, n.ObjEditTime, n.NodeCreateTime)
+			export, subtree := dlFilter.match(n.Title, included)
+			// A node of a type out of --include-obj-types is skipped, its
+			// descendants are still checked
+			if export && !dlObjTypes.match(n.ObjType) {
+				dlReport.skipObjType(n.ObjType)
+				export = false
+			}
+			// 按 wiki 节点顺序生成 01-、02- 形式的序号前缀
+			namePrefix := ""
+			if dlOpts.numPrefix {
+				namePrefix = numberPrefix(i+1, len(nodes))
+			}
+
 			// 先处理节点本身的文档内容（如果有的话）
 			// Handle different object types
-			if n.ObjType == "docx" {
-				opts := DownloadOpts{outputDir: folderPath, dump: dlOpts.dump, batch: false}
-				wg.Add(1)
-				semaphore <- struct{}{}
-				go func(_url string) {
-					if err := downloadDocument(ctx, client, _url, &opts); err != nil {
-						errChan <- err
+			// A node filtered out by --title-filter is not exported, but its
+			// descendants may be
+			if export && n.ObjType == "docx" {
+				opts := DownloadOpts{outputDir: folderPath, dump: dlOpts.dump, batch: false, namePrefix: namePrefix}
+				if wikiLayout != nil {
+					// Wiki repositories have a flat namespace of pages
+					rel, _ := filepath.Rel(wikiRoot, folderPath)
+					depth := len(strings.Split(filepath.ToSlash(rel), "/"))
+					if rel == "." {
+						depth = 0
 					}
-					wg.Done()
-					<-semaphore
-				}(prefixURL + "/wiki/" + n.NodeToken)
-			} else if n.ObjType == "mindnote" || n.ObjType == "file" || n.ObjType == "sheet" || n.ObjType == "bitable" {
-				// Download other file types (mindnote, video, sheet, bitable, etc.)
+					opts.outputDir = wikiRoot
+					opts.outputFile = wikiLayout.addPage(n.Title, depth) + ".md"
+					opts.namePrefix = ""
+				}
+				url := prefixURL + "/wiki/" + n.NodeToken
+				pool.submit(ctx, n.Title, url, func(ctx context.Context) error {
+					return downloadDocument(ctx, client, url, &opts)
+				})
+			} else if export && (n.ObjType == "mindnote" || n.ObjType == "file" || n.ObjType == "sheet" || n.ObjType == "bitable" || core.IsWhiteboard(n.ObjType)) {
+				// Download other file types (mindnote, video, sheet, bitable, whiteboard, etc.)
 				// Capture variables for goroutine
 				objToken := n.ObjToken
 				title := n.Title
 				objType := n.ObjType
-				wg.Add(1)
-				semaphore <- struct{}{}
-				go func() {
-					if err := downloadFile(ctx, client, objToken, title, folderPath, objType); err != nil {
-						errChan <- err
-					}
-					wg.Done()
-					<-semaphore
-				}()
+				fileDir := folderPath
+				if wikiLayout != nil {
+					fileDir = wikiRoot
+				}
+				pool.submit(ctx, title, prefixURL+"/wiki/"+n.NodeToken, func(ctx context.Context) error {
+					return downloadFile(ctx, client, objToken, title, fileDir, objType, namePrefix)
+				})
 			}
 
 			// 然后递归处理子节点
 			if n.HasChild {
-				_folderPath := filepath.Join(folderPath, n.Title)
+				_folderPath := slugDir(folderPath, namePrefix, n.Title, n.NodeToken)
+				// A broken node does not stop the others
 				if err := downloadWikiNode(ctx, client,
-					spaceID, _folderPath, &n.NodeToken); err != nil {
-					return err
+					spaceID, _folderPath, &n.NodeToken, subtree); err != nil {
+					if dlOpts.failOnPermission && core.IsPermissionError(err) {
+						return err
+					}
+					dlReport.failLink(n.Title+"/", prefixURL+"/wiki/"+n.NodeToken, err)
 				}
 			}
 		}
 		return nil
 	}
 
-	if err = downloadWikiNode(ctx, client, spaceID, folderPath, nil); err != nil {
+	err = downloadWikiNode(ctx, client, spaceID, folderPath, nil, false)
+	// Wait for the started downloads to finish even if the traversal failed
+	pool.wait()
+	if err != nil {
 		return err
 	}
-
-	// Wait for all the downloads to finish
-	go func() {
-		wg.Wait()
-		close(errChan)
-	}()
-	for err := range errChan {
+	if wikiLayout != nil {
+		if err := wikiLayout.writeIndex(wikiRoot, wikiName); err != nil {
+			return err
+		}
+	}
+	if err := finishSearchIndex(); err != nil {
 		return err
 	}
-	return nil
+	if err := finishSitemap(); err != nil {
+		return err
+	}
+	if err := finishSite(); err != nil {
+		return err
+	}
+	if err := finishManifest(); err != nil {
+		return err
+	}
+	return dlReport.err()
 }
 
-func handleDownloadCommand(url string) error {
-	// Load config
-	configPath, err := core.GetConfigFilePath()
+// applyDownloadOptions checks the flags and applies them to the loaded config
+func applyDownloadOptions() error {
+	if dlOpts.flavor != "" {
+		if _, err := core.ParseFlavor(dlOpts.flavor); err != nil {
+			return err
+		}
+	}
+	applyOutputFlags(&dlConfig.Output)
+
+	formats, err := parseFormats(dlOpts.format)
 	if err != nil {
 		return err
 	}
-	config, err := core.ReadConfigFromFile(configPath)
+	// The pages of a site are rendered from the markdown files
+	if dlOpts.layout == layoutSite && !slices.Contains(formats, formatMarkdown) {
+		formats = append(formats, formatMarkdown)
+	}
+	dlOpts.formats = formats
+
+	if dlOpts.summarize {
+		if dlOpts.llmEndpoint != "" {
+			dlConfig.LLM.Endpoint = dlOpts.llmEndpoint
+		}
+		if dlOpts.llmModel != "" {
+			dlConfig.LLM.Model = dlOpts.llmModel
+		}
+		if dlConfig.LLM.Endpoint == "" {
+			return fmt.Errorf("--summarize requires --llm-endpoint or the llm.endpoint in the config file")
+		}
+		dlSummarizer = core.NewSummarizer(dlConfig.LLM)
+	}
+
+	if err := loadDocumentTemplate(); err != nil {
+		return err
+	}
+
+	filter, err := newTitleFilter(dlOpts.titleFilter, dlOpts.filterTree)
+	if err != nil {
+		return fmt.Errorf("invalid --title-filter: %v", err)
+	}
+	dlFilter = filter
+	// The documents filtered out would be pruned or reported as deleted
+	if dlFilter != nil && (dlOpts.prune || dlOpts.incremental) {
+		return fmt.Errorf("--title-filter can not be used with --prune or --incremental")
+	}
+	objTypes, err := newObjTypeFilter(dlOpts.objTypes)
 	if err != nil {
+		return fmt.Errorf("invalid --include-obj-types: %v", err)
+	}
+	dlObjTypes = objTypes
+	if dlObjTypes != nil && (dlOpts.prune || dlOpts.incremental) {
+		return fmt.Errorf("--include-obj-types can not be used with --prune or --incremental")
+	}
+
+	if dlOpts.archive {
+		output, err := archivePath(dlOpts.outputDir, dlOpts.archiveFmt, time.Now())
+		if err != nil {
+			return err
+		}
+		dlOpts.outputDir = output
+	}
+
+	if dlOpts.maxDocs > 0 || dlOpts.sample > 0 {
+		if dlOpts.maxDocs > 0 && dlOpts.sample > 0 {
+			return fmt.Errorf("--max-docs can not be used with --sample")
+		}
+		// A partial download would prune or report the other documents as deleted
+		if dlOpts.prune || dlOpts.incremental {
+			return fmt.Errorf("--max-docs and --sample can not be used with --prune or --incremental")
+		}
+	}
+
+	if dlOpts.deterministic {
+		if dlSummarizer != nil {
+			return fmt.Errorf("--summarize can not be used with --deterministic")
+		}
+		// The documents sharing a file name are written in the order of the traversal
+		dlOpts.concurrency = 1
+	}
+
+	limits := dlConfig.Limits
+	if dlOpts.diskQuota > 0 {
+		limits.DiskQuotaMB = int64(dlOpts.diskQuota)
+	}
+	if dlOpts.memoryLimit > 0 {
+		limits.MemoryLimitMB = int64(dlOpts.memoryLimit)
+	}
+	dlGuard = core.NewResourceGuard(dlOpts.outputDir, limits)
+	dlHooks = append(append([]string{}, dlConfig.PostProcess...), dlOpts.postProcess.Value()...)
+	return nil
+}
+
+func handleDownloadCommand(urls []string) (err error) {
+	// Load config
+	if err := loadDownloadConfig(); err != nil {
 		return err
 	}
-	dlConfig = *config
+	if err := applyDownloadOptions(); err != nil {
+		return configError(err)
+	}
 
 	// Instantiate the client
-	client := core.NewClient(
-		dlConfig.Feishu.AppId, dlConfig.Feishu.AppSecret,
-	)
+	client := newClient(dlConfig.Feishu)
 	ctx := context.Background()
+	if dlOpts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dlOpts.timeout)
+		defer cancel()
+	}
 
+	if dlOpts.verbose {
+		client.SetVerbose(os.Stdout)
+	}
+	if dlOpts.vector {
+		finishVector, err := openVectorStore()
+		if err != nil {
+			return err
+		}
+		defer finishVector()
+	}
+	if dlOpts.ocr {
+		if dlConfig.Output.SkipImgDownload {
+			return fmt.Errorf("--ocr requires the images to be downloaded, unset skip_img_download")
+		}
+		dlOCR = core.NewOCR(dlConfig.OCR, client)
+	}
+	if dlOpts.stats {
+		defer func() { fmt.Print(client.Stats()) }()
+	}
+	defer dlReport.print(os.Stdout)
+
+	if dlOpts.traceFile != "" {
+		traceFile, err := os.OpenFile(dlOpts.traceFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return err
+		}
+		defer traceFile.Close()
+		client.SetTraceWriter(traceFile)
+	}
+	if dlOpts.userToken != "" {
+		client.SetUserAccessToken(dlOpts.userToken)
+	}
+
+	if dlOpts.mySpace {
+		if dlOpts.userToken == "" {
+			return fmt.Errorf("--my-space requires --user-token, the personal space is not accessible by the app")
+		}
+		dlOpts.batch = true
+		urls = append(urls, mySpaceURL)
+	}
+	if dlOpts.allSpaces {
+		spaces, err := client.GetWikiSpaces(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list the wiki spaces: %w", err)
+		}
+		if len(spaces) == 0 {
+			return fmt.Errorf("no wiki space is accessible by the app")
+		}
+		dlOpts.wiki = true
+		for _, space := range spaces {
+			urls = append(urls, wikiSettingsURL+space.SpaceID)
+		}
+	}
+	if dlOpts.stateDB != "" {
+		finishStateRun, err := startStateRun()
+		if err != nil {
+			return err
+		}
+		defer func() { finishStateRun(err) }()
+	}
+	if webhook := notifyWebhook(); webhook != "" && (dlOpts.batch || dlOpts.wiki) {
+		defer func() { notifyReport(webhook, urls, err) }()
+	}
+	if len(urls) == 1 {
+		err = runDownload(ctx, client, urls[0])
+	} else if strings.EqualFold(filepath.Ext(dlOpts.outputDir), ".md") {
+		return fmt.Errorf("the output must be a directory when downloading multiple urls")
+	} else {
+		err = runDownloads(ctx, client, urls)
+	}
+	// The pipeline is not fed with a partial download
+	if err == nil {
+		err = runTaskHooks(ctx)
+	}
+	return err
+}
+
+// wikiSettingsURL is the prefix of the settings url of a wiki space
+const wikiSettingsURL = "https://feishu.cn/wiki/settings/"
+
+// mySpaceURL is the url of the personal space of the user
+const mySpaceURL = "https://feishu.cn/drive/home/"
+
+var mySpaceURLRegexp = regexp.MustCompile(`^https://[\w-.]+/drive/home/?$`)
+
+// runDownloads downloads the urls one by one into the output directory, a
+// failed url does not stop the others and all failures are reported at the end
+func runDownloads(ctx context.Context, client *core.Client, urls []string) error {
+	for _, url := range urls {
+		if ctx.Err() != nil {
+			dlReport.fail(url, ctx.Err())
+			continue
+		}
+		// Every url has its own manifest, search index and sitemap
+		dlManifest = nil
+		dlSite = nil
+		failures := dlReport.failures()
+		if err := runDownload(ctx, client, url); err != nil && dlReport.failures() == failures {
+			dlReport.fail(url, err)
+		}
+	}
+	return dlReport.err()
+}
+
+// runDownload downloads the url as a folder, a wiki or a single document
+// according to dlOpts
+func runDownload(ctx context.Context, client *core.Client, url string) error {
+	if err := applyOutputOverrides("", url); err != nil {
+		return err
+	}
+	client.SetImageNaming(dlConfig.Output.ImageNaming)
+	client.SetStripEXIF(dlConfig.Output.StripEXIF)
 	if dlOpts.batch {
 		return downloadDocuments(ctx, client, url)
 	}
@@ -318,15 +945,449 @@ func handleDownloadCommand(url string) error {
 		return downloadWiki(ctx, client, url)
 	}
 
-	return downloadDocument(ctx, client, url, &dlOpts)
+	// A path ending with .md specifies the output file of a single document
+	if strings.EqualFold(filepath.Ext(dlOpts.outputDir), ".md") {
+		dlOpts.outputFile = filepath.Base(dlOpts.outputDir)
+		dlOpts.outputDir = filepath.Dir(dlOpts.outputDir)
+	}
+
+	return runWithTimeout(ctx, func(ctx context.Context) error {
+		return downloadDocument(ctx, client, url, &dlOpts)
+	})
 }
 
-func downloadFile(ctx context.Context, client *core.Client, nodeToken, title, outputDir, objType string) error {
-	// Download the file using the objToken
-	filePath, err := client.DownloadFile(ctx, nodeToken, outputDir, objType, title)
+func downloadFile(ctx context.Context, client *core.Client, nodeToken, title, outputDir, objType, namePrefix string) error {
+	// Only the uploaded files are assets, the sheets and the others are documents
+	if dlOpts.assetsOnly && objType != "file" {
+		return nil
+	}
+	// Download the file using the objToken, the whiteboards are exported as images
+	download := client.DownloadFile
+	if core.IsWhiteboard(objType) {
+		download = client.DownloadWhiteboard
+	}
+	filePath, err := download(ctx, nodeToken, outputDir, objType, core.Slugify(title, nodeToken, dlConfig.Output.Slug))
 	if err != nil {
-		return fmt.Errorf("failed to download file %s: %v", title, err)
+		return fmt.Errorf("failed to download file %s: %w", title, err)
+	}
+	if namePrefix != "" {
+		prefixedPath := filepath.Join(filepath.Dir(filePath), namePrefix+filepath.Base(filePath))
+		if err := os.Rename(filePath, prefixedPath); err != nil {
+			return err
+		}
+		filePath = prefixedPath
 	}
 	fmt.Printf("Downloaded file to %s\n", filePath)
+	dlReport.export()
+	recordManifest(filePath, core.ManifestEntry{ObjToken: nodeToken, ObjType: objType, Title: title})
+	return nil
+}
+
+// annotateImageText attaches the text recognized in the downloaded image to
+// its link, a failed recognition is only reported
+func annotateImageText(ctx context.Context, markdown, imgToken, localLink string) string {
+	image, err := os.ReadFile(localLink)
+	if err != nil {
+		fmt.Printf("Failed to read the image %s for OCR: %v\n", localLink, err)
+		return markdown
+	}
+	text, err := dlOCR.Recognize(ctx, imgToken, image)
+	if err != nil {
+		fmt.Printf("Failed to recognize the text of the image %s: %v\n", localLink, err)
+		return markdown
+	}
+	style := dlConfig.OCR.Style
+	if !dlConfig.Output.AllowHTML {
+		// The comments are HTML
+		style = core.OCRStyleAlt
+	}
+	return core.AnnotateImageText(markdown, imgToken, text, style)
+}
+
+// checkBlockCount compares the blocks rendered into the markdown with the
+// blocks of the document, a large difference means the content is lost silently
+func checkBlockCount(title string, count core.BlockCount) error {
+	if count.Ignored() == 0 || count.IgnoredRatio() <= dlOpts.ignoredBlocks {
+		if count.Ignored() > 0 && dlOpts.verbose {
+			fmt.Printf("%s: %s\n", title, count)
+		}
+		return nil
+	}
+	if dlOpts.failOnIgnoredBlocks {
+		return fmt.Errorf("too many blocks missing from %s: %s", title, count)
+	}
+	fmt.Printf("Warning: %s: %s\n", title, count)
 	return nil
 }
+
+// contentLength returns the number of non-space characters of a markdown
+// document, excluding its title line if it has one
+func contentLength(markdown string, hasTitle bool) int {
+	markdown = strings.TrimSpace(markdown)
+	if hasTitle && strings.HasPrefix(markdown, "#") {
+		_, markdown, _ = strings.Cut(markdown, "\n")
+	}
+	n := 0
+	for _, r := range markdown {
+		if !unicode.IsSpace(r) {
+			n++
+		}
+	}
+	return n
+}
+
+// numberPrefix returns the order prefix such as "01-", padded to the width of total
+func numberPrefix(index, total int) string {
+	width := len(fmt.Sprint(total))
+	if width < 2 {
+		width = 2
+	}
+	return fmt.Sprintf("%0*d-", width, index)
+}
+
+// downloadCommand downloads the documents, the folders or the wikis to markdown files
+func downloadCommand() *cli.Command {
+	defaults := defaultDownloadOpts()
+	return &cli.Command{
+		Name:    "download",
+		Aliases: []string{"dl"},
+		Usage:   "Download feishu/larksuite document to markdown file",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Value:       defaults.outputDir,
+				Usage:       "Specify the output directory for the markdown files, or the markdown file path for a single document",
+				Destination: &dlOpts.outputDir,
+			},
+			&cli.StringFlag{
+				Name:        "format",
+				Value:       defaults.format,
+				Usage:       "Specify the comma separated output formats of the documents: md, html, pdf, docx, marp, jsonl",
+				Destination: &dlOpts.format,
+			},
+			&cli.IntFlag{
+				Name:        "chunk-size",
+				Usage:       "Specify the maximum runes of a chunk of the jsonl format",
+				DefaultText: "chunk_size in the config file, 1000",
+				Destination: &dlOpts.chunkSize,
+			},
+			&cli.IntFlag{
+				Name:        "chunk-overlap",
+				Value:       defaults.chunkOverlap,
+				Usage:       "Specify the runes a chunk of the jsonl format repeats from the previous one",
+				DefaultText: "chunk_overlap in the config file, 100",
+				Destination: &dlOpts.chunkOverlap,
+			},
+			&cli.StringFlag{
+				Name:        "metadata",
+				Usage:       "Keep the tokens and the revision of the documents in a .meta.json \"sidecar\" or in the \"front_matter\"",
+				DefaultText: "metadata in the config file, none",
+				Destination: &dlOpts.metadata,
+			},
+			&cli.IntFlag{
+				Name:        "heading-offset",
+				Value:       defaults.headingOff,
+				Usage:       "Demote all the headings by the levels, e.g. 1 renders the title as ##",
+				DefaultText: "heading_offset in the config file, 0",
+				Destination: &dlOpts.headingOff,
+			},
+			&cli.BoolFlag{
+				Name:        "strip-exif",
+				Usage:       "Remove the exif and the other metadata, e.g. the location, of the downloaded images",
+				Destination: &dlOpts.stripEXIF,
+			},
+			&cli.BoolFlag{
+				Name:        "dump",
+				Value:       false,
+				Usage:       "Dump json response of the OPEN API",
+				Destination: &dlOpts.dump,
+			},
+			&cli.BoolFlag{
+				Name:        "batch",
+				Value:       false,
+				Usage:       "Download all documents under a folder",
+				Destination: &dlOpts.batch,
+			},
+			&cli.BoolFlag{
+				Name:        "wiki",
+				Value:       false,
+				Usage:       "Download all documents within the wiki.",
+				Destination: &dlOpts.wiki,
+			},
+			&cli.BoolFlag{
+				Name:        "all-spaces",
+				Value:       false,
+				Usage:       "Download all the wiki spaces accessible by the app, each into a directory named after the space",
+				Destination: &dlOpts.allSpaces,
+			},
+			&cli.BoolFlag{
+				Name:        "my-space",
+				Value:       false,
+				Usage:       "Download all documents in the personal space of the user of --user-token",
+				Destination: &dlOpts.mySpace,
+			},
+			&cli.StringFlag{
+				Name:        "user-token",
+				Value:       "",
+				EnvVars:     []string{"FEISHU_USER_ACCESS_TOKEN"},
+				Usage:       "Read the documents on behalf of a user with the user access token instead of the app",
+				Destination: &dlOpts.userToken,
+			},
+			&cli.StringFlag{
+				Name:        "flavor",
+				Value:       "",
+				Usage:       "Specify the markdown flavor: gfm, commonmark or html-rich (default: from the config file)",
+				Destination: &dlOpts.flavor,
+			},
+			&cli.BoolFlag{
+				Name:        "inline-embeds",
+				Value:       false,
+				Usage:       "Inline the content of the embedded document previews instead of linking them",
+				Destination: &dlOpts.inlineEmbeds,
+			},
+			&cli.StringFlag{
+				Name:        "blocks",
+				Value:       "",
+				Usage:       "Only download the subtrees of the comma separated block ids of a document",
+				Destination: &dlOpts.blocks,
+			},
+			&cli.BoolFlag{
+				Name:        "skip-empty",
+				Value:       false,
+				Usage:       "Skip the documents without content besides the title",
+				Destination: &dlOpts.skipEmpty,
+			},
+			&cli.IntFlag{
+				Name:        "min-chars",
+				Value:       0,
+				Usage:       "With --skip-empty, also skip the documents with fewer characters",
+				Destination: &dlOpts.minChars,
+			},
+			&cli.StringFlag{
+				Name:        "title-filter",
+				Value:       "",
+				Usage:       "Only download the documents of a batch/wiki download whose titles match the regular expression, e.g. ^\\[公开\\]",
+				Destination: &dlOpts.titleFilter,
+			},
+			&cli.BoolFlag{
+				Name:        "filter-subtree",
+				Value:       false,
+				Usage:       "With --title-filter, also download all the descendants of the matched documents",
+				Destination: &dlOpts.filterTree,
+			},
+			&cli.StringFlag{
+				Name:        "include-obj-types",
+				Value:       "",
+				Usage:       "Only download the wiki nodes of the comma separated object types: docx, sheet, bitable, mindnote, file, whiteboard (default: all)",
+				Destination: &dlOpts.objTypes,
+			},
+			&cli.BoolFlag{
+				Name:        "number-prefix",
+				Value:       false,
+				Usage:       "Prefix the file and folder names with the order of the wiki nodes, e.g. 01-",
+				Destination: &dlOpts.numPrefix,
+			},
+			&cli.StringFlag{
+				Name:        "layout",
+				Value:       "",
+				Usage:       "Specify the layout of a batch/wiki download, \"gh-wiki\" for a GitHub/GitLab wiki repository, \"site\" for a static site",
+				Destination: &dlOpts.layout,
+			},
+			&cli.IntFlag{
+				Name:        "max-docs",
+				Value:       0,
+				Usage:       "Only download the first N documents of a batch/wiki download, e.g. to check the rendering",
+				Destination: &dlOpts.maxDocs,
+			},
+			&cli.IntFlag{
+				Name:        "sample",
+				Value:       0,
+				Usage:       "Only download N documents of a batch/wiki download picked at random",
+				Destination: &dlOpts.sample,
+			},
+			&cli.BoolFlag{
+				Name:        "prune",
+				Value:       false,
+				Usage:       "Delete the local files of a batch/wiki download that no longer exist in feishu",
+				Destination: &dlOpts.prune,
+			},
+			&cli.BoolFlag{
+				Name:        "force",
+				Value:       false,
+				Usage:       "Prune without asking for confirmation",
+				Destination: &dlOpts.force,
+			},
+			&cli.BoolFlag{
+				Name:        "incremental",
+				Value:       false,
+				Usage:       "Pack the files of a batch/wiki download changed since the previous manifest.json into incremental.tar.gz",
+				Destination: &dlOpts.incremental,
+			},
+			&cli.BoolFlag{
+				Name:        "search-index",
+				Value:       false,
+				Usage:       "Generate a search-index.json with the title, path and text of the documents of a batch/wiki download",
+				Destination: &dlOpts.searchIndex,
+			},
+			&cli.BoolFlag{
+				Name:        "sitemap",
+				Value:       false,
+				Usage:       "Generate a sitemap.md and a links.dot of the references between the documents of a batch/wiki download",
+				Destination: &dlOpts.sitemap,
+			},
+			&cli.BoolFlag{
+				Name:        "summarize",
+				Value:       false,
+				Usage:       "Generate the summary and tags of the documents into the front matter with a LLM",
+				Destination: &dlOpts.summarize,
+			},
+			&cli.BoolFlag{
+				Name:        "ocr",
+				Usage:       "Attach the text recognized in the images below them, configured by the ocr section of the config file",
+				Destination: &dlOpts.ocr,
+			},
+			&cli.BoolFlag{
+				Name:        "vector",
+				Usage:       "Push the chunks of the documents with their embeddings to the vector database of the vector section of the config file",
+				Destination: &dlOpts.vector,
+			},
+			&cli.StringFlag{
+				Name:        "llm-endpoint",
+				Value:       "",
+				Usage:       "Specify the OpenAI compatible API for --summarize, e.g. https://api.openai.com/v1 (default: from the config file)",
+				Destination: &dlOpts.llmEndpoint,
+			},
+			&cli.StringFlag{
+				Name:        "llm-model",
+				Value:       "",
+				Usage:       "Specify the model for --summarize (default: from the config file)",
+				Destination: &dlOpts.llmModel,
+			},
+			&cli.IntFlag{
+				Name:        "concurrency",
+				Value:       defaults.concurrency,
+				Usage:       "Specify the number of documents downloaded at the same time in a batch/wiki download",
+				Destination: &dlOpts.concurrency,
+			},
+			&cli.IntFlag{
+				Name:        "disk-quota",
+				Usage:       "Pause the new downloads of a batch/wiki download while the output directory exceeds the size in MB",
+				DefaultText: "limits.disk_quota_mb in the config file",
+				Destination: &dlOpts.diskQuota,
+			},
+			&cli.IntFlag{
+				Name:        "memory-limit",
+				Usage:       "Pause the new downloads of a batch/wiki download while the memory of the process exceeds the size in MB",
+				DefaultText: "limits.memory_limit_mb in the config file",
+				Destination: &dlOpts.memoryLimit,
+			},
+			&cli.StringSliceFlag{
+				Name:        "post-process",
+				Usage:       "Run the shell command after every written file if it has {file}, or once after the download with {dir} as the output directory, e.g. \"prettier --write {file}\"",
+				Destination: &dlOpts.postProcess,
+			},
+			&cli.BoolFlag{
+				Name:        "archive-by-date",
+				Value:       false,
+				Usage:       "Put the output into a directory named after the date under the output directory, e.g. 2024-06-01/",
+				Destination: &dlOpts.archive,
+			},
+			&cli.StringFlag{
+				Name:        "archive-format",
+				Value:       defaults.archiveFmt,
+				Usage:       "Specify the name of the --archive-by-date directory with YYYY, MM, DD, HH, mm and ss",
+				Destination: &dlOpts.archiveFmt,
+			},
+			&cli.BoolFlag{
+				Name:        "deterministic",
+				Value:       false,
+				Usage:       "Make the output of repeated downloads identical byte by byte, e.g. for git diff",
+				Destination: &dlOpts.deterministic,
+			},
+			&cli.StringFlag{
+				Name:        "header",
+				Value:       "",
+				Usage:       "Specify a template file injected at the head of every document, e.g. a copyright notice",
+				Destination: &dlOpts.headerFile,
+			},
+			&cli.StringFlag{
+				Name:        "footer",
+				Value:       "",
+				Usage:       "Specify a template file injected at the tail of every document, e.g. a link back to the index",
+				Destination: &dlOpts.footerFile,
+			},
+			&cli.DurationFlag{
+				Name:        "timeout",
+				Value:       0,
+				Usage:       "Stop the whole download after the duration, e.g. 30m (default: no timeout)",
+				Destination: &dlOpts.timeout,
+			},
+			&cli.DurationFlag{
+				Name:        "doc-timeout",
+				Value:       0,
+				Usage:       "Give up a single document after the duration and go on with the others, e.g. 2m (default: no timeout)",
+				Destination: &dlOpts.docTimeout,
+			},
+			&cli.BoolFlag{
+				Name:        "verbose",
+				Value:       false,
+				Usage:       "Print the progress of fetching the documents",
+				Destination: &dlOpts.verbose,
+			},
+			&cli.BoolFlag{
+				Name:        "stats",
+				Value:       false,
+				Usage:       "Print the metrics of the OPEN API calls after downloading",
+				Destination: &dlOpts.stats,
+			},
+			&cli.StringFlag{
+				Name:        "trace-file",
+				Value:       "",
+				Usage:       "Write the redacted API requests and responses into a jsonl file",
+				Destination: &dlOpts.traceFile,
+			},
+			&cli.BoolFlag{
+				Name:        "fail-on-permission",
+				Usage:       "Stop at the first document or wiki node the app has no permission to read, instead of skipping it",
+				Destination: &dlOpts.failOnPermission,
+			},
+			&cli.Float64Flag{
+				Name:        "ignored-blocks-threshold",
+				Value:       defaults.ignoredBlocks,
+				Usage:       "Warn about a document when the ratio of its blocks missing from the markdown, e.g. of a new block type, exceeds the threshold",
+				Destination: &dlOpts.ignoredBlocks,
+			},
+			&cli.BoolFlag{
+				Name:        "fail-on-ignored-blocks",
+				Usage:       "Fail the documents exceeding --ignored-blocks-threshold instead of warning",
+				Destination: &dlOpts.failOnIgnoredBlocks,
+			},
+			&cli.BoolFlag{
+				Name:        "checksums",
+				Usage:       "Write the SHA256 of the files of a batch/wiki download into checksums.txt, see the verify command",
+				Destination: &dlOpts.checksums,
+			},
+			&cli.StringFlag{
+				Name:        "state-db",
+				Usage:       "Record the exported files, the runs and the failures into a SQLite database, see the state command",
+				EnvVars:     []string{"FEISHU2MD_STATE_DB"},
+				Destination: &dlOpts.stateDB,
+			},
+			&cli.StringFlag{
+				Name:        "notify-webhook",
+				Usage:       "Send the summary of a batch/wiki download to a feishu, dingtalk or slack robot webhook (default: notify.webhook in the config file)",
+				EnvVars:     []string{"FEISHU2MD_NOTIFY_WEBHOOK"},
+				Destination: &dlOpts.notify,
+			},
+		},
+		ArgsUsage: "<url>...",
+		Action: func(ctx *cli.Context) error {
+			if ctx.NArg() == 0 && !dlOpts.allSpaces && !dlOpts.mySpace {
+				return cli.Exit("Please specify the document/folder/wiki url", exitConfigError)
+			} else {
+				return handleDownloadCommand(ctx.Args().Slice())
+			}
+		},
+	}
+}
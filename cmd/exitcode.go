@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/Wsine/feishu2md/core"
+)
+
+// The exit codes of the commands, for the scripts to act on the kind of the
+// failure
+const (
+	exitOK = 0
+	// exitFailure is a partial failure of a download, some documents failed
+	// and the others were exported, or any other error
+	exitFailure = 1
+	// exitConfigError is an invalid config file, credentials, flag or argument
+	exitConfigError = 2
+	// exitPermissionError is a document, a wiki node or a scope the app has
+	// no permission to read
+	exitPermissionError = 3
+	// exitNetworkError is a failed connection or a timeout
+	exitNetworkError = 4
+)
+
+// exitError is an error with the exit code of its kind
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// configError marks an error of the config or of the flags, nil for nil
+func configError(err error) error {
+	var exitErr *exitError
+	if err == nil || errors.As(err, &exitErr) {
+		return err
+	}
+	return &exitError{code: exitConfigError, err: err}
+}
+
+// exitCode returns the exit code of the error returned by a command
+func exitCode(err error) int {
+	var exitErr *exitError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &exitErr):
+		return exitErr.code
+	case core.IsPermissionError(err) || core.IsScopeError(err):
+		return exitPermissionError
+	case core.IsCredentialError(err):
+		return exitConfigError
+	case core.IsNetworkError(err):
+		return exitNetworkError
+	}
+	return exitFailure
+}
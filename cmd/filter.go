@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Wsine/feishu2md/core"
+)
+
+// titleFilter selects the documents of a batch/wiki download by their titles
+type titleFilter struct {
+	re *regexp.Regexp
+	// subtree exports all the descendants of a matched node
+	subtree bool
+}
+
+// dlFilter is the --title-filter of the current download, nil for none
+var dlFilter *titleFilter
+
+func newTitleFilter(pattern string, subtree bool) (*titleFilter, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &titleFilter{re: re, subtree: subtree}, nil
+}
+
+// match reports whether the node is exported, and whether all of its
+// descendants are exported. inherited is true when an ancestor of the node
+// includes its subtree.
+func (f *titleFilter) match(title string, inherited bool) (bool, bool) {
+	if f == nil || inherited {
+		return true, true
+	}
+	if f.re.MatchString(title) {
+		return true, f.subtree
+	}
+	return false, false
+}
+
+// wikiObjTypes are the object types of the wiki nodes a wiki download exports
+var wikiObjTypes = []string{"docx", "sheet", "bitable", "mindnote", "file", "whiteboard"}
+
+// objTypeFilter is the set of the object types of --include-obj-types, nil
+// for all the types
+type objTypeFilter map[string]bool
+
+// dlObjTypes is the --include-obj-types of the current download
+var dlObjTypes objTypeFilter
+
+// newObjTypeFilter parses the comma separated object types
+func newObjTypeFilter(value string) (objTypeFilter, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	f := objTypeFilter{}
+	for _, objType := range strings.Split(value, ",") {
+		objType = normalizeObjType(strings.ToLower(strings.TrimSpace(objType)))
+		if objType == "" {
+			continue
+		}
+		supported := false
+		for _, t := range wikiObjTypes {
+			supported = supported || t == objType
+		}
+		if !supported {
+			return nil, fmt.Errorf("unsupported object type: %s, expected %s", objType, strings.Join(wikiObjTypes, ", "))
+		}
+		f[objType] = true
+	}
+	return f, nil
+}
+
+// normalizeObjType returns whiteboard for the object types of the whiteboards
+func normalizeObjType(objType string) string {
+	if core.IsWhiteboard(objType) {
+		return "whiteboard"
+	}
+	return objType
+}
+
+// match reports whether a wiki node of the object type is exported
+func (f objTypeFilter) match(objType string) bool {
+	return f == nil || f[normalizeObjType(objType)]
+}
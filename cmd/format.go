@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"os"
+	"strings"
+
+	"github.com/88250/lute"
+	"github.com/Wsine/feishu2md/core"
+)
+
+// Supported output formats of --format
+const (
+	formatMarkdown = "md"
+	formatHTML     = "html"
+	formatPDF      = "pdf"
+	formatDOCX     = "docx"
+	formatMarp     = "marp"
+	formatJSONL    = "jsonl"
+)
+
+// parseFormats validates the comma separated output formats
+func parseFormats(value string) ([]string, error) {
+	formats := make([]string, 0)
+	seen := map[string]bool{}
+	for _, format := range strings.Split(value, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		switch format {
+		case "":
+			continue
+		case formatMarkdown, formatHTML, formatPDF, formatDOCX, formatMarp, formatJSONL:
+		default:
+			return nil, fmt.Errorf("unsupported format: %s", format)
+		}
+		if !seen[format] {
+			seen[format] = true
+			formats = append(formats, format)
+		}
+	}
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("no output format specified")
+	}
+	return formats, nil
+}
+
+// docOutput is a parsed document to be written in every requested format
+type docOutput struct {
+	docToken  string
+	nodeToken string
+	url       string
+	title     string
+	revision  int64
+	markdown  string
+	// body is the markdown without the front matter
+	body string
+	// basePath is the output path without the extension
+	basePath string
+}
+
+// writeFormats writes the document in the formats, the document is parsed
+// only once and only pdf and docx require another API call
+func writeFormats(ctx context.Context, client *core.Client, doc docOutput, formats []string) error {
+	for _, format := range formats {
+		outputPath := doc.basePath + "." + format
+		if format == formatMarp {
+			outputPath = doc.basePath + ".marp.md"
+		}
+		switch format {
+		case formatMarkdown:
+			if err := os.WriteFile(outputPath, []byte(doc.markdown), 0o644); err != nil {
+				return err
+			}
+			fmt.Printf("Downloaded markdown file to %s\n", outputPath)
+		case formatHTML:
+			if err := os.WriteFile(outputPath, []byte(renderHTML(doc.title, doc.body)), 0o644); err != nil {
+				return err
+			}
+			fmt.Printf("Downloaded html file to %s\n", outputPath)
+		case formatPDF:
+			if err := client.ExportDocument(ctx, doc.docToken, "docx", formatPDF, outputPath); err != nil {
+				return err
+			}
+			fmt.Printf("Downloaded pdf file to %s\n", outputPath)
+		case formatDOCX:
+			if err := client.ExportDocument(ctx, doc.docToken, "docx", formatDOCX, outputPath); err != nil {
+				return err
+			}
+			fmt.Printf("Downloaded word file to %s\n", outputPath)
+		case formatMarp:
+			if err := os.WriteFile(outputPath, []byte(core.MarpSlides(doc.title, doc.markdown)), 0o644); err != nil {
+				return err
+			}
+			fmt.Printf("Downloaded marp slides to %s\n", outputPath)
+		case formatJSONL:
+			if err := writeChunks(doc, outputPath); err != nil {
+				return err
+			}
+			fmt.Printf("Downloaded jsonl chunks to %s\n", outputPath)
+		}
+		recordManifest(outputPath, core.ManifestEntry{
+			NodeToken: doc.nodeToken,
+			ObjToken:  doc.docToken,
+			ObjType:   "docx",
+			Title:     doc.title,
+			Revision:  doc.revision,
+		})
+		if err := runFileHooks(ctx, outputPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeChunks splits the body into the chunks of the configured size
+// and writes them as json lines
+func writeChunks(doc docOutput, outputPath string) error {
+	chunks := documentChunks(doc.docToken, doc.url, doc.body)
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return core.WriteChunks(file, chunks)
+}
+
+// documentChunks splits the markdown of a document into the chunks of the
+// configured size
+func documentChunks(docToken, url, markdown string) []core.Chunk {
+	chunks := core.ChunkMarkdown(markdown, dlConfig.Output.ChunkSize, dlConfig.Output.ChunkOverlap)
+	for i := range chunks {
+		chunks[i].DocID = docToken
+		chunks[i].URL = url
+	}
+	return chunks
+}
+
+// renderHTML renders the markdown as a standalone html page
+func renderHTML(title, markdown string) string {
+	engine := lute.New(func(l *lute.Lute) {
+		l.RenderOptions.AutoSpace = true
+	})
+	// The name only identifies the parse tree, the title is in the head
+	body := engine.MarkdownStr("document", markdown)
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+</head>
+<body>
+%s
+</body>
+</html>
+`, html.EscapeString(title), body)
+}
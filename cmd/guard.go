@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Wsine/feishu2md/core"
+)
+
+// dlGuard pauses the new downloads while the output directory or the memory
+// exceeds its limit, nil for no limit
+var dlGuard *core.ResourceGuard
+
+// guardPollInterval is the interval to check again an exceeded limit
+var guardPollInterval = 10 * time.Second
+
+// waitForResources blocks while a limit is exceeded, the downloads already
+// running go on. The pause is printed and sent to the notify webhook once.
+func waitForResources(ctx context.Context) error {
+	err := dlGuard.Check()
+	if err == nil {
+		return nil
+	}
+	message := fmt.Sprintf("Paused the new downloads: %v", err)
+	fmt.Fprintln(os.Stderr, message)
+	if webhook := notifyWebhook(); webhook != "" {
+		if err := core.Notify(ctx, webhook, "feishu2md: "+message); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to send the notification: %v\n", err)
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(guardPollInterval):
+		}
+		if dlGuard.Check() == nil {
+			fmt.Fprintln(os.Stderr, "Resumed the downloads")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// dlHooks are the post process commands of the current download, the ones
+// with {file} run after every file of a document is written and the others
+// once the whole download succeeds
+var dlHooks []string
+
+// isFileHook reports whether the command runs for every file
+func isFileHook(command string) bool {
+	return strings.Contains(command, "{file}")
+}
+
+// runFileHooks runs the post process commands with {file} on a written file,
+// {dir} is the directory of the file
+func runFileHooks(ctx context.Context, path string) error {
+	for _, command := range dlHooks {
+		if !isFileHook(command) {
+			continue
+		}
+		if err := runHook(ctx, command, map[string]string{"{file}": path, "{dir}": filepath.Dir(path)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runTaskHooks runs the post process commands without {file} once the
+// download into the output directory has succeeded, {dir} is the directory
+func runTaskHooks(ctx context.Context) error {
+	dir := dlOpts.outputDir
+	if strings.EqualFold(filepath.Ext(dir), ".md") {
+		dir = filepath.Dir(dir)
+	}
+	for _, command := range dlHooks {
+		if isFileHook(command) {
+			continue
+		}
+		if err := runHook(ctx, command, map[string]string{"{dir}": dir}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runHook runs the command in the shell with the placeholders replaced by
+// the quoted paths, the output goes to the output of feishu2md
+func runHook(ctx context.Context, command string, vars map[string]string) error {
+	replacements := make([]string, 0, len(vars)*2)
+	for placeholder, value := range vars {
+		replacements = append(replacements, placeholder, shellQuote(value))
+	}
+	line := strings.NewReplacer(replacements...).Replace(command)
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", line)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", line)
+	}
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("post process %q failed: %w", line, err)
+	}
+	return nil
+}
+
+// shellQuote quotes a path as a single argument of the shell
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + s + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Wsine/feishu2md/core"
+)
+
+// Status of a job
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobSucceeded = "succeeded"
+	jobFailed    = "failed"
+)
+
+// exportMu serializes the exports of the server, the download functions share
+// the state of the current download such as dlOpts and dlManifest
+var exportMu sync.Mutex
+
+// job is an asynchronous export, persisted as <id>.json in the jobs directory
+type job struct {
+	ID         string     `json:"id"`
+	URL        string     `json:"url"`
+	Wiki       bool       `json:"wiki"`
+	Batch      bool       `json:"batch"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	Exported   int        `json:"exported"`
+	Skipped    int        `json:"skipped"`
+	Failed     int        `json:"failed"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Artifact   string     `json:"artifact,omitempty"`
+	// Profile is the profile of the config file selected by the request
+	Profile string `json:"profile,omitempty"`
+	// HeaderCredentials is set for a job with the app credentials of the
+	// request headers, which are never persisted
+	HeaderCredentials bool `json:"header_credentials,omitempty"`
+	// Owner identifies the API key which created the job, only the requests
+	// with the same key see the job
+	Owner string `json:"owner,omitempty"`
+
+	client *core.Client
+	report *downloadReport
+}
+
+// jobManager runs the jobs one at a time in the order they are created
+type jobManager struct {
+	mu    sync.Mutex
+	dir   string
+	jobs  map[string]*job
+	queue chan *job
+}
+
+// newJobManager loads the jobs persisted in dir, the queued jobs are run again
+// with the app they were created with and the ones interrupted by a restart
+// are marked as failed
+func newJobManager(dir string, clients *clientPool) (*jobManager, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	m := &jobManager{dir: dir, jobs: make(map[string]*job), queue: make(chan *job, 1024)}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var queued []*job
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		j := &job{}
+		if err := json.Unmarshal(data, j); err != nil {
+			log.Printf("skipped the invalid job file %s: %v", file, err)
+			continue
+		}
+		m.jobs[j.ID] = j
+		switch j.Status {
+		case jobQueued:
+			switch {
+			case j.HeaderCredentials:
+				m.finish(j, fmt.Errorf("the app credentials of the request are not kept across a restart of the server"))
+			case j.Profile != "":
+				if j.client, err = clients.forProfile(j.Profile); err != nil {
+					m.finish(j, err)
+				} else {
+					queued = append(queued, j)
+				}
+			default:
+				j.client = clients.defaultClient
+				queued = append(queued, j)
+			}
+		case jobRunning:
+			m.finish(j, fmt.Errorf("interrupted by a restart of the server"))
+		}
+	}
+	sort.Slice(queued, func(a, b int) bool { return queued[a].CreatedAt.Before(queued[b].CreatedAt) })
+	for _, j := range queued {
+		m.queue <- j
+	}
+
+	go m.work()
+	return m, nil
+}
+
+// save persists the state of the job, the caller must hold m.mu
+func (m *jobManager) save(j *job) {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(m.dir, j.ID+".json"), data, 0o644)
+	}
+	if err != nil {
+		log.Printf("failed to save job %s: %v", j.ID, err)
+	}
+}
+
+// jobOwner identifies the API key of a request without keeping it, "" when
+// the server requires no API key
+func jobOwner(apiKey string) string {
+	if apiKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (m *jobManager) create(url string, wiki, batch bool, client *core.Client, app appSelection, owner string) (*job, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	j := &job{
+		ID:        hex.EncodeToString(id),
+		URL:       url,
+		Wiki:      wiki,
+		Batch:     batch,
+		Status:    jobQueued,
+		CreatedAt: time.Now(),
+		// Only the name of a profile is persisted, never a secret
+		Profile:           app.profile,
+		HeaderCredentials: app.fromHeaders,
+		Owner:             owner,
+		client:            client,
+	}
+
+	m.mu.Lock()
+	m.jobs[j.ID] = j
+	m.save(j)
+	m.mu.Unlock()
+
+	select {
+	case m.queue <- j:
+		return j, nil
+	default:
+		m.finish(j, fmt.Errorf("too many queued jobs"))
+		return nil, fmt.Errorf("too many queued jobs, please retry later")
+	}
+}
+
+// get returns a copy of the job with its latest progress
+func (m *jobManager) get(id string) (job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return job{}, false
+	}
+	snapshot := *j
+	if j.Status == jobRunning && j.report != nil {
+		snapshot.Exported, snapshot.Skipped, snapshot.Failed = j.report.counts()
+	}
+	return snapshot, true
+}
+
+func (m *jobManager) finish(j *job, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	j.FinishedAt = &now
+	if err != nil {
+		j.Status = jobFailed
+		j.Error = err.Error()
+	} else {
+		j.Status = jobSucceeded
+	}
+	m.save(j)
+}
+
+func (m *jobManager) work() {
+	for j := range m.queue {
+		outputDir := filepath.Join(m.dir, j.ID)
+		artifact := filepath.Join(m.dir, j.ID+".zip")
+
+		exportMu.Lock()
+		dlOpts = defaultDownloadOpts()
+		dlOpts.outputDir = outputDir
+		dlOpts.wiki = j.Wiki
+		dlOpts.batch = j.Batch
+		dlReport = &downloadReport{}
+		dlManifest = nil
+		// The job stays queued while a limit of the server is exceeded
+		_ = waitForResources(context.Background())
+		m.mu.Lock()
+		j.Status = jobRunning
+		j.report = dlReport
+		m.save(j)
+		m.mu.Unlock()
+
+		err := os.MkdirAll(outputDir, 0o755)
+		if err == nil {
+			err = runDownload(context.Background(), j.client, j.URL)
+		}
+		if err == nil {
+			err = runTaskHooks(context.Background())
+		}
+		exported, skipped, failed := dlReport.counts()
+		exportMu.Unlock()
+
+		if err == nil {
+			err = zipDir(outputDir, artifact)
+		}
+		m.mu.Lock()
+		j.Exported, j.Skipped, j.Failed = exported, skipped, failed
+		if err == nil {
+			j.Artifact = "/jobs/" + j.ID + "/artifact"
+		}
+		m.mu.Unlock()
+		m.finish(j, err)
+	}
+}
+
+// registerJobs serves the API of the asynchronous exports:
+// POST /jobs creates a job, GET /jobs/{id} returns its progress and
+// GET /jobs/{id}/artifact downloads the exported files as a zip
+func registerJobs(mux *http.ServeMux, m *jobManager, clients *clientPool, apiKeys []string) {
+	mux.HandleFunc("/jobs", requireAPIKey(apiKeys, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		req := struct {
+			URL   string `json:"url"`
+			Wiki  bool   `json:"wiki"`
+			Batch bool   `json:"batch"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Detect the folder and wiki space urls if not specified
+		if resource, err := core.ParseFeishuURL(req.URL); err == nil {
+			switch resource.Type {
+			case core.ResourceFolder:
+				req.Batch = true
+			case core.ResourceWikiSpace:
+				req.Wiki = true
+			}
+		}
+		client, app, err := clients.forRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		j, err := m.create(req.URL, req.Wiki, req.Batch, client, app, jobOwner(requestAPIKey(r)))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		snapshot, _ := m.get(j.ID)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(snapshot)
+	}))
+
+	mux.HandleFunc("/jobs/", requireAPIKey(apiKeys, func(w http.ResponseWriter, r *http.Request) {
+		id, artifact := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/artifact")
+		j, ok := m.get(id)
+		// The jobs of the other API keys are hidden
+		if !ok || strings.Contains(id, "/") || j.Owner != jobOwner(requestAPIKey(r)) {
+			http.NotFound(w, r)
+			return
+		}
+		if !artifact {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(j)
+			return
+		}
+		if j.Status != jobSucceeded {
+			http.Error(w, "the job has not succeeded", http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, j.ID))
+		http.ServeFile(w, r, filepath.Join(m.dir, j.ID+".zip"))
+	}))
+}
+
+// zipDir packs the files of dir into a zip archive at zipPath
+func zipDir(dir, zipPath string) error {
+	file, err := os.Create(zipPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := zip.NewWriter(file)
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		f, err := writer.Create(filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(f, src)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return writer.Close()
+}
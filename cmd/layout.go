@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Wsine/feishu2md/core"
+)
+
+// Supported values of the --layout flag
+const (
+	layoutDefault = ""
+	layoutGhWiki  = "gh-wiki"
+	layoutSite    = "site"
+)
+
+// ghWikiPage is a page of a GitHub/GitLab wiki repository
+type ghWikiPage struct {
+	depth int
+	title string
+	name  string
+}
+
+// ghWiki lays out the wiki nodes as the flat pages of a wiki repository,
+// with Home.md and _Sidebar.md following the order of the wiki nodes
+type ghWiki struct {
+	pages []ghWikiPage
+	used  map[string]int
+}
+
+func newGhWiki() *ghWiki {
+	return &ghWiki{used: make(map[string]int)}
+}
+
+var ghWikiInvalidChars = regexp.MustCompile(`[\\/:*?"<>|#%\[\]]+`)
+
+// addPage returns a unique page name for the title, spaces are replaced with
+// hyphens as the wiki page names do
+func (w *ghWiki) addPage(title string, depth int) string {
+	name := strings.Join(strings.Fields(title), "-")
+	name = ghWikiInvalidChars.ReplaceAllString(name, "-")
+	if name == "" || strings.EqualFold(name, "Home") || strings.HasPrefix(name, "_") {
+		name = "Page-" + name
+	}
+	w.used[strings.ToLower(name)]++
+	if count := w.used[strings.ToLower(name)]; count > 1 {
+		name = fmt.Sprintf("%s-%d", name, count)
+	}
+	w.pages = append(w.pages, ghWikiPage{depth: depth, title: title, name: name})
+	return name
+}
+
+// writeIndex writes Home.md and _Sidebar.md into the root of the wiki repository
+func (w *ghWiki) writeIndex(root, wikiName string) error {
+	toc := new(strings.Builder)
+	for _, page := range w.pages {
+		toc.WriteString(strings.Repeat("  ", page.depth))
+		toc.WriteString(fmt.Sprintf("- [%s](%s)\n", page.title, page.name))
+	}
+
+	files := map[string]string{
+		"Home.md":     fmt.Sprintf("# %s\n\n%s", wikiName, toc.String()),
+		"_Sidebar.md": fmt.Sprintf("**[%s](Home)**\n\n%s", wikiName, toc.String()),
+	}
+	for name, content := range files {
+		filePath := filepath.Join(root, name)
+		if err := os.WriteFile(filePath, []byte(content), 0o644); err != nil {
+			return err
+		}
+		recordManifest(filePath, core.ManifestEntry{Title: wikiName})
+	}
+	return nil
+}
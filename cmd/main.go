@@ -20,72 +20,30 @@ func main() {
 			return nil
 		},
 		Commands: []*cli.Command{
-			{
-				Name:  "config",
-				Usage: "Read config file or set field(s) if provided",
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:        "appId",
-						Value:       "",
-						Usage:       "Set app id for the OPEN API",
-						Destination: &configOpts.appId,
-					},
-					&cli.StringFlag{
-						Name:        "appSecret",
-						Value:       "",
-						Usage:       "Set app secret for the OPEN API",
-						Destination: &configOpts.appSecret,
-					},
-				},
-				Action: func(ctx *cli.Context) error {
-					return handleConfigCommand()
-				},
-			},
-			{
-				Name:    "download",
-				Aliases: []string{"dl"},
-				Usage:   "Download feishu/larksuite document to markdown file",
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:        "output",
-						Aliases:     []string{"o"},
-						Value:       "./",
-						Usage:       "Specify the output directory for the markdown files",
-						Destination: &dlOpts.outputDir,
-					},
-					&cli.BoolFlag{
-						Name:        "dump",
-						Value:       false,
-						Usage:       "Dump json response of the OPEN API",
-						Destination: &dlOpts.dump,
-					},
-					&cli.BoolFlag{
-						Name:        "batch",
-						Value:       false,
-						Usage:       "Download all documents under a folder",
-						Destination: &dlOpts.batch,
-					},
-					&cli.BoolFlag{
-						Name:        "wiki",
-						Value:       false,
-						Usage:       "Download all documents within the wiki.",
-						Destination: &dlOpts.wiki,
-					},
-				},
-				ArgsUsage: "<url>",
-				Action: func(ctx *cli.Context) error {
-					if ctx.NArg() == 0 {
-						return cli.Exit("Please specify the document/folder/wiki url", 1)
-					} else {
-						url := ctx.Args().First()
-						return handleDownloadCommand(url)
-					}
-				},
-			},
+			configCommand(),
+			downloadCommand(),
+			assetsCommand(),
+			chatCommand(),
+			whoamiCommand(),
+			serveCommand(),
+			scheduleCommand(),
+			stateCommand(),
+			verifyCommand(),
+			statsCommand(),
 		},
 	}
 
-	if err := app.Run(os.Args); err != nil {
-		log.Fatal(err)
+	if err := app.Run(withDefaultCommand(os.Args)); err != nil {
+		log.Print(err)
+		os.Exit(exitCode(err))
 	}
 }
+
+// withDefaultCommand keeps the short usage "feishu2md <url>" working as
+// "feishu2md download <url>"
+func withDefaultCommand(args []string) []string {
+	if len(args) > 1 && (strings.HasPrefix(args[1], "https://") || strings.HasPrefix(args[1], "http://")) {
+		return append([]string{args[0], "download"}, args[1:]...)
+	}
+	return args
+}
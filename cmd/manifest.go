@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Wsine/feishu2md/core"
+)
+
+// dlManifest records the files produced by a batch or wiki download
+var dlManifest *core.Manifest
+
+// dlNodeTimes are the times of the wiki nodes by their obj token, they are
+// added to the manifest entries of the files exported from the nodes
+var dlNodeTimes sync.Map
+
+type nodeTimes struct {
+	objCreateTime  string
+	objEditTime    string
+	nodeCreateTime string
+}
+
+func recordNodeTimes(objToken, objCreateTime, objEditTime, nodeCreateTime string) {
+	dlNodeTimes.Store(objToken, nodeTimes{
+		objCreateTime:  objCreateTime,
+		objEditTime:    objEditTime,
+		nodeCreateTime: nodeCreateTime,
+	})
+}
+
+// recordManifest adds the file to the manifest of the current download, if any
+func recordManifest(filePath string, entry core.ManifestEntry) {
+	if dlManifest != nil {
+		if v, ok := dlNodeTimes.Load(entry.ObjToken); ok {
+			times := v.(nodeTimes)
+			entry.ObjCreateTime = times.objCreateTime
+			entry.ObjEditTime = times.objEditTime
+			entry.NodeCreateTime = times.nodeCreateTime
+		}
+		dlManifest.Add(filePath, entry)
+	}
+}
+
+// slugDir returns the directory of a folder or a wiki node in parent, named
+// by the configured slug, and maps it to its title in the manifest
+func slugDir(parent, namePrefix, title, token string) string {
+	dir := filepath.Join(parent, namePrefix+core.Slugify(title, token, dlConfig.Output.Slug))
+	if dlManifest != nil {
+		dlManifest.AddDirectory(dir, namePrefix+title)
+	}
+	return dir
+}
+
+// finishManifest compares the manifest with the previous one, prunes the
+// stale files if requested and writes the new manifest
+func finishManifest() error {
+	if dlManifest == nil {
+		return nil
+	}
+	previous, err := core.ReadManifest(dlManifest.Root())
+	if err != nil {
+		return err
+	}
+	base, err := core.ManifestChecksum(dlManifest.Root())
+	if err != nil {
+		return err
+	}
+	stale := dlManifest.Stale(previous)
+	if len(stale) > 0 {
+		if dlOpts.prune && dlReport.incomplete() > 0 {
+			// The files of the failed and the denied documents would be
+			// pruned as well
+			fmt.Printf("Skipped pruning %d stale file(s) since some documents failed to download or were denied\n", len(stale))
+			dlManifest.AddEntries(stale...)
+		} else if dlOpts.prune {
+			pruned, err := pruneStaleFiles(dlManifest.Root(), stale, dlOpts.force)
+			if err != nil {
+				return err
+			}
+			if !pruned {
+				// Declined at the prompt, a later --prune asks again
+				dlManifest.AddEntries(stale...)
+			}
+		} else {
+			// Keep tracking the stale files so that a later --prune can remove them
+			dlManifest.AddEntries(stale...)
+		}
+	}
+	if err := dlManifest.Write(); err != nil {
+		return err
+	}
+	if dlOpts.checksums {
+		if err := dlManifest.WriteChecksums(); err != nil {
+			return fmt.Errorf("failed to write the checksums: %v", err)
+		}
+	}
+	if dlState != nil {
+		if err := dlState.SaveFiles(dlManifest.Root(), dlManifest.Entries, time.Now()); err != nil {
+			return fmt.Errorf("failed to save the files into the state database: %v", err)
+		}
+	}
+	if dlOpts.incremental {
+		return writeIncremental(previous, base)
+	}
+	return nil
+}
+
+// writeIncremental packs the files changed since the previous manifest into
+// incremental.tar.gz, the files removed from the manifest are listed as deleted
+func writeIncremental(previous *core.Manifest, base string) error {
+	root := dlManifest.Root()
+	info := core.IncrementalInfo{BaseManifest: base}
+	for _, entry := range dlManifest.Changed(previous) {
+		// A tracked file may be missing locally, e.g. a stale file not pruned
+		if entry.SHA256 != "" {
+			info.Changed = append(info.Changed, entry.Path)
+		}
+	}
+	for _, entry := range dlManifest.Stale(previous) {
+		info.Deleted = append(info.Deleted, entry.Path)
+	}
+	sum, err := core.ManifestChecksum(root)
+	if err != nil {
+		return err
+	}
+	info.Manifest = sum
+	if err := core.WriteIncremental(root, info); err != nil {
+		return err
+	}
+	fmt.Printf("Packed %d changed file(s) into %s\n", len(info.Changed), filepath.Join(root, core.IncrementalFileName))
+	return nil
+}
+
+// pruneStaleFiles deletes the stale files after a confirmation unless force is
+// set, it returns false when the deletion is declined
+func pruneStaleFiles(root string, stale []core.ManifestEntry, force bool) (bool, error) {
+	root = filepath.Clean(root)
+	// A hand edited or corrupted manifest must not delete the files out of
+	// the root
+	for _, entry := range stale {
+		if _, err := manifestFilePath(root, entry); err != nil {
+			return false, err
+		}
+	}
+
+	fmt.Printf("Found %d local file(s) no longer in feishu:\n", len(stale))
+	for _, entry := range stale {
+		fmt.Println("  " + entry.Path)
+	}
+	if !force {
+		fmt.Print("Delete them? [y/N] ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.ToLower(strings.TrimSpace(answer)) != "y" {
+			fmt.Println("Skipped pruning")
+			return false, nil
+		}
+	}
+
+	for _, entry := range stale {
+		filePath := filepath.Join(root, filepath.FromSlash(entry.Path))
+		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+		// Remove the directories left empty, up to the root
+		for dir := filepath.Dir(filePath); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	fmt.Printf("Pruned %d stale file(s)\n", len(stale))
+	return true, nil
+}
+
+// manifestFilePath returns the local path of a manifest entry, an error for a
+// path out of the root
+func manifestFilePath(root string, entry core.ManifestEntry) (string, error) {
+	filePath := filepath.Join(root, filepath.FromSlash(entry.Path))
+	rel, err := filepath.Rel(root, filePath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(entry.Path) {
+		return "", fmt.Errorf("invalid path in %s: %s", core.ManifestFileName, entry.Path)
+	}
+	return filePath, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Wsine/feishu2md/core"
+)
+
+// notifyMaxFailures limits the failed documents listed in a notification
+const notifyMaxFailures = 20
+
+// notifyWebhook returns the webhook of --notify-webhook or the config file
+func notifyWebhook() string {
+	if dlOpts.notify != "" {
+		return dlOpts.notify
+	}
+	return dlConfig.Notify.Webhook
+}
+
+// notifyReport sends the summary of the download to the webhook, with the
+// links of the failed documents
+func notifyReport(webhook string, urls []string, err error) {
+	if err := core.Notify(context.Background(), webhook, dlReport.summary(urls, err)); err != nil {
+		log.Printf("failed to send the notification: %v", err)
+	}
+}
+
+// summary returns the text of a notification of the report
+func (r *downloadReport) summary(urls []string, err error) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	buf := new(strings.Builder)
+	if err != nil {
+		buf.WriteString("feishu2md 导出失败")
+	} else {
+		buf.WriteString("feishu2md 导出完成")
+	}
+	fmt.Fprintf(buf, "：%s\n", strings.Join(urls, " "))
+	fmt.Fprintf(buf, "导出 %d 篇，跳过 %d 篇，失败 %d 篇", r.exported, len(r.skipped)+len(r.denied), len(r.failed))
+	if err != nil && len(r.failed) == 0 {
+		fmt.Fprintf(buf, "\n%v", err)
+	}
+	for i, item := range r.failed {
+		if i == notifyMaxFailures {
+			fmt.Fprintf(buf, "\n… 另有 %d 篇失败", len(r.failed)-i)
+			break
+		}
+		fmt.Fprintf(buf, "\n- %s：%s", item.name, item.reason)
+		if item.link != "" {
+			fmt.Fprintf(buf, " %s", item.link)
+		}
+	}
+	if len(r.denied) > 0 {
+		fmt.Fprintf(buf, "\n无权限 %d 篇，需要为应用申请权限：", len(r.denied))
+		for i, item := range r.denied {
+			if i == notifyMaxFailures {
+				fmt.Fprintf(buf, "\n… 另有 %d 篇", len(r.denied)-i)
+				break
+			}
+			fmt.Fprintf(buf, "\n- %s %s", item.name, item.link)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/Wsine/feishu2md/core"
+)
+
+// defaultConcurrency is the number of documents downloaded at the same time
+const defaultConcurrency = 10
+
+// downloadPool runs the downloads of a batch or wiki download with a limited
+// concurrency and prints the progress as each of them finishes. A failed
+// download is recorded in the report and does not stop the others.
+type downloadPool struct {
+	wg        sync.WaitGroup
+	semaphore chan struct{}
+
+	mu    sync.Mutex
+	total int
+	done  int
+
+	// limit stops taking downloads after --max-docs of them, sample keeps
+	// the downloads until wait and runs a random --sample of them
+	limit   int
+	sample  int
+	pending []pendingDownload
+	// stopped is set by a permission error with --fail-on-permission
+	stopped bool
+}
+
+// pendingDownload is a download kept for sampling
+type pendingDownload struct {
+	ctx      context.Context
+	name     string
+	link     string
+	download func(ctx context.Context) error
+}
+
+func newDownloadPool(concurrency int) *downloadPool {
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	return &downloadPool{
+		semaphore: make(chan struct{}, concurrency),
+		limit:     dlOpts.maxDocs,
+		sample:    dlOpts.sample,
+	}
+}
+
+// full reports whether --max-docs downloads have been taken or the download
+// is stopped, the traversal stops there
+func (p *downloadPool) full() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stopped || p.limit > 0 && p.total >= p.limit
+}
+
+// submit runs the download in the background, it blocks while the pool is
+// full. The link of the document is reported if the download fails.
+func (p *downloadPool) submit(ctx context.Context, name, link string, download func(ctx context.Context) error) {
+	if p.sample > 0 {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.pending = append(p.pending, pendingDownload{ctx: ctx, name: name, link: link, download: download})
+		return
+	}
+	if p.full() {
+		return
+	}
+	p.run(ctx, name, link, download)
+}
+
+func (p *downloadPool) run(ctx context.Context, name, link string, download func(ctx context.Context) error) {
+	if err := waitForResources(ctx); err != nil {
+		dlReport.failLink(name, link, err)
+		return
+	}
+	p.mu.Lock()
+	p.total++
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+	p.semaphore <- struct{}{}
+	go func() {
+		defer p.wg.Done()
+		err := runWithTimeout(ctx, download)
+		<-p.semaphore
+		if err != nil {
+			dlReport.failLink(name, link, err)
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if err != nil && dlOpts.failOnPermission && core.IsPermissionError(err) {
+			p.stopped = true
+		}
+		p.done++
+		if err != nil {
+			fmt.Printf("[%d/%d] Failed to download %s: %v\n", p.done, p.total, name, err)
+		} else {
+			fmt.Printf("[%d/%d] %s\n", p.done, p.total, name)
+		}
+	}()
+}
+
+// wait waits for all the downloads to finish, the sampled ones are started here
+func (p *downloadPool) wait() {
+	if p.sample > 0 {
+		p.mu.Lock()
+		pending := p.pending
+		p.pending = nil
+		p.mu.Unlock()
+		rand.Shuffle(len(pending), func(i, j int) { pending[i], pending[j] = pending[j], pending[i] })
+		if len(pending) > p.sample {
+			fmt.Printf("Sampled %d of %d documents\n", p.sample, len(pending))
+			pending = pending[:p.sample]
+		}
+		for _, d := range pending {
+			p.run(d.ctx, d.name, d.link, d.download)
+		}
+	}
+	p.wg.Wait()
+}
+
+// runWithTimeout runs the download of a single document within --doc-timeout
+func runWithTimeout(ctx context.Context, download func(ctx context.Context) error) error {
+	if dlOpts.docTimeout <= 0 {
+		return download(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, dlOpts.docTimeout)
+	defer cancel()
+	err := download(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s: %w", dlOpts.docTimeout, err)
+	}
+	return err
+}
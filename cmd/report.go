@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Wsine/feishu2md/core"
+)
+
+// reportItem is a document that was not downloaded, with the reason
+type reportItem struct {
+	name   string
+	reason string
+	// link is the url of the document, if known
+	link string
+	// permission is set for a document the app has no permission to read
+	permission bool
+}
+
+// downloadReport collects the documents exported, skipped and failed during a
+// download. It is safe to use concurrently.
+type downloadReport struct {
+	mu       sync.Mutex
+	exported int
+	skipped  []reportItem
+	failed   []reportItem
+	// denied are the documents and the wiki nodes the app has no permission
+	// to read, skipped unless --fail-on-permission
+	denied []reportItem
+	// skippedTypes counts the wiki nodes skipped by --include-obj-types by
+	// their object types
+	skippedTypes map[string]int
+}
+
+// dlReport is the report of the current download
+var dlReport = &downloadReport{}
+
+func (r *downloadReport) export() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exported++
+}
+
+// counts returns the number of the documents exported, skipped and failed
+// so far, the denied documents are skipped
+func (r *downloadReport) counts() (int, int, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	skipped := len(r.skipped) + len(r.denied)
+	for _, n := range r.skippedTypes {
+		skipped += n
+	}
+	return r.exported, skipped, len(r.failed)
+}
+
+func (r *downloadReport) skip(name, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.skipped = append(r.skipped, reportItem{name: name, reason: reason})
+}
+
+// skipObjType counts a wiki node skipped by --include-obj-types
+func (r *downloadReport) skipObjType(objType string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.skippedTypes == nil {
+		r.skippedTypes = make(map[string]int)
+	}
+	r.skippedTypes[normalizeObjType(objType)]++
+}
+
+// fail records a document that failed to download, the others go on
+func (r *downloadReport) fail(name string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failed = append(r.failed, reportItem{name: name, reason: err.Error(), permission: core.IsPermissionError(err)})
+}
+
+// failLink records a failed document with its url, a document the app has
+// no permission to read is listed apart unless --fail-on-permission
+func (r *downloadReport) failLink(name, link string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	item := reportItem{name: name, reason: err.Error(), link: link, permission: core.IsPermissionError(err)}
+	if !dlOpts.failOnPermission && item.permission {
+		r.denied = append(r.denied, item)
+		return
+	}
+	r.failed = append(r.failed, item)
+}
+
+// failures returns the number of the documents failed so far
+func (r *downloadReport) failures() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.failed)
+}
+
+// incomplete returns the number of the documents failed or denied so far,
+// their files are missing from the manifest of the download
+func (r *downloadReport) incomplete() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.failed) + len(r.denied)
+}
+
+// err returns an error summarizing the failed documents, if any. It exits
+// with exitPermissionError if a permission error stopped the download with
+// --fail-on-permission, exitFailure otherwise.
+func (r *downloadReport) err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.failed) == 0 {
+		return nil
+	}
+	code := exitFailure
+	for _, item := range r.failed {
+		if dlOpts.failOnPermission && item.permission {
+			code = exitPermissionError
+			break
+		}
+	}
+	return &exitError{code: code, err: fmt.Errorf("%d document(s) failed to download", len(r.failed))}
+}
+
+// print writes the summary of the report, nothing is written for an empty report
+func (r *downloadReport) print(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.skipped) > 0 {
+		fmt.Fprintf(w, "Skipped %d document(s):\n", len(r.skipped))
+		for _, item := range r.skipped {
+			fmt.Fprintf(w, "  %s: %s\n", item.name, item.reason)
+		}
+	}
+	if len(r.skippedTypes) > 0 {
+		types := make([]string, 0, len(r.skippedTypes))
+		total := 0
+		for objType, n := range r.skippedTypes {
+			types = append(types, fmt.Sprintf("%s %d", objType, n))
+			total += n
+		}
+		sort.Strings(types)
+		fmt.Fprintf(w, "Skipped %d wiki node(s) by --include-obj-types: %s\n", total, strings.Join(types, ", "))
+	}
+	if len(r.failed) > 0 {
+		fmt.Fprintf(w, "Failed %d document(s):\n", len(r.failed))
+		for _, item := range r.failed {
+			fmt.Fprintf(w, "  %s: %s\n", item.name, item.reason)
+		}
+	}
+	if len(r.denied) > 0 {
+		fmt.Fprintf(w, "Skipped %d document(s) without permission, share them with the app to export:\n", len(r.denied))
+		for _, item := range r.denied {
+			fmt.Fprintf(w, "  %s %s\n", item.name, item.link)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/robfig/cron/v3"
+	"github.com/urfave/cli/v2"
+)
+
+type ScheduleOpts struct {
+	cron         string
+	history      string
+	alertWebhook string
+}
+
+var scheduleOpts = ScheduleOpts{}
+
+// scheduleRun is a line of the history file of the scheduled exports
+type scheduleRun struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Exported   int       `json:"exported"`
+	Skipped    int       `json:"skipped"`
+	Failed     int       `json:"failed"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// handleScheduleCommand runs the download of the urls at the times of the
+// cron expression until it is stopped
+func handleScheduleCommand(urls []string) error {
+	schedule, err := cron.ParseStandard(scheduleOpts.cron)
+	if err != nil {
+		return configError(fmt.Errorf("invalid --cron: %v", err))
+	}
+	// Fail fast rather than at the first run
+	if err := loadDownloadConfig(); err != nil {
+		return err
+	}
+	history := scheduleOpts.history
+	if history == "" {
+		history = filepath.Join(dlOpts.outputDir, "schedule-history.jsonl")
+	}
+	// Every run starts from the options of the command line, the downloads
+	// change some of them such as the output of an archive
+	opts := dlOpts
+	for {
+		next := schedule.Next(time.Now())
+		log.Printf("next export at %s", next.Format(time.RFC3339))
+		time.Sleep(time.Until(next))
+
+		dlOpts = opts
+		dlReport = &downloadReport{}
+		dlManifest = nil
+		dlSite = nil
+		run := scheduleRun{StartedAt: time.Now()}
+		err := handleDownloadCommand(urls)
+		run.FinishedAt = time.Now()
+		run.Exported, run.Skipped, run.Failed = dlReport.counts()
+		if err != nil {
+			run.Error = err.Error()
+			log.Printf("scheduled export failed: %v", err)
+			alertScheduleFailure(run)
+		}
+		if err := appendScheduleHistory(history, run); err != nil {
+			log.Printf("failed to write the history: %v", err)
+		}
+	}
+}
+
+// appendScheduleHistory appends a run to the history file in json lines
+func appendScheduleHistory(path string, run scheduleRun) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return json.NewEncoder(file).Encode(run)
+}
+
+// alertScheduleFailure notifies the alert webhook of a failed run, if any
+func alertScheduleFailure(run scheduleRun) {
+	if scheduleOpts.alertWebhook == "" {
+		return
+	}
+	text := fmt.Sprintf("feishu2md 定时导出失败（%s）：%s\n导出 %d 篇，跳过 %d 篇，失败 %d 篇",
+		run.StartedAt.Format("2006-01-02 15:04"), run.Error, run.Exported, run.Skipped, run.Failed)
+	if err := core.Notify(context.Background(), scheduleOpts.alertWebhook, text); err != nil {
+		log.Printf("failed to send the alert: %v", err)
+	}
+}
+
+// scheduleCommand exports the urls periodically without an external crontab
+func scheduleCommand() *cli.Command {
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        "cron",
+			Usage:       "Specify the times of the exports as a cron expression, e.g. \"0 2 * * *\" for 2 AM every day",
+			Required:    true,
+			Destination: &scheduleOpts.cron,
+		},
+		&cli.StringFlag{
+			Name:        "history",
+			Usage:       "Specify the file of the run history in json lines, default to schedule-history.jsonl in the output directory",
+			Destination: &scheduleOpts.history,
+		},
+		&cli.StringFlag{
+			Name:        "alert-webhook",
+			Usage:       "Notify the failed exports to a feishu, dingtalk or slack robot webhook",
+			EnvVars:     []string{"FEISHU2MD_ALERT_WEBHOOK"},
+			Destination: &scheduleOpts.alertWebhook,
+		},
+	}
+	return &cli.Command{
+		Name:      "schedule",
+		Usage:     "Download the documents periodically, accepting the options of download",
+		ArgsUsage: "<url>...",
+		Flags:     append(flags, downloadCommand().Flags...),
+		Action: func(ctx *cli.Context) error {
+			if ctx.NArg() == 0 && !dlOpts.allSpaces && !dlOpts.mySpace {
+				return cli.Exit("Please specify the document/folder/wiki url", exitConfigError)
+			}
+			return handleScheduleCommand(ctx.Args().Slice())
+		},
+	}
+}
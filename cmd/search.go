@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/Wsine/feishu2md/core"
+)
+
+// dlSearchIndex collects the documents of a batch or wiki download when --search-index is set
+var dlSearchIndex *core.SearchIndex
+
+// startSearchIndex creates the search index in the root of the download if requested
+func startSearchIndex(root string) {
+	if dlOpts.searchIndex {
+		dlSearchIndex = core.NewSearchIndex(root)
+	}
+}
+
+// finishSearchIndex writes the search index of the download, if any
+func finishSearchIndex() error {
+	if dlSearchIndex == nil {
+		return nil
+	}
+	if err := dlSearchIndex.Write(); err != nil {
+		return err
+	}
+	fmt.Printf("Generated search index %s\n", filepath.Join(dlSearchIndex.Root(), core.SearchIndexFileName))
+	return nil
+}
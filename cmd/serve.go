@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/urfave/cli/v2"
+)
+
+type ServeOpts struct {
+	addr      string
+	outputDir string
+	webhook   bool
+	ui        bool
+	jobs      bool
+	debounce  time.Duration
+	apiKeys   cli.StringSlice
+}
+
+var serveOpts = ServeOpts{}
+
+// maxPendingExports bounds the documents waiting to be re-exported by the
+// webhook, the edits of the other documents are dropped beyond it
+const maxPendingExports = 256
+
+// debouncer delays the export of a document until it stops changing for a while
+type debouncer struct {
+	mu     sync.Mutex
+	delay  time.Duration
+	timers map[string]*time.Timer
+	// limit bounds the calls scheduled or running
+	limit   int
+	running int
+}
+
+// trigger schedules f after the delay, replacing the call of the key
+// scheduled before. It returns false if the limit is reached.
+func (d *debouncer) trigger(key string, f func()) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if timer, ok := d.timers[key]; ok {
+		timer.Stop()
+	} else if d.limit > 0 && len(d.timers)+d.running >= d.limit {
+		return false
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(d.delay, func() {
+		d.mu.Lock()
+		if d.timers[key] != timer {
+			// Replaced by a later trigger
+			d.mu.Unlock()
+			return
+		}
+		delete(d.timers, key)
+		d.running++
+		d.mu.Unlock()
+		f()
+		d.mu.Lock()
+		d.running--
+		d.mu.Unlock()
+	})
+	d.timers[key] = timer
+	return true
+}
+
+func handleServeCommand() error {
+	if err := loadDownloadConfig(); err != nil {
+		return err
+	}
+	feishu := dlConfig.Feishu
+	dlGuard = core.NewResourceGuard(serveOpts.outputDir, dlConfig.Limits)
+	dlHooks = dlConfig.PostProcess
+	client := newClient(feishu, core.WithEventCallback(feishu.EncryptKey, feishu.VerificationToken))
+
+	mux := http.NewServeMux()
+	if !serveOpts.webhook && !serveOpts.ui && !serveOpts.jobs {
+		return fmt.Errorf("nothing to serve, please enable at least one service such as --webhook, --ui or --jobs")
+	}
+	apiKeys := append(dlConfig.Server.APIKeys, serveOpts.apiKeys.Value()...)
+	clients := newClientPool(client)
+	if serveOpts.webhook {
+		registerWebhook(mux, client)
+	}
+	if serveOpts.ui {
+		registerUI(mux, clients, apiKeys)
+	}
+	if serveOpts.jobs {
+		jobs, err := newJobManager(filepath.Join(serveOpts.outputDir, "jobs"), clients)
+		if err != nil {
+			return err
+		}
+		registerJobs(mux, jobs, clients, apiKeys)
+	}
+
+	fmt.Printf("Listening on %s\n", serveOpts.addr)
+	return http.ListenAndServe(serveOpts.addr, mux)
+}
+
+// registerWebhook receives the document edit events and re-exports the documents
+func registerWebhook(mux *http.ServeMux, client *core.Client) {
+	d := &debouncer{delay: serveOpts.debounce, timers: make(map[string]*time.Timer), limit: maxPendingExports}
+	client.HandleDocumentEvents(func(ctx context.Context, fileToken, fileType string) {
+		if fileType != "docx" {
+			return
+		}
+		triggered := d.trigger(fileToken, func() {
+			exportMu.Lock()
+			defer exportMu.Unlock()
+			// The same settings as the jobs
+			dlOpts = defaultDownloadOpts()
+			dlOpts.outputDir = serveOpts.outputDir
+			dlReport = &downloadReport{}
+			dlManifest = nil
+			url := "https://feishu.cn/docx/" + fileToken
+			// The file of a document already in the mirror is updated in place
+			if filePath, nodeToken, ok := mirroredDocument(serveOpts.outputDir, fileToken); ok {
+				dlOpts.outputDir = filePath
+				if nodeToken != "" {
+					url = "https://feishu.cn/wiki/" + nodeToken
+				}
+			}
+			if err := runDownload(context.Background(), client, url); err != nil {
+				log.Printf("failed to export %s: %v", fileToken, err)
+			}
+		})
+		if !triggered {
+			log.Printf("skipped the export of %s, %d documents are already pending", fileToken, maxPendingExports)
+		}
+	})
+
+	verifySignature := dlConfig.Feishu.EncryptKey != ""
+	mux.HandleFunc("/webhook", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		client.ListenEventCallback(r.Context(), r.Header, r.Body, w, verifySignature)
+	})
+}
+
+// mirroredDocument returns the markdown file of the document in the manifest
+// of the output directory, and its wiki node if any
+func mirroredDocument(root, docToken string) (string, string, bool) {
+	manifest, err := core.ReadManifest(root)
+	if err != nil {
+		log.Printf("failed to read the manifest of %s: %v", root, err)
+		return "", "", false
+	}
+	for _, entry := range manifest.Entries {
+		if entry.ObjToken != docToken || entry.ObjType != "docx" ||
+			!strings.HasSuffix(entry.Path, ".md") || strings.HasSuffix(entry.Path, ".marp.md") {
+			continue
+		}
+		filePath, err := manifestFilePath(root, entry)
+		if err != nil {
+			log.Print(err)
+			return "", "", false
+		}
+		return filePath, entry.NodeToken, true
+	}
+	return "", "", false
+}
+
+// serveCommand runs the HTTP server of the webhook, the web page and the jobs
+func serveCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "serve",
+		Usage: "Run a HTTP server to export documents on demand",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "addr",
+				Value:       ":8080",
+				Usage:       "Specify the address to listen on",
+				Destination: &serveOpts.addr,
+			},
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Value:       "./",
+				Usage:       "Specify the output directory for the markdown files",
+				Destination: &serveOpts.outputDir,
+			},
+			&cli.BoolFlag{
+				Name:        "webhook",
+				Value:       false,
+				Usage:       "Receive document edit events on /webhook and re-export the documents",
+				Destination: &serveOpts.webhook,
+			},
+			&cli.BoolFlag{
+				Name:        "ui",
+				Value:       false,
+				Usage:       "Serve a web page on / to convert, preview and download documents",
+				Destination: &serveOpts.ui,
+			},
+			&cli.BoolFlag{
+				Name:        "jobs",
+				Value:       false,
+				Usage:       "Serve the asynchronous export jobs on /jobs, persisted under <output>/jobs",
+				Destination: &serveOpts.jobs,
+			},
+			&cli.StringSliceFlag{
+				Name:        "api-key",
+				Usage:       "Require one of the API keys for the /api endpoints, in addition to server.api_keys of the config file",
+				Destination: &serveOpts.apiKeys,
+			},
+			&cli.DurationFlag{
+				Name:        "debounce",
+				Value:       10 * time.Second,
+				Usage:       "Wait until a document stops changing for the duration before re-exporting",
+				Destination: &serveOpts.debounce,
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			return handleServeCommand()
+		},
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+// handlerTransport serves the requests of the lark clients with a handler in
+// memory. Without the network, only the synchronization of the code orders
+// the goroutines for the race detector.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	t.handler.ServeHTTP(rec, req)
+	return rec.Result(), nil
+}
+
+// fakeFeishu serves the open APIs to read the documents, every document has a
+// title and a paragraph
+func fakeFeishu(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/open-apis/auth/v3/tenant_access_token/internal", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"code":0,"tenant_access_token":"t-token","expire":7200}`)
+	})
+	mux.HandleFunc("/open-apis/docx/v1/documents/", func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/open-apis/docx/v1/documents/")
+		docToken, blocks := strings.CutSuffix(path, "/blocks")
+		if !blocks {
+			fmt.Fprintf(w, `{"code":0,"data":{"document":{"document_id":%q,"revision_id":1,"title":"Title"}}}`, docToken)
+			return
+		}
+		fmt.Fprintf(w, `{"code":0,"data":{"has_more":false,"items":[
+			{"block_id":%q,"block_type":1,"children":["text"],"page":{"elements":[{"text_run":{"content":"Title"}}]}},
+			{"block_id":"text","parent_id":%q,"block_type":2,"text":{"elements":[{"text_run":{"content":"Hello"}}]}}
+		]}}`, docToken, docToken)
+	})
+
+	transport := http.DefaultTransport
+	http.DefaultTransport = handlerTransport{handler: mux}
+	t.Cleanup(func() { http.DefaultTransport = transport })
+}
+
+// appRequest returns a request of the server with the credentials of an app in
+// the headers, each app has its own client and rate limit
+func appRequest(method, target, body, appID string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("X-Feishu-App-Id", appID)
+	req.Header.Set("X-Feishu-App-Secret", "app_secret")
+	return req
+}
+
+// TestServeConcurrentExports converts documents with the web ui while the
+// jobs export, run with -race to detect the state shared between the exports
+func TestServeConcurrentExports(t *testing.T) {
+	fakeFeishu(t)
+	config, output := dlConfig, dlOutput
+	t.Cleanup(func() { dlConfig, dlOutput = config, output })
+	dlConfig = *core.NewConfig("app_id", "app_secret")
+	dlOutput = dlConfig.Output
+
+	clients := newClientPool(newClient(dlConfig.Feishu))
+	jobs, err := newJobManager(t.TempDir(), clients)
+	assert.NoError(t, err)
+	mux := http.NewServeMux()
+	registerUI(mux, clients, nil)
+	registerJobs(mux, jobs, clients, nil)
+
+	// The conversions go on until the jobs finish
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for n := 0; ; n++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				rec := httptest.NewRecorder()
+				mux.ServeHTTP(rec, appRequest(http.MethodGet,
+					"/api/convert?url="+url.QueryEscape("https://feishu.cn/docx/UIDocument"), "", fmt.Sprintf("app_%d_%d", i, n)))
+				doc := convertedDocument{}
+				assert.NoError(t, json.NewDecoder(rec.Body).Decode(&doc))
+				assert.Equal(t, "UIDocument", doc.Token)
+				assert.Contains(t, doc.Markdown, "Hello")
+			}
+		}(i)
+	}
+
+	ids := make([]string, 0)
+	for i := 0; i < 20; i++ {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, appRequest(http.MethodPost, "/jobs",
+			`{"url":"https://feishu.cn/docx/JobDocument"}`, fmt.Sprintf("job_%d", i)))
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		created := job{}
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&created))
+		ids = append(ids, created.ID)
+	}
+
+	deadline := time.Now().Add(30 * time.Second)
+	for _, id := range ids {
+		for {
+			snapshot, _ := jobs.get(id)
+			if snapshot.Status == jobSucceeded || snapshot.Status == jobFailed || time.Now().After(deadline) {
+				assert.Equal(t, jobSucceeded, snapshot.Status, snapshot.Error)
+				assert.Equal(t, 1, snapshot.Exported)
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
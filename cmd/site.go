@@ -0,0 +1,193 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"html"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/88250/lute"
+	"github.com/Wsine/feishu2md/core"
+)
+
+//go:embed site/site.css
+var siteCSS []byte
+
+// siteStylesheet is the path of the stylesheet in the root of a site
+const siteStylesheet = "assets/site.css"
+
+// sitePage is a document of a --layout site download
+type sitePage struct {
+	title string
+	// path is the slash separated path of the markdown file relative to the root
+	path string
+}
+
+// site renders the documents of a batch or wiki download as a static site,
+// every page has a navigation of all the pages
+type site struct {
+	mu    sync.Mutex
+	root  string
+	title string
+	pages []sitePage
+}
+
+// dlSite collects the documents of a download with --layout site
+var dlSite *site
+
+// startSite creates the site in the root of the download if requested
+func startSite(root, title string) {
+	if dlOpts.layout == layoutSite {
+		dlSite = &site{root: root, title: title}
+	}
+}
+
+func (s *site) add(mdPath, title string) {
+	rel, err := filepath.Rel(s.root, mdPath)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pages = append(s.pages, sitePage{title: title, path: filepath.ToSlash(rel)})
+}
+
+// finishSite renders the html pages, the index and the stylesheet of the site, if any
+func finishSite() error {
+	if dlSite == nil {
+		return nil
+	}
+	s := dlSite
+	sort.Slice(s.pages, func(i, j int) bool { return s.pages[i].path < s.pages[j].path })
+
+	for _, page := range s.pages {
+		markdown, err := os.ReadFile(filepath.Join(s.root, filepath.FromSlash(page.path)))
+		if err != nil {
+			return err
+		}
+		htmlPath := sitePagePath(page.path)
+		if err := s.writePage(htmlPath, page.title, renderSiteBody(page.title, string(markdown))); err != nil {
+			return err
+		}
+	}
+
+	index := fmt.Sprintf("<h1>%s</h1>\n%s", html.EscapeString(s.title), s.nav("index.html"))
+	if err := s.writePage("index.html", s.title, index); err != nil {
+		return err
+	}
+	stylesheet := filepath.Join(s.root, filepath.FromSlash(siteStylesheet))
+	if err := os.MkdirAll(filepath.Dir(stylesheet), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(stylesheet, siteCSS, 0o644); err != nil {
+		return err
+	}
+	recordManifest(stylesheet, core.ManifestEntry{})
+	fmt.Printf("Generated static site %s\n", filepath.Join(s.root, "index.html"))
+	return nil
+}
+
+// sitePagePath returns the path of the html page of a markdown file
+func sitePagePath(mdPath string) string {
+	return strings.TrimSuffix(mdPath, path.Ext(mdPath)) + ".html"
+}
+
+// siteLocalLink matches the relative links to the markdown files
+var siteLocalLink = regexp.MustCompile(`href="([^":#]+)\.md(#[^"]*)?"`)
+
+// renderSiteBody renders the markdown as html, the links to the other
+// markdown files point to their html pages
+func renderSiteBody(title, markdown string) string {
+	engine := lute.New(func(l *lute.Lute) {
+		l.RenderOptions.AutoSpace = true
+	})
+	body := engine.MarkdownStr(filepath.Base(title), markdown)
+	return siteLocalLink.ReplaceAllString(body, `href="$1.html$2"`)
+}
+
+// writePage writes a html page with the navigation of the site
+func (s *site) writePage(pagePath, title, body string) error {
+	base := relativeLink(pagePath, "")
+	page := fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+<link rel="stylesheet" href="%s">
+</head>
+<body>
+<nav class="sidebar">
+<a class="home" href="%s">%s</a>
+%s</nav>
+<main class="content">
+%s
+</main>
+</body>
+</html>
+`, html.EscapeString(title), base+siteStylesheet, base+"index.html", html.EscapeString(s.title), s.nav(pagePath), body)
+
+	filePath := filepath.Join(s.root, filepath.FromSlash(pagePath))
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filePath, []byte(page), 0o644); err != nil {
+		return err
+	}
+	recordManifest(filePath, core.ManifestEntry{Title: title})
+	return nil
+}
+
+// nav renders the pages as nested lists following the directories, the links
+// are relative to the current page
+func (s *site) nav(current string) string {
+	buf := new(strings.Builder)
+	buf.WriteString("<ul>\n")
+	var open []string
+	for _, page := range s.pages {
+		var dirs []string
+		if dir := path.Dir(page.path); dir != "." {
+			dirs = strings.Split(dir, "/")
+		}
+		common := 0
+		for common < len(open) && common < len(dirs) && open[common] == dirs[common] {
+			common++
+		}
+		for i := len(open); i > common; i-- {
+			buf.WriteString("</ul></li>\n")
+		}
+		for _, dir := range dirs[common:] {
+			buf.WriteString(fmt.Sprintf("<li><span class=\"folder\">%s</span><ul>\n", html.EscapeString(dir)))
+		}
+		open = dirs
+
+		htmlPath := sitePagePath(page.path)
+		class := ""
+		if htmlPath == current {
+			class = ` class="current"`
+		}
+		buf.WriteString(fmt.Sprintf("<li><a href=\"%s\"%s>%s</a></li>\n",
+			html.EscapeString(relativeLink(current, htmlPath)), class, html.EscapeString(page.title)))
+	}
+	for range open {
+		buf.WriteString("</ul></li>\n")
+	}
+	buf.WriteString("</ul>\n")
+	return buf.String()
+}
+
+// relativeLink returns the link from the page to the target, both relative
+// to the root of the site, e.g. ../b/c.html
+func relativeLink(from, to string) string {
+	depth := 0
+	if dir := path.Dir(from); dir != "." {
+		depth = len(strings.Split(dir, "/"))
+	}
+	return strings.Repeat("../", depth) + to
+}
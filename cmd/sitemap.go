@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/Wsine/feishu2md/core"
+)
+
+// dlSitemap collects the documents of a batch or wiki download when --sitemap is set
+var dlSitemap *core.Sitemap
+
+// startSitemap creates the sitemap in the root of the download if requested
+func startSitemap(root string) {
+	if dlOpts.sitemap {
+		dlSitemap = core.NewSitemap(root)
+	}
+}
+
+// finishSitemap writes the sitemap and the link graph of the download, if any
+func finishSitemap() error {
+	if dlSitemap == nil {
+		return nil
+	}
+	if err := dlSitemap.Write(); err != nil {
+		return err
+	}
+	fmt.Printf("Generated sitemap %s and link graph %s\n",
+		filepath.Join(dlSitemap.Root(), core.SitemapFileName),
+		filepath.Join(dlSitemap.Root(), core.LinkGraphFileName))
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/urfave/cli/v2"
+)
+
+// dlState is the state database of the current download, if --state-db is set
+var dlState *core.StateDB
+
+// startStateRun opens the state database and records the start of the
+// download, the returned function records its result and closes the database
+func startStateRun() (func(err error), error) {
+	state, err := core.OpenStateDB(dlOpts.stateDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the state database: %v", err)
+	}
+	run := core.StateRun{StartedAt: time.Now()}
+	if run.ID, err = state.StartRun(run.StartedAt); err != nil {
+		state.Close()
+		return nil, err
+	}
+	dlState = state
+	return func(err error) {
+		defer func() {
+			state.Close()
+			dlState = nil
+		}()
+		run.FinishedAt = time.Now()
+		run.Exported, run.Skipped, run.Failed = dlReport.counts()
+		if err != nil {
+			run.Error = err.Error()
+		}
+		if err := state.FinishRun(run, dlReport.stateFailures()); err != nil {
+			fmt.Printf("Failed to record the run in the state database: %v\n", err)
+		}
+	}, nil
+}
+
+// stateFailures returns the failed and the denied documents of the report
+func (r *downloadReport) stateFailures() []core.StateFailure {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	failures := make([]core.StateFailure, 0, len(r.failed)+len(r.denied))
+	for _, item := range r.failed {
+		failures = append(failures, core.StateFailure{Name: item.name, Link: item.link, Reason: item.reason})
+	}
+	for _, item := range r.denied {
+		failures = append(failures, core.StateFailure{Name: item.name, Link: item.link, Reason: item.reason, Denied: true})
+	}
+	return failures
+}
+
+type StateOpts struct {
+	path  string
+	runs  int
+	files string
+}
+
+var stateOpts = StateOpts{}
+
+// handleStateCommand prints the latest runs recorded in the state database
+// with the failures of the latest one, or the exported files
+func handleStateCommand() error {
+	if _, err := os.Stat(stateOpts.path); err != nil {
+		return err
+	}
+	state, err := core.OpenStateDB(stateOpts.path)
+	if err != nil {
+		return err
+	}
+	defer state.Close()
+
+	if stateOpts.files != "" {
+		files, err := state.Files(stateOpts.files)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			fmt.Printf("%s  %-8s %s  %s\n", file.ExportedAt.Format("2006-01-02 15:04"), file.ObjType, file.Path, file.Title)
+		}
+		return nil
+	}
+
+	runs, err := state.Runs(stateOpts.runs)
+	if err != nil {
+		return err
+	}
+	for _, run := range runs {
+		status := "running"
+		if !run.FinishedAt.IsZero() {
+			status = fmt.Sprintf("exported %d, skipped %d, failed %d", run.Exported, run.Skipped, run.Failed)
+		}
+		fmt.Printf("#%d %s  %s\n", run.ID, run.StartedAt.Format("2006-01-02 15:04:05"), status)
+		if run.Error != "" {
+			fmt.Printf("  Error: %s\n", run.Error)
+		}
+	}
+	if len(runs) == 0 {
+		return nil
+	}
+	failures, err := state.Failures(runs[0].ID)
+	if err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		fmt.Printf("Failures of run #%d:\n", runs[0].ID)
+	}
+	for _, failure := range failures {
+		reason := failure.Reason
+		if failure.Denied {
+			reason = "no permission: " + reason
+		}
+		fmt.Printf("  %s %s: %s\n", failure.Name, failure.Link, reason)
+	}
+	return nil
+}
+
+// stateCommand reports the history and the files recorded with --state-db
+func stateCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "state",
+		Usage: "Print the history of the downloads or the exported files recorded in a state database",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "state-db",
+				Usage:       "Specify the SQLite state database written by download --state-db",
+				Required:    true,
+				EnvVars:     []string{"FEISHU2MD_STATE_DB"},
+				Destination: &stateOpts.path,
+			},
+			&cli.IntFlag{
+				Name:        "runs",
+				Value:       10,
+				Usage:       "Specify the number of the latest runs to print",
+				Destination: &stateOpts.runs,
+			},
+			&cli.StringFlag{
+				Name:        "files",
+				Usage:       "Print the files exported into the output directory instead of the runs",
+				Destination: &stateOpts.files,
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			return handleStateCommand()
+		},
+	}
+}
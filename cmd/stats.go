@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/Wsine/feishu2md/utils"
+	"github.com/chyroc/lark"
+	"github.com/urfave/cli/v2"
+)
+
+type statsOptions struct {
+	batch     bool
+	wiki      bool
+	json      bool
+	skipSizes bool
+}
+
+var statsOpts = statsOptions{}
+
+// handleStatsCommand counts the block types, the assets and the code
+// languages of a document, a folder, a wiki or a json dumped with --dump
+func handleStatsCommand(target string) error {
+	stats := core.NewBlockStats()
+	if strings.HasPrefix(target, "https://") || strings.HasPrefix(target, "http://") {
+		if err := loadDownloadConfig(); err != nil {
+			return err
+		}
+		client := newClient(dlConfig.Feishu)
+		ctx := context.Background()
+		var err error
+		switch {
+		case statsOpts.batch:
+			err = statsFolder(ctx, client, target, stats)
+		case statsOpts.wiki:
+			err = statsWiki(ctx, client, target, stats)
+		default:
+			err = statsDocument(ctx, client, target, stats)
+		}
+		if err != nil {
+			return err
+		}
+	} else {
+		data, err := os.ReadFile(target)
+		if err != nil {
+			return err
+		}
+		dump := struct {
+			Blocks []*lark.DocxBlock `json:"blocks"`
+		}{}
+		if err := json.Unmarshal(data, &dump); err != nil {
+			return fmt.Errorf("failed to parse %s: %v", target, err)
+		}
+		stats.Add(dump.Blocks)
+	}
+
+	if statsOpts.json {
+		fmt.Println(utils.PrettyPrint(stats))
+		return nil
+	}
+	fmt.Print(stats)
+	return nil
+}
+
+// statsDocument adds the statistics of a docx, the url is a docx or a wiki page
+func statsDocument(ctx context.Context, client *core.Client, url string, stats *core.BlockStats) error {
+	resource, err := core.ParseFeishuURL(url)
+	if err != nil {
+		return err
+	}
+	docType, docToken := resource.Type, resource.Token
+	if docType == core.ResourceWiki {
+		node, err := client.GetWikiNodeInfo(ctx, docToken)
+		if err != nil {
+			return fmt.Errorf("GetWikiNodeInfo err: %w for %v", err, url)
+		}
+		docType, docToken = node.ObjType, node.ObjToken
+	}
+	if docType != core.ResourceDocx {
+		return fmt.Errorf("only the statistics of a docx document are supported: %s", url)
+	}
+	return statsDocx(ctx, client, docToken, stats)
+}
+
+func statsDocx(ctx context.Context, client *core.Client, docToken string, stats *core.BlockStats) error {
+	docx, blocks, err := client.GetDocxContent(ctx, docToken)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Counting %s\n", docx.Title)
+	stats.Add(blocks)
+	if statsOpts.skipSizes {
+		return nil
+	}
+	for _, asset := range core.DocumentAssets(blocks) {
+		size, err := client.MediaSize(ctx, asset.Token)
+		if err != nil {
+			// A missing size does not stop the statistics
+			fmt.Fprintf(os.Stderr, "Failed to get the size of %s: %v\n", asset.Token, err)
+			continue
+		}
+		stats.AddAssetSize(asset.Type, size)
+	}
+	return nil
+}
+
+// statsFolder adds the statistics of the docx documents under a folder
+func statsFolder(ctx context.Context, client *core.Client, url string, stats *core.BlockStats) error {
+	folderToken, err := folderURLToken(url)
+	if err != nil {
+		return err
+	}
+	var processFolder func(folderToken string) error
+	processFolder = func(folderToken string) error {
+		files := client.FolderFiles(folderToken)
+		for files.Next(ctx) {
+			file := files.Item()
+			switch file.Type {
+			case "folder":
+				if err := processFolder(file.Token); err != nil {
+					return err
+				}
+			case "docx":
+				if err := statsDocx(ctx, client, file.Token, stats); err != nil {
+					return fmt.Errorf("%s: %w", file.Name, err)
+				}
+			}
+		}
+		return files.Err()
+	}
+	return processFolder(folderToken)
+}
+
+// statsWiki adds the statistics of the docx documents of a wiki space, or of
+// a wiki node and its descendants
+func statsWiki(ctx context.Context, client *core.Client, url string, stats *core.BlockStats) error {
+	resource, err := core.ParseFeishuURL(url)
+	if err != nil {
+		return err
+	}
+	var spaceID string
+	var parent *string
+	switch resource.Type {
+	case core.ResourceWikiSpace:
+		spaceID = resource.Token
+	case core.ResourceWiki:
+		node, err := client.GetWikiNodeInfo(ctx, resource.Token)
+		if err != nil {
+			return fmt.Errorf("failed to get wiki node info: %w", err)
+		}
+		if node.ObjType == core.ResourceDocx {
+			if err := statsDocx(ctx, client, node.ObjToken, stats); err != nil {
+				return err
+			}
+		}
+		spaceID, parent = node.SpaceID, &resource.Token
+	default:
+		return fmt.Errorf("not a wiki URL: %s", url)
+	}
+
+	tree := fetchWikiTree(ctx, client, spaceID, parent, defaultConcurrency)
+	var processNode func(parent *string) error
+	processNode = func(parent *string) error {
+		nodes, err := tree.list(parent)
+		if err != nil {
+			return err
+		}
+		for _, n := range nodes {
+			if n.ObjType == core.ResourceDocx {
+				if err := statsDocx(ctx, client, n.ObjToken, stats); err != nil {
+					return fmt.Errorf("%s: %w", n.Title, err)
+				}
+			}
+			if n.HasChild {
+				if err := processNode(&n.NodeToken); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return processNode(parent)
+}
+
+// statsCommand prints the statistics of the blocks of the documents
+func statsCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "stats",
+		Usage:     "Count the block types, the images and attachments, and the code languages of the documents",
+		ArgsUsage: "<url|dump.json>",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:        "batch",
+				Usage:       "Count all documents under a folder",
+				Destination: &statsOpts.batch,
+			},
+			&cli.BoolFlag{
+				Name:        "wiki",
+				Usage:       "Count all documents within the wiki, or under the wiki node",
+				Destination: &statsOpts.wiki,
+			},
+			&cli.BoolFlag{
+				Name:        "json",
+				Usage:       "Print the statistics as json",
+				Destination: &statsOpts.json,
+			},
+			&cli.BoolFlag{
+				Name:        "skip-sizes",
+				Usage:       "Skip requesting the sizes of the images and attachments",
+				Destination: &statsOpts.skipSizes,
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if ctx.NArg() == 0 {
+				return cli.Exit("Please specify the document/folder/wiki url or the json dumped with --dump", exitConfigError)
+			}
+			return handleStatsCommand(ctx.Args().First())
+		},
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/Wsine/feishu2md/core"
+)
+
+// loadDocumentTemplate parses the header and footer templates of the config
+// file, the --header and --footer files take precedence
+func loadDocumentTemplate() error {
+	header, footer := dlConfig.Output.HeaderTemplate, dlConfig.Output.FooterTemplate
+	if dlOpts.headerFile != "" {
+		data, err := os.ReadFile(dlOpts.headerFile)
+		if err != nil {
+			return err
+		}
+		header = string(data)
+	}
+	if dlOpts.footerFile != "" {
+		data, err := os.ReadFile(dlOpts.footerFile)
+		if err != nil {
+			return err
+		}
+		footer = string(data)
+	}
+	if header == "" && footer == "" {
+		dlTemplate = nil
+		return nil
+	}
+	tmpl, err := core.NewDocumentTemplate(header, footer)
+	if err != nil {
+		return err
+	}
+	dlTemplate = tmpl
+	return nil
+}
+
+// documentVars returns the template variables of the document written to
+// outputPath, the paths are relative to the root of a batch/wiki download
+func documentVars(url, docToken, title, outputPath string) core.DocumentVars {
+	root := filepath.Dir(outputPath)
+	if dlManifest != nil {
+		root = dlManifest.Root()
+	}
+	path, err := filepath.Rel(root, outputPath)
+	if err != nil {
+		path = filepath.Base(outputPath)
+	}
+	rootPath, err := filepath.Rel(filepath.Dir(outputPath), root)
+	if err != nil {
+		rootPath = "."
+	}
+	return core.DocumentVars{
+		Title:      title,
+		Token:      docToken,
+		URL:        url,
+		Path:       filepath.ToSlash(path),
+		RootPath:   filepath.ToSlash(rootPath),
+		ExportTime: exportTime(),
+	}
+}
+
+// exportTime returns the time of the export, a --deterministic download uses
+// the SOURCE_DATE_EPOCH of the reproducible builds, or an empty string
+func exportTime() string {
+	if !dlOpts.deterministic {
+		return time.Now().Format("2006-01-02 15:04:05")
+	}
+	epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64)
+	if err != nil {
+		return ""
+	}
+	return time.Unix(epoch, 0).UTC().Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/Wsine/feishu2md/utils"
+)
+
+//go:embed ui/index.html
+var uiPage []byte
+
+// convertedDocument is a document converted in memory for the web ui
+type convertedDocument struct {
+	Token    string `json:"token"`
+	Title    string `json:"title"`
+	Markdown string `json:"markdown"`
+	HTML     string `json:"html"`
+	// files maps the links in the markdown to the content of the images and
+	// the attachments
+	files map[string][]byte
+}
+
+// registerUI serves a web page to convert a document and its API
+func registerUI(mux *http.ServeMux, clients *clientPool, apiKeys []string) {
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(uiPage)
+	})
+
+	mux.HandleFunc("/api/convert", requireAPIKey(apiKeys, func(w http.ResponseWriter, r *http.Request) {
+		doc, err := convertRequest(r, clients)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(doc)
+	}))
+
+	mux.HandleFunc("/api/download", requireAPIKey(apiKeys, func(w http.ResponseWriter, r *http.Request) {
+		doc, err := convertRequest(r, clients)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, doc.Token))
+		if err := writeDocumentZip(w, doc); err != nil {
+			log.Printf("failed to write the zip of %s: %v", doc.Token, err)
+		}
+	}))
+}
+
+// convertRequest converts the document of the url query with the options of the request
+func convertRequest(r *http.Request, clients *clientPool) (*convertedDocument, error) {
+	client, _, err := clients.forRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	query := r.URL.Query()
+	// The exports of the jobs and the webhook rewrite dlConfig.Output, the
+	// conversion reads the config file instead
+	config, err := outputConfig("", query.Get("url"))
+	if err != nil {
+		return nil, err
+	}
+	if flavor := query.Get("flavor"); flavor != "" {
+		if _, err := core.ParseFlavor(flavor); err != nil {
+			return nil, err
+		}
+		config.Flavor = flavor
+	}
+	if query.Get("skip_images") == "on" || query.Get("skip_images") == "true" {
+		config.SkipImgDownload = true
+	}
+	return convertDocument(r.Context(), client, query.Get("url"), config)
+}
+
+// convertDocument converts a docx in memory, the images and the attachments are
+// kept in the result rather than written to the disk
+func convertDocument(ctx context.Context, client *core.Client, url string, config core.OutputConfig) (*convertedDocument, error) {
+	docType, docToken, err := utils.ValidateDocumentURL(url)
+	if err != nil {
+		return nil, err
+	}
+	if docType == "wiki" {
+		node, err := client.GetWikiNodeInfo(ctx, docToken)
+		if err != nil {
+			return nil, err
+		}
+		docType = node.ObjType
+		docToken = node.ObjToken
+	}
+	if docType != "docx" {
+		return nil, fmt.Errorf("unsupported document type: %s", docType)
+	}
+
+	doc, err := client.ConvertDocument(ctx, docToken, config)
+	if err != nil {
+		return nil, err
+	}
+	return &convertedDocument{
+		Token:    doc.Token,
+		Title:    doc.Title,
+		Markdown: doc.Markdown,
+		HTML:     renderHTML(doc.Title, doc.Markdown),
+		files:    doc.Files,
+	}, nil
+}
+
+// writeDocumentZip packs the markdown and its files into a zip archive
+func writeDocumentZip(w http.ResponseWriter, doc *convertedDocument) error {
+	writer := zip.NewWriter(w)
+	f, err := writer.Create(doc.Token + ".md")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write([]byte(doc.Markdown)); err != nil {
+		return err
+	}
+	for link, data := range doc.files {
+		f, err := writer.Create(link)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Wsine/feishu2md/core"
+)
+
+// openVectorStore connects to the vector database of the config for
+// --vector, the returned function closes it
+func openVectorStore() (func(), error) {
+	embedding := dlConfig.Vector.Embedding
+	// The embeddings are usually served by the endpoint of the llm
+	if embedding.Endpoint == "" {
+		embedding.Endpoint = dlConfig.LLM.Endpoint
+		embedding.APIKey = dlConfig.LLM.APIKey
+	}
+	if embedding.Endpoint == "" || embedding.Model == "" {
+		return nil, fmt.Errorf("--vector requires the endpoint and the model of vector.embedding in the config file")
+	}
+	store, err := core.NewVectorStore(dlConfig.Vector)
+	if err != nil {
+		return nil, err
+	}
+	dlVector = store
+	dlEmbedder = core.NewEmbedder(embedding)
+	return func() {
+		store.Close()
+		dlVector = nil
+		dlEmbedder = nil
+	}, nil
+}
+
+// pushVectors replaces the chunks of a document in the vector database
+func pushVectors(ctx context.Context, docToken, url, markdown string) error {
+	chunks := documentChunks(docToken, url, markdown)
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.Text
+	}
+	vectors, err := dlEmbedder.Embed(ctx, texts)
+	if err != nil {
+		return err
+	}
+	return dlVector.Replace(ctx, docToken, chunks, vectors)
+}
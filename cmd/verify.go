@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/urfave/cli/v2"
+)
+
+// handleVerifyCommand checks the files of an export against its checksums.txt
+func handleVerifyCommand(root string) error {
+	problems, checked, err := core.VerifyChecksums(root)
+	if err != nil {
+		return err
+	}
+	for _, problem := range problems {
+		fmt.Printf("%s: %s\n", problem.Reason, problem.Path)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d of %d file(s) failed the verification", len(problems), checked)
+	}
+	fmt.Printf("Verified %d file(s)\n", checked)
+	return nil
+}
+
+// verifyCommand verifies the integrity of a backup written with --checksums
+func verifyCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "verify",
+		Usage:     "Verify the files of a download against the checksums.txt written with --checksums",
+		ArgsUsage: "[output directory]",
+		Action: func(ctx *cli.Context) error {
+			root := ctx.Args().First()
+			if root == "" {
+				root = "./"
+			}
+			return handleVerifyCommand(root)
+		},
+	}
+}
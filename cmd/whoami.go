@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Wsine/feishu2md/utils"
+	"github.com/urfave/cli/v2"
+)
+
+// handleWhoamiCommand checks the credentials of the config and prints the
+// result with the hints to fix the failed checks
+func handleWhoamiCommand(url string) error {
+	if err := loadDownloadConfig(); err != nil {
+		return err
+	}
+	var docToken string
+	if url != "" {
+		docType, token, err := utils.ValidateDocumentURL(url)
+		if err != nil {
+			return err
+		}
+		if docType != "docx" {
+			return fmt.Errorf("only the access to a docx document can be checked")
+		}
+		docToken = token
+	}
+
+	fmt.Println("App ID:", dlConfig.Feishu.AppId)
+	client := newClient(dlConfig.Feishu)
+	failed := 0
+	for _, check := range client.CheckCredentials(context.Background(), docToken) {
+		mark := "✓"
+		if !check.OK {
+			mark = "✗"
+			failed++
+		}
+		fmt.Printf("%s %s: %s\n", mark, check.Name, check.Detail)
+		if check.Hint != "" {
+			fmt.Printf("  Hint: %s\n", check.Hint)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+	return nil
+}
+
+// whoamiCommand checks the credentials and the access to a document
+func whoamiCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "whoami",
+		Usage:     "Check the credentials and the permissions of the app, and the access to a document if given",
+		ArgsUsage: "[url]",
+		Action: func(ctx *cli.Context) error {
+			return handleWhoamiCommand(ctx.Args().First())
+		},
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/chyroc/lark"
+)
+
+// wikiTree holds the child nodes of every node of a wiki space, listed
+// concurrently before the download so that the traversal does not wait for
+// the lists level by level
+type wikiTree struct {
+	mu sync.Mutex
+	// lists are the child nodes by the token of their parent, "" for the
+	// top level nodes of the space
+	lists map[string]wikiNodeList
+}
+
+type wikiNodeList struct {
+	nodes []*lark.GetWikiNodeListRespItem
+	err   error
+}
+
+// fetchWikiTree lists the nodes under parent, nil for the whole space, with
+// at most concurrency lists at the same time. A failed list is kept and
+// returned by the list of its parent, its siblings are still listed.
+func fetchWikiTree(ctx context.Context, client *core.Client, spaceID string, parent *string, concurrency int) *wikiTree {
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	tree := &wikiTree{lists: make(map[string]wikiNodeList)}
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var fetch func(parent *string)
+	fetch = func(parent *string) {
+		defer wg.Done()
+		semaphore <- struct{}{}
+		nodes, err := client.GetWikiNodeList(ctx, spaceID, parent)
+		<-semaphore
+
+		tree.mu.Lock()
+		tree.lists[wikiParentKey(parent)] = wikiNodeList{nodes: nodes, err: err}
+		tree.mu.Unlock()
+		for _, n := range nodes {
+			if n.HasChild {
+				wg.Add(1)
+				go fetch(&n.NodeToken)
+			}
+		}
+	}
+	wg.Add(1)
+	fetch(parent)
+	wg.Wait()
+	return tree
+}
+
+func wikiParentKey(parent *string) string {
+	if parent == nil {
+		return ""
+	}
+	return *parent
+}
+
+// list returns the child nodes of parent, nil for the top level nodes
+func (t *wikiTree) list(parent *string) ([]*lark.GetWikiNodeListRespItem, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := t.lists[wikiParentKey(parent)]
+	return list.nodes, list.err
+}
+
+// size returns the number of the nodes listed
+func (t *wikiTree) size() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := 0
+	for _, list := range t.lists {
+		n += len(list.nodes)
+	}
+	return n
+}
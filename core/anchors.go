@@ -0,0 +1,117 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/88250/lute"
+	"github.com/chyroc/lark"
+)
+
+// HeadingAnchor returns the GitHub style anchor of a heading, i.e. the lower
+// case text without the punctuations, the spaces replaced by hyphens. The
+// letters of any language are kept, e.g. "第一章 简介" is "第一章-简介".
+func HeadingAnchor(text string) string {
+	buf := new(strings.Builder)
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsNumber(r), unicode.IsMark(r), r == '-', r == '_':
+			buf.WriteRune(r)
+		case r == ' ':
+			buf.WriteRune('-')
+		}
+	}
+	return buf.String()
+}
+
+// anchorSlugger makes the anchors of a document unique as GitHub does, the
+// duplicated ones are suffixed with -1, -2 and so on
+type anchorSlugger map[string]int
+
+func (s anchorSlugger) slug(text string) string {
+	base := HeadingAnchor(text)
+	anchor := base
+	if n, ok := s[base]; ok {
+		for {
+			n++
+			anchor = fmt.Sprintf("%s-%d", base, n)
+			if _, ok := s[anchor]; !ok {
+				break
+			}
+		}
+		s[base] = n
+	}
+	s[anchor] = 0
+	return anchor
+}
+
+// collectHeadingAnchors assigns the anchors to the title and the headings in
+// the order of the document. The headings below level 6 are not markdown
+// headings and have no anchor.
+func (p *Parser) collectHeadingAnchors(page *lark.DocxBlock) {
+	p.headingAnchors = make(map[string]string)
+	slugger := anchorSlugger{}
+	// The text is spaced as the output is formatted, e.g. "第1章" is "第 1 章"
+	engine := lute.New(func(l *lute.Lute) {
+		l.RenderOptions.AutoSpace = true
+	})
+	var walk func(b *lark.DocxBlock)
+	walk = func(b *lark.DocxBlock) {
+		if b == nil {
+			return
+		}
+		if text := headingText(b); text != nil {
+			p.headingAnchors[b.BlockID] = slugger.slug(engine.Space(plainText(text)))
+		}
+		for _, childID := range b.Children {
+			walk(p.blockMap[childID])
+		}
+	}
+	walk(page)
+}
+
+// headingText returns the text of a title or a heading of level 1 to 6
+func headingText(b *lark.DocxBlock) *lark.DocxBlockText {
+	switch b.BlockType {
+	case lark.DocxBlockTypePage:
+		return b.Page
+	case lark.DocxBlockTypeHeading1:
+		return b.Heading1
+	case lark.DocxBlockTypeHeading2:
+		return b.Heading2
+	case lark.DocxBlockTypeHeading3:
+		return b.Heading3
+	case lark.DocxBlockTypeHeading4:
+		return b.Heading4
+	case lark.DocxBlockTypeHeading5:
+		return b.Heading5
+	case lark.DocxBlockTypeHeading6:
+		return b.Heading6
+	}
+	return nil
+}
+
+// plainText returns the text runs of a text without any style
+func plainText(text *lark.DocxBlockText) string {
+	buf := new(strings.Builder)
+	for _, e := range text.Elements {
+		if e != nil && e.TextRun != nil {
+			buf.WriteString(ReplaceEmojiCodes(e.TextRun.Content))
+		}
+	}
+	return buf.String()
+}
+
+// headingLink returns the anchor of the heading a link jumps to, if the
+// fragment of the link is the block id of a heading of the document, e.g.
+// https://example.feishu.cn/docx/xxx#doxcnAbc or #share-doxcnAbc
+func (p *Parser) headingLink(link string) (string, bool) {
+	u, err := url.Parse(link)
+	if err != nil || u.Fragment == "" {
+		return "", false
+	}
+	anchor, ok := p.headingAnchors[strings.TrimPrefix(u.Fragment, "share-")]
+	return anchor, ok
+}
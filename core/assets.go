@@ -0,0 +1,83 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Wsine/feishu2md/utils"
+	"github.com/chyroc/lark"
+)
+
+// DocumentAsset is an image or an attachment of a docx
+type DocumentAsset struct {
+	Token string
+	// Name is the file name of an attachment, the name of an image comes
+	// with the download
+	Name string
+	// Type is "image" or "file"
+	Type string
+}
+
+// DocumentAssets returns the images and the attachments of the blocks in the
+// order of the blocks
+func DocumentAssets(blocks []*lark.DocxBlock) []DocumentAsset {
+	assets := make([]DocumentAsset, 0)
+	for _, block := range blocks {
+		switch {
+		case block.BlockType == lark.DocxBlockTypeImage && block.Image != nil && block.Image.Token != "":
+			assets = append(assets, DocumentAsset{Token: block.Image.Token, Type: "image"})
+		case block.BlockType == lark.DocxBlockTypeFile && block.File != nil && block.File.Token != "":
+			assets = append(assets, DocumentAsset{Token: block.File.Token, Name: block.File.Name, Type: "file"})
+		}
+	}
+	return assets
+}
+
+// DownloadAssets downloads the assets into the directory with their original
+// file names, and returns the paths of the files. The files sharing a name
+// are suffixed with -2, -3 and so on.
+func (c *Client) DownloadAssets(ctx context.Context, assets []DocumentAsset, dir string) ([]string, error) {
+	used := make(map[string]bool)
+	files := make([]string, 0, len(assets))
+	for _, asset := range assets {
+		resp, _, err := c.lark().Drive.DownloadDriveMedia(ctx, &lark.DownloadDriveMediaReq{
+			FileToken: asset.Token,
+		})
+		if err != nil {
+			return files, fmt.Errorf("failed to download %s %s: %w", asset.Type, asset.Token, err)
+		}
+		name := asset.Name
+		if name == "" {
+			name = resp.Filename
+		}
+		if name == "" {
+			name = asset.Token
+		}
+		filePath := filepath.Join(dir, UniqueFileName(used, utils.SanitizeFileName(name)))
+		content := resp.File
+		if asset.Type == "image" {
+			content = c.imageContent(content)
+		}
+		written, err := DiskAssets{}.WriteAsset(filePath, content)
+		if err != nil {
+			return files, err
+		}
+		c.stats.addDownloadedBytes(written)
+		files = append(files, filePath)
+	}
+	return files, nil
+}
+
+// UniqueFileName returns the name, or the name suffixed with -2, -3 and so on
+// if it is already used, and marks the returned name as used
+func UniqueFileName(used map[string]bool, name string) string {
+	unique := name
+	ext := filepath.Ext(name)
+	for i := 2; used[strings.ToLower(unique)]; i++ {
+		unique = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), i, ext)
+	}
+	used[strings.ToLower(unique)] = true
+	return unique
+}
@@ -0,0 +1,31 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/chyroc/lark"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentAssets(t *testing.T) {
+	blocks := []*lark.DocxBlock{
+		{BlockType: lark.DocxBlockTypePage},
+		{BlockType: lark.DocxBlockTypeImage, Image: &lark.DocxBlockImage{Token: "img1"}},
+		{BlockType: lark.DocxBlockTypeFile, File: &lark.DocxBlockFile{Token: "file1", Name: "报告.pdf"}},
+		{BlockType: lark.DocxBlockTypeImage, Image: &lark.DocxBlockImage{}},
+	}
+	assert.Equal(t, []core.DocumentAsset{
+		{Token: "img1", Type: "image"},
+		{Token: "file1", Name: "报告.pdf", Type: "file"},
+	}, core.DocumentAssets(blocks))
+}
+
+func TestUniqueFileName(t *testing.T) {
+	used := map[string]bool{}
+	assert.Equal(t, "image.png", core.UniqueFileName(used, "image.png"))
+	assert.Equal(t, "image-2.png", core.UniqueFileName(used, "image.png"))
+	assert.Equal(t, "Image-3.png", core.UniqueFileName(used, "Image.png"))
+	assert.Equal(t, "README", core.UniqueFileName(used, "README"))
+	assert.Equal(t, "README-2", core.UniqueFileName(used, "README"))
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Types of the bitable fields that need special formatting
+const (
+	bitableFieldDateTime     = 5
+	bitableFieldCheckbox     = 7
+	bitableFieldAttachment   = 17
+	bitableFieldCreatedTime  = 1001
+	bitableFieldModifiedTime = 1002
+)
+
+// BitableTable is the content of a bitable, the first row is the field names
+type BitableTable struct {
+	Rows [][]string
+	// Attachments are the files of the attachment fields by [row, column] of Rows
+	Attachments map[[2]int][]BitableAttachment
+}
+
+// BitableAttachment is a file of an attachment field
+type BitableAttachment struct {
+	Name      string
+	FileToken string
+}
+
+// bitableAttachments returns the files of an attachment field value
+func bitableAttachments(value interface{}) []BitableAttachment {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+	var files []BitableAttachment
+	for _, item := range items {
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		token, _ := object["file_token"].(string)
+		if token == "" {
+			continue
+		}
+		name, _ := object["name"].(string)
+		files = append(files, BitableAttachment{Name: name, FileToken: token})
+	}
+	return files
+}
+
+// BitableCellText renders the value of a bitable record field as displayed
+// in feishu, e.g. the computed value of a formula or lookup field rather
+// than the raw map of the API
+func BitableCellText(fieldType int64, value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		if fieldType == bitableFieldCheckbox || fieldType == 0 {
+			if v {
+				return "☑"
+			}
+			return "☐"
+		}
+		return strconv.FormatBool(v)
+	case float64:
+		switch fieldType {
+		case bitableFieldDateTime, bitableFieldCreatedTime, bitableFieldModifiedTime:
+			return formatBitableTime(int64(v))
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		return bitableListText(fieldType, v)
+	case map[string]interface{}:
+		return bitableObjectText(fieldType, v)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// formatBitableTime formats a timestamp in milliseconds, omitting the time of a date
+func formatBitableTime(millis int64) string {
+	t := time.UnixMilli(millis)
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+		return t.Format("2006-01-02")
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+func bitableListText(fieldType int64, values []interface{}) string {
+	texts := make([]string, 0, len(values))
+	// 多行文本由多个片段组成，直接拼接
+	segments := true
+	for _, value := range values {
+		if object, ok := value.(map[string]interface{}); !ok || object["type"] == nil || object["text"] == nil {
+			segments = false
+		}
+		if text := BitableCellText(fieldType, value); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	if segments {
+		return strings.Join(texts, "")
+	}
+	return strings.Join(texts, ", ")
+}
+
+func bitableObjectText(fieldType int64, object map[string]interface{}) string {
+	// 公式与查找引用字段返回 {"type": 被引用字段的类型, "value": [...]}
+	if value, ok := object["value"]; ok {
+		if innerType, ok := object["type"].(float64); ok {
+			return BitableCellText(int64(innerType), value)
+		}
+		return BitableCellText(fieldType, value)
+	}
+	for _, key := range []string{"text_arr", "full_address", "text", "name", "link_record_ids"} {
+		if value, ok := object[key]; ok {
+			return BitableCellText(fieldType, value)
+		}
+	}
+	data, err := json.Marshal(object)
+	if err != nil {
+		return fmt.Sprintf("%v", object)
+	}
+	return string(data)
+}
@@ -0,0 +1,33 @@
+package core_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBitableCellText(t *testing.T) {
+	decode := func(s string) interface{} {
+		var v interface{}
+		assert.NoError(t, json.Unmarshal([]byte(s), &v))
+		return v
+	}
+
+	// 公式字段的计算值
+	assert.Equal(t, "42.5", core.BitableCellText(20, decode(`{"type": 2, "value": [42.5]}`)))
+	assert.Equal(t, "张三", core.BitableCellText(20, decode(`{"type": 1, "value": [{"type": "text", "text": "张三"}]}`)))
+	// 查找引用字段
+	assert.Equal(t, "A, B", core.BitableCellText(19, decode(`{"type": 3, "value": ["A", "B"]}`)))
+	// 多行文本与人员
+	assert.Equal(t, "见 链接", core.BitableCellText(1, decode(`[{"type": "text", "text": "见 "}, {"type": "url", "text": "链接", "link": "https://example.com"}]`)))
+	assert.Equal(t, "张三, 李四", core.BitableCellText(11, decode(`[{"id": "ou_1", "name": "张三"}, {"id": "ou_2", "name": "李四"}]`)))
+	// 自动编号、数字与复选框
+	assert.Equal(t, "NO.001", core.BitableCellText(1005, "NO.001"))
+	assert.Equal(t, "3", core.BitableCellText(2, decode(`3`)))
+	assert.Equal(t, "☑", core.BitableCellText(7, true))
+	// 双向关联与地理位置
+	assert.Equal(t, "任务一", core.BitableCellText(21, decode(`{"link_record_ids": ["rec1"], "text_arr": ["任务一"]}`)))
+	assert.Equal(t, "北京市海淀区", core.BitableCellText(22, decode(`{"full_address": "北京市海淀区", "location": "116.3,39.9"}`)))
+}
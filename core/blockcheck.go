@@ -0,0 +1,82 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/chyroc/lark"
+)
+
+// BlockCount compares the blocks rendered into the markdown with the blocks
+// returned by the API, a block type released after the SDK is skipped
+// silently otherwise
+type BlockCount struct {
+	Total    int
+	Rendered int
+	// IgnoredTypes counts the blocks without a renderer by block type
+	IgnoredTypes map[int64]int
+}
+
+// Ignored returns the number of the blocks missing from the markdown, either
+// of an unsupported type or never reached from the page
+func (c BlockCount) Ignored() int {
+	return c.Total - c.Rendered
+}
+
+// IgnoredRatio returns the ratio of the ignored blocks to all the blocks
+func (c BlockCount) IgnoredRatio() float64 {
+	if c.Total == 0 {
+		return 0
+	}
+	return float64(c.Ignored()) / float64(c.Total)
+}
+
+func (c BlockCount) String() string {
+	s := fmt.Sprintf("rendered %d of %d blocks, %d ignored", c.Rendered, c.Total, c.Ignored())
+	if len(c.IgnoredTypes) == 0 {
+		return s
+	}
+	types := make([]int64, 0, len(c.IgnoredTypes))
+	for blockType := range c.IgnoredTypes {
+		types = append(types, blockType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	parts := make([]string, 0, len(types))
+	for _, blockType := range types {
+		parts = append(parts, fmt.Sprintf("type %d ×%d", blockType, c.IgnoredTypes[blockType]))
+	}
+	return s + " (" + strings.Join(parts, ", ") + ")"
+}
+
+// BlockCount returns the blocks rendered by the last parse of a document.
+// The blocks removed on purpose, such as the trailing blocks, count as rendered.
+func (p *Parser) BlockCount() BlockCount {
+	count := BlockCount{Total: len(p.blockMap), IgnoredTypes: make(map[int64]int)}
+	for id := range p.blockMap {
+		if p.renderedBlocks[id] {
+			count.Rendered++
+		}
+	}
+	for blockType, n := range p.ignoredTypes {
+		count.IgnoredTypes[blockType] = n
+	}
+	return count
+}
+
+// markRendered records a block as rendered, with its descendants if subtree
+// is set, for the blocks consumed by their parents or removed on purpose
+func (p *Parser) markRendered(blockID string, subtree bool) {
+	p.renderedBlocks[blockID] = true
+	if b := p.blockMap[blockID]; subtree && b != nil {
+		for _, childID := range b.Children {
+			p.markRendered(childID, true)
+		}
+	}
+}
+
+// ignoreBlock records a block of a type without a renderer
+func (p *Parser) ignoreBlock(b *lark.DocxBlock) {
+	delete(p.renderedBlocks, b.BlockID)
+	p.ignoredTypes[int64(b.BlockType)]++
+}
@@ -0,0 +1,212 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/chyroc/lark"
+)
+
+// blockTypeNames are the names of the block types in the statistics
+var blockTypeNames = map[lark.DocxBlockType]string{
+	lark.DocxBlockTypePage:           "page",
+	lark.DocxBlockTypeText:           "text",
+	lark.DocxBlockTypeHeading1:       "heading1",
+	lark.DocxBlockTypeHeading2:       "heading2",
+	lark.DocxBlockTypeHeading3:       "heading3",
+	lark.DocxBlockTypeHeading4:       "heading4",
+	lark.DocxBlockTypeHeading5:       "heading5",
+	lark.DocxBlockTypeHeading6:       "heading6",
+	lark.DocxBlockTypeHeading7:       "heading7",
+	lark.DocxBlockTypeHeading8:       "heading8",
+	lark.DocxBlockTypeHeading9:       "heading9",
+	lark.DocxBlockTypeBullet:         "bullet",
+	lark.DocxBlockTypeOrdered:        "ordered",
+	lark.DocxBlockTypeCode:           "code",
+	lark.DocxBlockTypeQuote:          "quote",
+	lark.DocxBlockTypeEquation:       "equation",
+	lark.DocxBlockTypeTodo:           "todo",
+	lark.DocxBlockTypeBitable:        "bitable",
+	lark.DocxBlockTypeCallout:        "callout",
+	lark.DocxBlockTypeChatCard:       "chat_card",
+	lark.DocxBlockTypeDiagram:        "diagram",
+	lark.DocxBlockTypeDivider:        "divider",
+	lark.DocxBlockTypeFile:           "file",
+	lark.DocxBlockTypeGrid:           "grid",
+	lark.DocxBlockTypeGridColumn:     "grid_column",
+	lark.DocxBlockTypeIframe:         "iframe",
+	lark.DocxBlockTypeImage:          "image",
+	lark.DocxBlockTypeISV:            "isv",
+	lark.DocxBlockTypeMindnote:       "mindnote",
+	lark.DocxBlockTypeSheet:          "sheet",
+	lark.DocxBlockTypeTable:          "table",
+	lark.DocxBlockTypeTableCell:      "table_cell",
+	lark.DocxBlockTypeView:           "view",
+	lark.DocxBlockTypeQuoteContainer: "quote_container",
+	lark.DocxBlockTypeTask:           "task",
+	lark.DocxBlockTypeOKR:            "okr",
+	lark.DocxBlockTypeOKRObjective:   "okr_objective",
+	lark.DocxBlockTypeOKRKeyResult:   "okr_key_result",
+	lark.DocxBlockTypeProgress:       "progress",
+	lark.DocxBlockTypeUndefined:      "undefined",
+}
+
+// BlockTypeName returns the name of a block type, e.g. "heading1"
+func BlockTypeName(blockType lark.DocxBlockType) string {
+	if name, ok := blockTypeNames[blockType]; ok {
+		return name
+	}
+	return fmt.Sprintf("type_%d", blockType)
+}
+
+// BlockStats counts the block types, the sizes of the images and the
+// attachments, and the languages of the code blocks of documents. It is safe
+// to use concurrently.
+type BlockStats struct {
+	mu            sync.Mutex
+	Documents     int            `json:"documents"`
+	Blocks        int            `json:"blocks"`
+	BlockTypes    map[string]int `json:"block_types"`
+	Images        int            `json:"images"`
+	ImageBytes    int64          `json:"image_bytes"`
+	Files         int            `json:"files"`
+	FileBytes     int64          `json:"file_bytes"`
+	CodeLanguages map[string]int `json:"code_languages"`
+	// Sized is set once the sizes of the assets are added
+	Sized bool `json:"sized"`
+}
+
+func NewBlockStats() *BlockStats {
+	return &BlockStats{BlockTypes: make(map[string]int), CodeLanguages: make(map[string]int)}
+}
+
+// Add counts the blocks of a document
+func (s *BlockStats) Add(blocks []*lark.DocxBlock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Documents++
+	for _, block := range blocks {
+		s.Blocks++
+		s.BlockTypes[BlockTypeName(block.BlockType)]++
+		switch {
+		case block.BlockType == lark.DocxBlockTypeImage:
+			s.Images++
+		case block.BlockType == lark.DocxBlockTypeFile:
+			s.Files++
+		case block.BlockType == lark.DocxBlockTypeCode && block.Code != nil:
+			language := "plaintext"
+			if block.Code.Style != nil {
+				if lang, ok := DocxCodeLang2MdStr[block.Code.Style.Language]; ok && lang != "" {
+					language = lang
+				} else if !ok {
+					language = fmt.Sprintf("language_%d", block.Code.Style.Language)
+				}
+			}
+			s.CodeLanguages[language]++
+		}
+	}
+}
+
+// AddAssetSize adds the size of an image or an attachment, assetType is
+// "image" or "file"
+func (s *BlockStats) AddAssetSize(assetType string, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Sized = true
+	if assetType == "image" {
+		s.ImageBytes += size
+	} else {
+		s.FileBytes += size
+	}
+}
+
+// String renders the statistics as plain text, the counts sorted in
+// descending order
+func (s *BlockStats) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buf := new(strings.Builder)
+	buf.WriteString(fmt.Sprintf("Documents: %d\n", s.Documents))
+	buf.WriteString(fmt.Sprintf("Blocks: %d\n", s.Blocks))
+	writeCounts(buf, s.BlockTypes)
+	if s.Sized {
+		buf.WriteString(fmt.Sprintf("Images: %d, %s\n", s.Images, formatBytes(s.ImageBytes)))
+		buf.WriteString(fmt.Sprintf("Attachments: %d, %s\n", s.Files, formatBytes(s.FileBytes)))
+	} else {
+		buf.WriteString(fmt.Sprintf("Images: %d\n", s.Images))
+		buf.WriteString(fmt.Sprintf("Attachments: %d\n", s.Files))
+	}
+	if len(s.CodeLanguages) > 0 {
+		buf.WriteString("Code languages:\n")
+		writeCounts(buf, s.CodeLanguages)
+	}
+	return buf.String()
+}
+
+func writeCounts(buf *strings.Builder, counts map[string]int) {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		buf.WriteString(fmt.Sprintf("  %-20s %8d\n", name, counts[name]))
+	}
+}
+
+// formatBytes formats a size in the binary units, e.g. 1.5 MiB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// driveMediaDownloadURL is the API to download an image or an attachment of
+// a document
+const driveMediaDownloadURL = "https://open.feishu.cn/open-apis/drive/v1/medias/%s/download"
+
+// MediaSize returns the size of an image or an attachment of a document
+// without downloading it, with a request of its first byte
+func (c *Client) MediaSize(ctx context.Context, token string) (int64, error) {
+	accessToken, err := c.accessToken(ctx, c.lark())
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(driveMediaDownloadURL, token), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Range", "bytes=0-0")
+	resp, err := (&http.Client{Timeout: defaultTimeout}).Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		// Content-Range: bytes 0-0/12345
+		_, total, _ := strings.Cut(resp.Header.Get("Content-Range"), "/")
+		return strconv.ParseInt(total, 10, 64)
+	case http.StatusOK:
+		return resp.ContentLength, nil
+	}
+	return 0, fmt.Errorf("failed to get the size of %s: %s", token, resp.Status)
+}
@@ -0,0 +1,48 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/chyroc/lark"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockStats(t *testing.T) {
+	code := func(language lark.DocxCodeLanguage) *lark.DocxBlock {
+		return &lark.DocxBlock{
+			BlockType: lark.DocxBlockTypeCode,
+			Code:      &lark.DocxBlockText{Style: &lark.DocxTextStyle{Language: language}},
+		}
+	}
+	stats := core.NewBlockStats()
+	stats.Add([]*lark.DocxBlock{
+		{BlockType: lark.DocxBlockTypePage},
+		{BlockType: lark.DocxBlockTypeText},
+		{BlockType: lark.DocxBlockTypeText},
+		{BlockType: lark.DocxBlockTypeImage, Image: &lark.DocxBlockImage{Token: "img1"}},
+		code(lark.DocxCodeLanguageGo),
+		code(lark.DocxCodeLanguagePlainText),
+	})
+	stats.Add([]*lark.DocxBlock{
+		{BlockType: lark.DocxBlockTypePage},
+		{BlockType: lark.DocxBlockTypeFile, File: &lark.DocxBlockFile{Token: "file1"}},
+		{BlockType: 999},
+		code(lark.DocxCodeLanguageGo),
+	})
+
+	assert.Equal(t, 2, stats.Documents)
+	assert.Equal(t, 10, stats.Blocks)
+	assert.Equal(t, map[string]int{"page": 2, "text": 2, "image": 1, "file": 1, "code": 3, "undefined": 1}, stats.BlockTypes)
+	assert.Equal(t, map[string]int{"go": 2, "plaintext": 1}, stats.CodeLanguages)
+	assert.Equal(t, 1, stats.Images)
+	assert.Equal(t, 1, stats.Files)
+	assert.Contains(t, stats.String(), "Images: 1\n")
+
+	stats.AddAssetSize("image", 1536)
+	stats.AddAssetSize("file", 100)
+	assert.Equal(t, int64(1536), stats.ImageBytes)
+	assert.Contains(t, stats.String(), "Images: 1, 1.5 KiB\n")
+	assert.Contains(t, stats.String(), "Attachments: 1, 100 B\n")
+	assert.Equal(t, "type_1000", core.BlockTypeName(1000))
+}
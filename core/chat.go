@@ -0,0 +1,68 @@
+package core
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/Wsine/feishu2md/utils"
+	"github.com/chyroc/lark"
+)
+
+// messageURLRegexp matches the urls in the json content of a message
+var messageURLRegexp = regexp.MustCompile(`https?://[^\s"'<>\\()\[\]]+`)
+
+// DocumentURLs returns the urls of the docx and the wiki documents in the
+// content of a message, each document once
+func DocumentURLs(content string) []string {
+	content = strings.ReplaceAll(content, `\/`, "/")
+	urls := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, url := range messageURLRegexp.FindAllString(content, -1) {
+		docType, docToken, err := utils.ValidateDocumentURL(url)
+		if err != nil || (docType != "docx" && docType != "wiki") || seen[docToken] {
+			continue
+		}
+		seen[docToken] = true
+		urls = append(urls, url)
+	}
+	return urls
+}
+
+// GetChatDocumentURLs returns the urls of the documents shared in the
+// messages of a chat from the oldest, each document once. The app must be a
+// member of the chat.
+func (c *Client) GetChatDocumentURLs(ctx context.Context, chatID string) ([]string, error) {
+	urls := make([]string, 0)
+	seen := make(map[string]bool)
+	pageSize := int64(50)
+	var pageToken *string
+	for {
+		// Only the main app is a member of the chat
+		resp, _, err := c.larkClient.Message.GetMessageList(ctx, &lark.GetMessageListReq{
+			ContainerIDType: lark.ContainerIDTypeChat,
+			ContainerID:     chatID,
+			PageSize:        &pageSize,
+			PageToken:       pageToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range resp.Items {
+			if item.Deleted || item.Body == nil {
+				continue
+			}
+			for _, url := range DocumentURLs(item.Body.Content) {
+				_, docToken, _ := utils.ValidateDocumentURL(url)
+				if !seen[docToken] {
+					seen[docToken] = true
+					urls = append(urls, url)
+				}
+			}
+		}
+		if !resp.HasMore || resp.PageToken == "" || (pageToken != nil && *pageToken == resp.PageToken) {
+			return urls, nil
+		}
+		pageToken = &resp.PageToken
+	}
+}
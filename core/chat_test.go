@@ -0,0 +1,18 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentURLs(t *testing.T) {
+	content := `{"title":"周报","content":[[{"tag":"a","href":"https:\/\/example.feishu.cn\/docx\/AbC123?from=chat","text":"设计文档"}],` +
+		`[{"tag":"text","text":"见 https://example.feishu.cn/wiki/WiKi456 和 https://example.feishu.cn/docx/AbC123"}],` +
+		`[{"tag":"text","text":"https://example.feishu.cn/drive/folder/Fold789 https://example.com/about"}]]}`
+	assert.Equal(t, []string{
+		"https://example.feishu.cn/docx/AbC123?from=chat",
+		"https://example.feishu.cn/wiki/WiKi456",
+	}, core.DocumentURLs(content))
+}
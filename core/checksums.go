@@ -0,0 +1,97 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ChecksumsFileName is the SHA256 list of the files of an export, in the
+// format of sha256sum so that `sha256sum -c checksums.txt` works as well
+const ChecksumsFileName = "checksums.txt"
+
+// Checksum problems reported by VerifyChecksums
+const (
+	ChecksumMissing  = "missing"
+	ChecksumMismatch = "mismatch"
+)
+
+// ChecksumProblem is a file of checksums.txt missing or changed
+type ChecksumProblem struct {
+	Path   string
+	Reason string
+}
+
+// WriteChecksums writes the checksums of the files of the manifest and of
+// the manifest itself into checksums.txt in the root directory. The
+// manifest must be written first so that the checksums are up to date,
+// the tracked files missing locally are left out.
+func (m *Manifest) WriteChecksums() error {
+	m.mu.Lock()
+	sums := make(map[string]string, len(m.Entries)+1)
+	for _, entry := range m.Entries {
+		if entry.SHA256 != "" {
+			sums[entry.Path] = entry.SHA256
+		}
+	}
+	m.mu.Unlock()
+	sum, err := ManifestChecksum(m.root)
+	if err != nil {
+		return err
+	}
+	if sum != "" {
+		sums[ManifestFileName] = sum
+	}
+
+	paths := make([]string, 0, len(sums))
+	for path := range sums {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	buf := new(strings.Builder)
+	for _, path := range paths {
+		fmt.Fprintf(buf, "%s  %s\n", sums[path], path)
+	}
+	return os.WriteFile(filepath.Join(m.root, ChecksumsFileName), []byte(buf.String()), 0o644)
+}
+
+// VerifyChecksums checks the files listed in checksums.txt of the root
+// directory, the files missing or whose content changed are returned with
+// the number of the files checked
+func VerifyChecksums(root string) ([]ChecksumProblem, int, error) {
+	file, err := os.Open(filepath.Join(root, ChecksumsFileName))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	problems := make([]ChecksumProblem, 0)
+	checked := 0
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		sum, path, ok := strings.Cut(text, "  ")
+		if !ok || len(sum) != 64 {
+			return nil, checked, fmt.Errorf("invalid line %d of %s: %s", line, ChecksumsFileName, text)
+		}
+		checked++
+		actual, err := fileChecksum(filepath.Join(root, filepath.FromSlash(path)))
+		if os.IsNotExist(err) {
+			problems = append(problems, ChecksumProblem{Path: path, Reason: ChecksumMissing})
+			continue
+		}
+		if err != nil {
+			return nil, checked, err
+		}
+		if !strings.EqualFold(actual, sum) {
+			problems = append(problems, ChecksumProblem{Path: path, Reason: ChecksumMismatch})
+		}
+	}
+	return problems, checked, scanner.Err()
+}
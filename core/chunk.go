@@ -0,0 +1,184 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Chunk is a piece of a document for the retrieval of a LLM, written as a
+// line of the jsonl format
+type Chunk struct {
+	DocID string `json:"doc_id"`
+	// HeadingPath are the headings the chunk is under, from the title
+	HeadingPath []string `json:"heading_path"`
+	Text        string   `json:"text"`
+	URL         string   `json:"url"`
+}
+
+var chunkHeadingRegexp = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+
+// chunkSection is the content under a heading
+type chunkSection struct {
+	headingPath []string
+	paragraphs  []string
+}
+
+// ChunkMarkdown splits a markdown document into chunks of at most size
+// runes. The document is split by its headings first, the paragraphs of a
+// section are then packed into chunks and a paragraph longer than size is
+// cut. A chunk starts with the last overlap runes of the previous chunk of
+// its section. The front matter is skipped and the headings inside the code
+// fences are ignored.
+func ChunkMarkdown(markdown string, size, overlap int) []Chunk {
+	if size <= 0 {
+		size = 1000
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+	chunks := make([]Chunk, 0)
+	for _, section := range chunkSections(markdown) {
+		for _, text := range packParagraphs(section.paragraphs, size, overlap) {
+			chunks = append(chunks, Chunk{HeadingPath: section.headingPath, Text: text})
+		}
+	}
+	return chunks
+}
+
+// chunkSections splits the markdown by the headings outside of the code
+// fences, the paragraphs are separated by the blank lines
+func chunkSections(markdown string) []chunkSection {
+	// The front matter is metadata rather than content
+	if strings.HasPrefix(markdown, "---\n") {
+		if end := strings.Index(markdown[4:], "\n---\n"); end >= 0 {
+			markdown = markdown[4+end+5:]
+		}
+	}
+
+	sections := make([]chunkSection, 0)
+	current := chunkSection{headingPath: []string{}}
+	levels := make([]int, 0)
+	paragraph := new(strings.Builder)
+	flushParagraph := func() {
+		if text := strings.TrimSpace(paragraph.String()); text != "" {
+			current.paragraphs = append(current.paragraphs, text)
+		}
+		paragraph.Reset()
+	}
+	fence := ""
+	for _, line := range strings.SplitAfter(markdown, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			paragraph.WriteString(line)
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+			}
+			continue
+		}
+		if marker := fenceMarker(trimmed); marker != "" {
+			fence = marker
+			paragraph.WriteString(line)
+			continue
+		}
+		if match := chunkHeadingRegexp.FindStringSubmatch(trimmed); match != nil && !strings.HasPrefix(line, " ") {
+			flushParagraph()
+			if len(current.paragraphs) > 0 {
+				sections = append(sections, current)
+			}
+			level := len(match[1])
+			path := current.headingPath
+			for len(levels) > 0 && levels[len(levels)-1] >= level {
+				levels = levels[:len(levels)-1]
+				path = path[:len(path)-1]
+			}
+			levels = append(levels, level)
+			// A new slice, the previous sections share the prefix
+			current = chunkSection{headingPath: append(append([]string{}, path...), match[2])}
+			continue
+		}
+		if trimmed == "" {
+			flushParagraph()
+			continue
+		}
+		paragraph.WriteString(line)
+	}
+	flushParagraph()
+	if len(current.paragraphs) > 0 {
+		sections = append(sections, current)
+	}
+	return sections
+}
+
+// packParagraphs joins the paragraphs into texts of at most size runes
+func packParagraphs(paragraphs []string, size, overlap int) []string {
+	texts := make([]string, 0)
+	current := ""
+	// fresh is set when current holds nothing but the overlap
+	fresh := true
+	emit := func() {
+		if !fresh {
+			texts = append(texts, current)
+			current = tailRunes(current, overlap)
+			fresh = true
+		}
+	}
+	for _, paragraph := range paragraphs {
+		joined := paragraph
+		if current != "" {
+			joined = current + "\n\n" + paragraph
+		}
+		if runeCount(joined) <= size {
+			current, fresh = joined, false
+			continue
+		}
+		emit()
+		joined = paragraph
+		if current != "" {
+			joined = current + "\n\n" + paragraph
+		}
+		if runeCount(joined) <= size {
+			current, fresh = joined, false
+			continue
+		}
+		// Cut the long paragraph into windows, each one starting with the
+		// overlap of the previous one
+		runes := []rune(joined)
+		for len(runes) > size {
+			texts = append(texts, string(runes[:size]))
+			runes = runes[size-overlap:]
+		}
+		current, fresh = string(runes), false
+	}
+	emit()
+	return texts
+}
+
+func runeCount(s string) int {
+	return len([]rune(s))
+}
+
+// tailRunes returns the last n runes of s
+func tailRunes(s string, n int) string {
+	runes := []rune(s)
+	if n <= 0 {
+		return ""
+	}
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[len(runes)-n:])
+}
+
+// WriteChunks writes the chunks as json lines
+func WriteChunks(w io.Writer, chunks []Chunk) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, chunk := range chunks {
+		if err := encoder.Encode(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
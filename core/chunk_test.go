@@ -0,0 +1,46 @@
+package core_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkMarkdown(t *testing.T) {
+	markdown := "---\ntitle: 手册\n---\n# 手册\n\n简介\n\n## 安装\n\n第一段\n\n```sh\n# not a heading\n```\n\n### 依赖\n\n" +
+		strings.Repeat("依", 70) + "\n\n## 使用\n\n最后一段\n"
+	chunks := core.ChunkMarkdown(markdown, 30, 2)
+	texts := make([]string, 0)
+	paths := make([]string, 0)
+	for _, chunk := range chunks {
+		texts = append(texts, chunk.Text)
+		paths = append(paths, strings.Join(chunk.HeadingPath, " > "))
+	}
+	assert.Equal(t, []string{
+		"简介",
+		"第一段\n\n```sh\n# not a heading\n```",
+		strings.Repeat("依", 30),
+		strings.Repeat("依", 30),
+		strings.Repeat("依", 14),
+		"最后一段",
+	}, texts)
+	assert.Equal(t, []string{
+		"手册",
+		"手册 > 安装",
+		"手册 > 安装 > 依赖",
+		"手册 > 安装 > 依赖",
+		"手册 > 安装 > 依赖",
+		"手册 > 使用",
+	}, paths)
+}
+
+func TestWriteChunks(t *testing.T) {
+	buf := new(bytes.Buffer)
+	assert.NoError(t, core.WriteChunks(buf, []core.Chunk{
+		{DocID: "doxcn", HeadingPath: []string{"标题"}, Text: "a < b", URL: "https://sample.feishu.cn/docx/doxcn"},
+	}))
+	assert.Equal(t, `{"doc_id":"doxcn","heading_path":["标题"],"text":"a < b","url":"https://sample.feishu.cn/docx/doxcn"}`+"\n", buf.String())
+}
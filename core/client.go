@@ -1,6 +1,7 @@
 package core
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -10,6 +11,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/chyroc/lark"
@@ -17,56 +20,217 @@ import (
 )
 
 type Client struct {
-	larkClient *lark.Lark
+	// larkClient is the client of the main app, larkClients are the clients
+	// of all the apps whose requests are rotated, see WithAppCredentials
+	larkClient  *lark.Lark
+	larkClients []*lark.Lark
+	nextClient  atomic.Uint64
+	// rateLimits are the rate limits of the apps by their lark clients
+	rateLimits map[*lark.Lark]lark.ApiMiddleware
+	// httpClient makes the requests without the lark client, e.g. the
+	// resumable downloads, with the configured headers and no timeout of
+	// the whole request
+	httpClient *headerHTTPClient
+	stats      *Stats
+	tracer     *tracer
+	titleCache sync.Map
+	// imageCaptions are the captions of the image blocks by block id, the
+	// lark SDK does not decode them
+	imageCaptions sync.Map
+	// tableHeaders are the header settings of the table blocks by block id
+	tableHeaders sync.Map
+	// shortLinks are the targets of the expanded short links
+	shortLinks sync.Map
+	verbose    io.Writer
+	// userAccessToken makes the requests on behalf of a user if set
+	userAccessToken string
+	// images names the downloaded images
+	images imageNamer
+	// stripEXIF removes the metadata of the downloaded images
+	stripEXIF atomic.Bool
 }
 
-func NewClient(appID, appSecret string) *Client {
-	return &Client{
-		larkClient: lark.New(
+// maxDocxBlockPages limits the pages of the blocks of a document, a page has
+// at most 500 blocks
+const maxDocxBlockPages = 1000
+
+type clientOptions struct {
+	lark []lark.ClientOptionFunc
+	// apps are the credentials of the other apps
+	apps []AppCredential
+	// userAgent and headers are set on every request, see WithRequestHeaders
+	userAgent string
+	headers   map[string]string
+}
+
+// ClientOption configures the client and the underlying lark clients
+type ClientOption func(options *clientOptions)
+
+// WithEventCallback sets the encrypt key and verification token to receive event callbacks
+func WithEventCallback(encryptKey, verificationToken string) ClientOption {
+	return func(options *clientOptions) {
+		options.lark = append(options.lark, lark.WithEventCallbackVerify(encryptKey, verificationToken))
+	}
+}
+
+// WithAppCredentials adds the credentials of other apps, the requests are
+// rotated among the apps, each with its own rate limit. The apps must all
+// have the permissions to read the documents.
+func WithAppCredentials(apps []AppCredential) ClientOption {
+	return func(options *clientOptions) {
+		options.apps = append(options.apps, apps...)
+	}
+}
+
+// WithRequestHeaders sets the User-Agent and the extra headers of every
+// request, e.g. for an enterprise gateway to identify and audit the requests
+func WithRequestHeaders(userAgent string, headers map[string]string) ClientOption {
+	return func(options *clientOptions) {
+		if userAgent == "" && len(headers) == 0 {
+			return
+		}
+		options.userAgent, options.headers = userAgent, headers
+		options.lark = append(options.lark, lark.WithHttpClient(&headerHTTPClient{
+			client:    &http.Client{Timeout: defaultTimeout},
+			userAgent: userAgent,
+			headers:   headers,
+		}))
+	}
+}
+
+// headerHTTPClient adds the headers to the requests of the lark client
+type headerHTTPClient struct {
+	client    *http.Client
+	userAgent string
+	headers   map[string]string
+}
+
+func (c *headerHTTPClient) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	return c.client.Do(req.WithContext(ctx))
+}
+
+// defaultTimeout is the timeout of the requests
+const defaultTimeout = 60 * time.Second
+
+func NewClient(appID, appSecret string, opts ...ClientOption) *Client {
+	c := &Client{
+		stats:  newStats(),
+		tracer: &tracer{},
+	}
+	options := clientOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	c.rateLimits = make(map[*lark.Lark]lark.ApiMiddleware)
+	c.httpClient = &headerHTTPClient{
+		// A large file takes long on a slow network
+		client: &http.Client{Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			ResponseHeaderTimeout: defaultTimeout,
+		}},
+		userAgent: options.userAgent,
+		headers:   options.headers,
+	}
+	newLark := func(appID, appSecret string) *lark.Lark {
+		rateLimit := lark_rate_limiter.Wait(4, 4)
+		larkClient := lark.New(append([]lark.ClientOptionFunc{
 			lark.WithAppCredential(appID, appSecret),
-			lark.WithTimeout(60*time.Second),
-			lark.WithApiMiddleware(lark_rate_limiter.Wait(4, 4)),
-		),
+			lark.WithTimeout(defaultTimeout),
+			lark.WithApiMiddleware(rateLimit, c.stats.middleware, c.tracer.middleware, c.userTokenMiddleware),
+		}, options.lark...)...)
+		c.rateLimits[larkClient] = rateLimit
+		return larkClient
+	}
+	c.larkClient = newLark(appID, appSecret)
+	c.larkClients = []*lark.Lark{c.larkClient}
+	added := map[string]bool{appID: true}
+	for _, app := range options.apps {
+		if !added[app.AppID] {
+			added[app.AppID] = true
+			c.larkClients = append(c.larkClients, newLark(app.AppID, app.AppSecret))
+		}
+	}
+	return c
+}
+
+// lark returns the client of the next app in turn
+func (c *Client) lark() *lark.Lark {
+	if len(c.larkClients) == 1 {
+		return c.larkClient
+	}
+	return c.larkClients[(c.nextClient.Add(1)-1)%uint64(len(c.larkClients))]
+}
+
+// Apps returns the number of the apps whose requests are rotated
+func (c *Client) Apps() int {
+	return len(c.larkClients)
+}
+
+// SetVerbose prints the progress of the requests into w
+func (c *Client) SetVerbose(w io.Writer) {
+	c.verbose = w
+}
+
+func (c *Client) logf(format string, args ...interface{}) {
+	if c.verbose != nil {
+		fmt.Fprintf(c.verbose, format, args...)
 	}
 }
 
+// Stats returns the metrics of the API calls made by the client
+func (c *Client) Stats() *Stats {
+	return c.stats
+}
+
 func (c *Client) DownloadImage(ctx context.Context, imgToken, outDir string) (string, error) {
-	resp, _, err := c.larkClient.Drive.DownloadDriveMedia(ctx, &lark.DownloadDriveMediaReq{
+	resp, _, err := c.lark().Drive.DownloadDriveMedia(ctx, &lark.DownloadDriveMediaReq{
 		FileToken: imgToken,
 	})
 	if err != nil {
 		return imgToken, err
 	}
-	fileext := filepath.Ext(resp.Filename)
-	filename := fmt.Sprintf("%s/%s%s", outDir, imgToken, fileext)
+	// The head of the content recognizes an svg without an extension
+	content := bufio.NewReader(c.imageContent(resp.File))
+	head, _ := content.Peek(512)
+	filename := c.imagePath(outDir, imgToken, resp.Filename, head)
 	err = os.MkdirAll(filepath.Dir(filename), 0o755)
 	if err != nil {
 		return imgToken, err
 	}
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY, 0o666)
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o666)
 	if err != nil {
 		return imgToken, err
 	}
 	defer file.Close()
-	_, err = io.Copy(file, resp.File)
+	written, err := io.Copy(file, content)
 	if err != nil {
 		return imgToken, err
 	}
+	c.stats.addDownloadedBytes(written)
 	return filename, nil
 }
 
 func (c *Client) DownloadImageRaw(ctx context.Context, imgToken, imgDir string) (string, []byte, error) {
-	resp, _, err := c.larkClient.Drive.DownloadDriveMedia(ctx, &lark.DownloadDriveMediaReq{
+	resp, _, err := c.lark().Drive.DownloadDriveMedia(ctx, &lark.DownloadDriveMediaReq{
 		FileToken: imgToken,
 	})
 	if err != nil {
 		return imgToken, nil, err
 	}
-	fileext := filepath.Ext(resp.Filename)
-	filename := fmt.Sprintf("%s/%s%s", imgDir, imgToken, fileext)
 	buf := new(bytes.Buffer)
-	buf.ReadFrom(resp.File)
-	return filename, buf.Bytes(), nil
+	written, _ := buf.ReadFrom(resp.File)
+	c.stats.addDownloadedBytes(written)
+	data := buf.Bytes()
+	if c.stripEXIF.Load() {
+		data = StripEXIF(data)
+	}
+	return c.imagePath(imgDir, imgToken, resp.Filename, data), data, nil
 }
 
 // DownloadFile downloads any file from Feishu Drive (including mindnote, video, etc.)
@@ -79,39 +243,26 @@ func (c *Client) DownloadImageRaw(ctx context.Context, imgToken, imgDir string)
 // For file objects (mindnote, file, sheet, bitable), we should use DownloadDriveFile
 // For media blocks inside documents, we should use DownloadDriveMedia
 func (c *Client) DownloadFile(ctx context.Context, fileToken, outDir, objType, title string) (string, error) {
-	var (
-		file     io.Reader
-		filename string
-		err      error
-	)
-
-	// Try DownloadDriveFile first for standalone files (mindnote, video, PDF, etc.)
-	// This is the correct API for downloading files from cloud drive
-	resp, _, err := c.larkClient.Drive.DownloadDriveFile(ctx, &lark.DownloadDriveFileReq{
+	// Try the drive file download first for standalone files (mindnote, video, PDF, etc.)
+	// This is the correct API for downloading files from cloud drive, an
+	// interrupted download is resumed with Range requests
+	filePath, err := c.downloadResumable(ctx, fileToken, outDir)
+	if err == nil {
+		return filePath, nil
+	}
+	c.logf("Failed to download file %s: %v\n", fileToken, err)
+
+	// If the file download fails, try DownloadDriveMedia as fallback
+	// This handles the case where the file is actually a media resource inside a document
+	mediaResp, _, mediaErr := c.lark().Drive.DownloadDriveMedia(ctx, &lark.DownloadDriveMediaReq{
 		FileToken: fileToken,
 	})
-	if err != nil {
-		// If DownloadDriveFile fails, try DownloadDriveMedia as fallback
-		// This handles the case where the file is actually a media resource inside a document
-		mediaResp, _, mediaErr := c.larkClient.Drive.DownloadDriveMedia(ctx, &lark.DownloadDriveMediaReq{
-			FileToken: fileToken,
-		})
-		if mediaErr != nil {
-			// Both APIs failed, create a placeholder
-			return c.createFilePlaceholder(ctx, fileToken, outDir, objType, title)
-		}
-		if mediaResp == nil {
-			return c.createFilePlaceholder(ctx, fileToken, outDir, objType, title)
-		}
-		file = mediaResp.File
-		filename = mediaResp.Filename
-	} else {
-		if resp == nil {
-			return c.createFilePlaceholder(ctx, fileToken, outDir, objType, title)
-		}
-		file = resp.File
-		filename = resp.Filename
+	if mediaErr != nil || mediaResp == nil {
+		// Both APIs failed, create a placeholder
+		return c.createFilePlaceholder(ctx, fileToken, outDir, objType, title)
 	}
+	file := mediaResp.File
+	filename := mediaResp.Filename
 
 	// Use the original filename from the response
 	if filename == "" {
@@ -119,7 +270,7 @@ func (c *Client) DownloadFile(ctx context.Context, fileToken, outDir, objType, t
 		filename = fileToken
 	}
 
-	filePath := filepath.Join(outDir, filename)
+	filePath = filepath.Join(outDir, filename)
 	err = os.MkdirAll(filepath.Dir(filePath), 0o755)
 	if err != nil {
 		return "", err
@@ -131,10 +282,11 @@ func (c *Client) DownloadFile(ctx context.Context, fileToken, outDir, objType, t
 	}
 	defer fileHandle.Close()
 
-	_, err = io.Copy(fileHandle, file)
+	written, err := io.Copy(fileHandle, file)
 	if err != nil {
 		return "", err
 	}
+	c.stats.addDownloadedBytes(written)
 
 	return filePath, nil
 }
@@ -162,6 +314,8 @@ func (c *Client) createFilePlaceholder(ctx context.Context, fileToken, outDir, o
 		fileType = "表格"
 	case "bitable":
 		fileType = "多维表格"
+	case "board", "whiteboard":
+		fileType = "画板"
 	default:
 		fileType = "文件"
 	}
@@ -180,7 +334,7 @@ func (c *Client) createFilePlaceholder(ctx context.Context, fileToken, outDir, o
 }
 
 func (c *Client) GetDocxContent(ctx context.Context, docToken string) (*lark.DocxDocument, []*lark.DocxBlock, error) {
-	resp, _, err := c.larkClient.Drive.GetDocxDocument(ctx, &lark.GetDocxDocumentReq{
+	resp, _, err := c.lark().Drive.GetDocxDocument(ctx, &lark.GetDocxDocumentReq{
 		DocumentID: docToken,
 	})
 	if err != nil {
@@ -193,25 +347,188 @@ func (c *Client) GetDocxContent(ctx context.Context, docToken string) (*lark.Doc
 	}
 	var blocks []*lark.DocxBlock
 	var pageToken *string
-	for {
-		resp2, _, err := c.larkClient.Drive.GetDocxBlockListOfDocument(ctx, &lark.GetDocxBlockListOfDocumentReq{
-			DocumentID: docx.DocumentID,
-			PageToken:  pageToken,
-		})
+	seen := make(map[string]bool)
+	// The pages are requested with the same app
+	larkClient := c.lark()
+	for page := 1; ; page++ {
+		resp2, err := c.getDocxBlockList(ctx, larkClient, docx.DocumentID, pageToken)
 		if err != nil {
 			return docx, nil, err
 		}
 		blocks = append(blocks, resp2.Items...)
-		pageToken = &resp2.PageToken
+		c.logf("Fetched %d blocks of %s\n", len(blocks), docx.DocumentID)
 		if !resp2.HasMore {
 			break
 		}
+		// Guard against a broken pagination of the API
+		if resp2.PageToken == "" || seen[resp2.PageToken] {
+			return docx, nil, fmt.Errorf("invalid page token %q of the blocks of %s", resp2.PageToken, docx.DocumentID)
+		}
+		if page >= maxDocxBlockPages {
+			return docx, nil, fmt.Errorf("too many pages of the blocks of %s", docx.DocumentID)
+		}
+		seen[resp2.PageToken] = true
+		pageToken = &resp2.PageToken
 	}
 	return docx, blocks, nil
 }
 
+// docxBlockListResp is the response of the block list API, the items are
+// decoded by getDocxBlockList
+type docxBlockListResp struct {
+	Code int64  `json:"code,omitempty"`
+	Msg  string `json:"msg,omitempty"`
+	Data struct {
+		Items     []json.RawMessage `json:"items"`
+		PageToken string            `json:"page_token"`
+		HasMore   bool              `json:"has_more"`
+	} `json:"data"`
+}
+
+// docxImageCaption is the caption of an image block
+type docxImageCaption struct {
+	BlockID string `json:"block_id"`
+	Image   *struct {
+		Caption *struct {
+			Content string `json:"content"`
+		} `json:"caption"`
+	} `json:"image"`
+}
+
+// docxTableHeader is the header setting of a table block
+type docxTableHeader struct {
+	BlockID string `json:"block_id"`
+	Table   *struct {
+		Property *struct {
+			HeaderRow    bool `json:"header_row"`
+			HeaderColumn bool `json:"header_column"`
+		} `json:"property"`
+	} `json:"table"`
+}
+
+// TableHeader tells whether the first row and the first column of a table are headers
+type TableHeader struct {
+	Row    bool
+	Column bool
+}
+
+// getDocxBlockList requests a page of the blocks of a document, same as
+// Drive.GetDocxBlockListOfDocument but keeping the captions of the images
+// and the header settings of the tables
+func (c *Client) getDocxBlockList(ctx context.Context, larkClient *lark.Lark, documentID string, pageToken *string) (*lark.GetDocxBlockListOfDocumentResp, error) {
+	resp := new(docxBlockListResp)
+	_, err := larkClient.RawRequest(ctx, &lark.RawRequestReq{
+		Scope:  "Drive",
+		API:    "GetDocxBlockListOfDocument",
+		Method: http.MethodGet,
+		URL:    "https://open.feishu.cn/open-apis/docx/v1/documents/:document_id/blocks",
+		Body: &lark.GetDocxBlockListOfDocumentReq{
+			DocumentID: documentID,
+			PageToken:  pageToken,
+		},
+		MethodOption:          &lark.MethodOption{},
+		NeedTenantAccessToken: true,
+	}, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &lark.GetDocxBlockListOfDocumentResp{
+		Items:     make([]*lark.DocxBlock, 0, len(resp.Data.Items)),
+		PageToken: resp.Data.PageToken,
+		HasMore:   resp.Data.HasMore,
+	}
+	for _, item := range resp.Data.Items {
+		block := &lark.DocxBlock{}
+		if err := json.Unmarshal(item, block); err != nil {
+			return nil, err
+		}
+		result.Items = append(result.Items, block)
+		switch block.BlockType {
+		case lark.DocxBlockTypeImage:
+			caption := docxImageCaption{}
+			if err := json.Unmarshal(item, &caption); err == nil &&
+				caption.Image != nil && caption.Image.Caption != nil && caption.Image.Caption.Content != "" {
+				c.imageCaptions.Store(caption.BlockID, caption.Image.Caption.Content)
+			}
+		case lark.DocxBlockTypeTable:
+			header := docxTableHeader{}
+			if err := json.Unmarshal(item, &header); err == nil &&
+				header.Table != nil && header.Table.Property != nil {
+				c.tableHeaders.Store(header.BlockID, TableHeader{
+					Row:    header.Table.Property.HeaderRow,
+					Column: header.Table.Property.HeaderColumn,
+				})
+			}
+		}
+	}
+	return result, nil
+}
+
+// ImageCaption returns the caption of an image block fetched by GetDocxContent
+func (c *Client) ImageCaption(blockID string) string {
+	if caption, ok := c.imageCaptions.Load(blockID); ok {
+		return caption.(string)
+	}
+	return ""
+}
+
+// TableHeader returns the header setting of a table block fetched by GetDocxContent
+func (c *Client) TableHeader(blockID string) (TableHeader, bool) {
+	if header, ok := c.tableHeaders.Load(blockID); ok {
+		return header.(TableHeader), true
+	}
+	return TableHeader{}, false
+}
+
+// mentionObjTypes maps the object types of a mentioned document to the doc types of the drive API
+var mentionObjTypes = map[lark.DocxMentionObjType]string{
+	1:  "doc",
+	3:  "sheet",
+	8:  "bitable",
+	11: "mindnote",
+	12: "file",
+	15: "slides",
+	22: "docx",
+}
+
+// GetDocumentTitle returns the latest title of a mentioned document, the
+// titles are cached by the client to avoid repeated requests
+func (c *Client) GetDocumentTitle(ctx context.Context, token string, objType lark.DocxMentionObjType) (string, error) {
+	if title, ok := c.titleCache.Load(token); ok {
+		return title.(string), nil
+	}
+
+	var title string
+	if objType == 16 {
+		node, err := c.GetWikiNodeInfo(ctx, token)
+		if err != nil {
+			return "", err
+		}
+		title = node.Title
+	} else {
+		docType, ok := mentionObjTypes[objType]
+		if !ok {
+			return "", fmt.Errorf("unsupported mentioned document type: %d", objType)
+		}
+		resp, _, err := c.lark().Drive.GetDriveFileMeta(ctx, &lark.GetDriveFileMetaReq{
+			RequestDocs: []*lark.GetDriveFileMetaReqRequestDocs{{DocToken: token, DocType: docType}},
+		})
+		if err != nil {
+			return "", err
+		}
+		if len(resp.Metas) == 0 {
+			return "", fmt.Errorf("failed to get the meta of document %s", token)
+		}
+		title = resp.Metas[0].Title
+	}
+
+	c.titleCache.Store(token, title)
+	return title, nil
+}
+
 func (c *Client) GetWikiNodeInfo(ctx context.Context, token string) (*lark.GetWikiNodeRespNode, error) {
-	resp, _, err := c.larkClient.Drive.GetWikiNode(ctx, &lark.GetWikiNodeReq{
+	resp, _, err := c.lark().Drive.GetWikiNode(ctx, &lark.GetWikiNodeReq{
 		Token: token,
 	})
 	if err != nil {
@@ -220,32 +537,21 @@ func (c *Client) GetWikiNodeInfo(ctx context.Context, token string) (*lark.GetWi
 	return resp.Node, nil
 }
 
+// GetDriveFolderFileList returns the files of a folder from the page of
+// pageToken, nil for the first page, see FolderFiles
 func (c *Client) GetDriveFolderFileList(ctx context.Context, pageToken *string, folderToken *string) ([]*lark.GetDriveFileListRespFile, error) {
-	resp, _, err := c.larkClient.Drive.GetDriveFileList(ctx, &lark.GetDriveFileListReq{
-		PageSize:    nil,
-		PageToken:   pageToken,
-		FolderToken: folderToken,
-	})
-	if err != nil {
-		return nil, err
+	start, folder := "", ""
+	if pageToken != nil {
+		start = *pageToken
 	}
-	files := resp.Files
-	for resp.HasMore {
-		resp, _, err = c.larkClient.Drive.GetDriveFileList(ctx, &lark.GetDriveFileListReq{
-			PageSize:    nil,
-			PageToken:   &resp.NextPageToken,
-			FolderToken: folderToken,
-		})
-		if err != nil {
-			return nil, err
-		}
-		files = append(files, resp.Files...)
+	if folderToken != nil {
+		folder = *folderToken
 	}
-	return files, nil
+	return NewPageCursor(start, c.folderFilesFetcher(folder)).All(ctx)
 }
 
 func (c *Client) GetWikiName(ctx context.Context, spaceID string) (string, error) {
-	resp, _, err := c.larkClient.Drive.GetWikiSpace(ctx, &lark.GetWikiSpaceReq{
+	resp, _, err := c.lark().Drive.GetWikiSpace(ctx, &lark.GetWikiSpaceReq{
 		SpaceID: spaceID,
 	})
 
@@ -256,230 +562,335 @@ func (c *Client) GetWikiName(ctx context.Context, spaceID string) (string, error
 	return resp.Space.Name, nil
 }
 
-func (c *Client) GetWikiNodeList(ctx context.Context, spaceID string, parentNodeToken *string) ([]*lark.GetWikiNodeListRespItem, error) {
-	resp, _, err := c.larkClient.Drive.GetWikiNodeList(ctx, &lark.GetWikiNodeListReq{
-		SpaceID:         spaceID,
-		PageSize:        nil,
-		PageToken:       nil,
-		ParentNodeToken: parentNodeToken,
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	nodes := resp.Items
-	previousPageToken := ""
-
-	for resp.HasMore && previousPageToken != resp.PageToken {
-		previousPageToken = resp.PageToken
-		resp, _, err := c.larkClient.Drive.GetWikiNodeList(ctx, &lark.GetWikiNodeListReq{
-			SpaceID:         spaceID,
-			PageSize:        nil,
-			PageToken:       &resp.PageToken,
-			ParentNodeToken: parentNodeToken,
+// GetWikiSpaces returns all the wiki spaces accessible by the app
+func (c *Client) GetWikiSpaces(ctx context.Context) ([]*lark.GetWikiSpaceListRespItem, error) {
+	var spaces []*lark.GetWikiSpaceListRespItem
+	pageSize := int64(50)
+	var pageToken *string
+	for {
+		// The spaces are the ones accessible by the main app
+		resp, _, err := c.larkClient.Drive.GetWikiSpaceList(ctx, &lark.GetWikiSpaceListReq{
+			PageSize:  &pageSize,
+			PageToken: pageToken,
 		})
-
 		if err != nil {
 			return nil, err
 		}
-
-		nodes = append(nodes, resp.Items...)
+		spaces = append(spaces, resp.Items...)
+		if !resp.HasMore || resp.PageToken == "" || (pageToken != nil && *pageToken == resp.PageToken) {
+			return spaces, nil
+		}
+		pageToken = &resp.PageToken
 	}
+}
 
-	return nodes, nil
+// GetWikiNodeList returns the child nodes of a wiki node, or the top level
+// nodes of the space if parentNodeToken is nil, see WikiNodes
+func (c *Client) GetWikiNodeList(ctx context.Context, spaceID string, parentNodeToken *string) ([]*lark.GetWikiNodeListRespItem, error) {
+	return c.WikiNodes(spaceID, parentNodeToken).All(ctx)
 }
 
+// valueRenderOption 与 dateTimeRenderOption 的取值
+const (
+	sheetValueRenderFormatted    = "FormattedValue"
+	sheetDateTimeRenderFormatted = "FormattedString"
+)
+
+// sheetChunkRows 是分段拉取电子表格时每次读取的行数，避免大范围一次读取超出接口限制
+const sheetChunkRows = 1000
+
 // GetSheetContent 获取电子表格的内容
-func (c *Client) GetSheetContent(ctx context.Context, sheetToken string) ([][]string, error) {
+// formulas 为 false 时获取公式计算后的显示值（与飞书界面一致），为 true 时保留公式本身
+func (c *Client) GetSheetContent(ctx context.Context, sheetToken string, formulas bool) ([][]string, error) {
+	// The ranges are requested with the same app
+	larkClient := c.lark()
 	// sheetToken 的格式是：spreadsheet_token + "_" + sheet_id
 	// 例如：B3hasMxsshByaEtZxAwcVfWxnSe_Ml1QzO
-	// 需要解析出 spreadsheet_token 和 sheet_id
-
 	// 查找最后一个下划线，分隔 spreadsheet_token 和 sheet_id
 	lastUnderscore := strings.LastIndex(sheetToken, "_")
 	if lastUnderscore == -1 {
 		return nil, fmt.Errorf("invalid sheet token format (missing underscore separator): %s", sheetToken)
 	}
-
 	spreadsheetToken := sheetToken[:lastUnderscore]
 	sheetID := sheetToken[lastUnderscore+1:]
 
-	// 定义原始 API 响应结构，使用 interface{} 来处理任意类型的值
-	type SheetValueResponse struct {
-		Code int    `json:"code"`
-		Msg  string `json:"msg"`
-		Data struct {
-			ValueRanges []struct {
-				MajorDimension string          `json:"majorDimension"`
-				Range          string          `json:"range"`
-				Values         [][]interface{} `json:"values"`
-			} `json:"valueRanges"`
-		} `json:"data"`
-	}
-
-	// 构建请求体
-	requestBody := map[string]interface{}{
-		"spreadsheetToken": spreadsheetToken,
-		"ranges":           []string{sheetID},
-	}
-	requestJSON, err := json.Marshal(requestBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	// 创建 HTTP 请求
-	// 使用飞书 API 的 endpoint
-	url := "https://open.feishu.cn/open-apis/sheets/v4/spreadsheets/" + spreadsheetToken + "/values:batchGet"
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestJSON))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// 设置请求头
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-
-	// 获取访问令牌
-	// 注意：这里需要从 lark client 获取访问令牌
-	// 由于 lark SDK 没有直接提供获取令牌的方法，我们需要使用 SDK 的认证机制
-	// 作为一个 workaround，我们使用 SDK 的方法，但手动处理响应
-
-	// 尝试使用 SDK 的方法
-	valueResp, _, err := c.larkClient.Drive.BatchGetSheetValue(ctx, &lark.BatchGetSheetValueReq{
+	// 按工作表的行列数分段拉取，获取不到行列数时一次读取整个工作表
+	ranges := []string{sheetID}
+	if resp, _, err := larkClient.Drive.GetSheet(ctx, &lark.GetSheetReq{
 		SpreadSheetToken: spreadsheetToken,
-		Ranges:           []string{sheetID},
-	})
-	if err != nil {
-		// 如果失败，返回详细的错误信息
-		return nil, fmt.Errorf("failed to get sheet values: %w", err)
+		SheetID:          sheetID,
+	}); err == nil && resp.Sheet != nil && resp.Sheet.GridProperties != nil {
+		ranges = sheetChunkRanges(sheetID, int(resp.Sheet.GridProperties.RowCount), int(resp.Sheet.GridProperties.ColumnCount))
 	}
 
-	if len(valueResp.ValueRanges) == 0 {
-		return nil, fmt.Errorf("no value ranges found")
-	}
-
-	// 转换为二维数组
-	values := valueResp.ValueRanges[0].Values
-	if len(values) == 0 {
-		return nil, fmt.Errorf("sheet is empty")
+	var values [][]lark.SheetContent
+	for _, r := range ranges {
+		valueReq := &lark.BatchGetSheetValueReq{
+			SpreadSheetToken: spreadsheetToken,
+			Ranges:           []string{r},
+		}
+		if !formulas {
+			// 计算公式并按单元格格式显示，日期也按其格式返回字符串
+			valueRender, dateTimeRender := sheetValueRenderFormatted, sheetDateTimeRenderFormatted
+			valueReq.ValueRenderOption = &valueRender
+			valueReq.DateTimeRenderOption = &dateTimeRender
+		}
+		valueResp, _, err := larkClient.Drive.BatchGetSheetValue(ctx, valueReq)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get sheet values: %w", err)
+		}
+		if len(valueResp.ValueRanges) == 0 {
+			return nil, fmt.Errorf("no value ranges found")
+		}
+		values = append(values, valueResp.ValueRanges[0].Values...)
 	}
 
-	// 将 [][]SheetContent 转换为 [][]string
+	// 去掉末尾的空行，工作表的行数通常大于有内容的行数
 	result := make([][]string, len(values))
 	for i, row := range values {
 		result[i] = make([]string, len(row))
 		for j, cell := range row {
-			// 根据单元格类型提取值
-			if cell.String != nil {
-				// 将换行符转换为 <br> 标签，以便在 markdown 表格中正确显示
-				result[i][j] = strings.ReplaceAll(*cell.String, "\n", "<br>")
-			} else if cell.Int != nil {
-				result[i][j] = fmt.Sprintf("%d", *cell.Int)
-			} else if cell.Float != nil {
-				// 处理浮点数
-				result[i][j] = fmt.Sprintf("%g", *cell.Float)
-			} else if cell.Link != nil {
-				result[i][j] = cell.Link.Text
-			} else if cell.Formula != nil {
-				// 公式类型，Text 字段存储公式本身
-				result[i][j] = cell.Formula.Text
-			} else if cell.AtUser != nil {
-				result[i][j] = cell.AtUser.Text
-			} else if cell.AtDoc != nil {
-				result[i][j] = cell.AtDoc.Text
-			} else if cell.MultiValue != nil && len(cell.MultiValue.Values) > 0 {
-				// 下拉列表，可能有多个值
-				values := make([]string, len(cell.MultiValue.Values))
-				for k, v := range cell.MultiValue.Values {
-					// Values 是 []interface{}，可能是 string, bool, 或 number
-					switch val := v.(type) {
-					case string:
-						values[k] = val
-					case bool:
-						values[k] = fmt.Sprintf("%t", val)
-					case float64:
-						values[k] = fmt.Sprintf("%g", val) // 使用 %g 去掉不必要的零
-					case int:
-						values[k] = fmt.Sprintf("%d", val)
-					case int64:
-						values[k] = fmt.Sprintf("%d", val)
-					default:
-						values[k] = fmt.Sprintf("%v", val)
-					}
-				}
-				result[i][j] = strings.Join(values, ", ")
-			} else {
-				result[i][j] = ""
-			}
+			result[i][j] = sheetCellText(cell)
 		}
 	}
-
+	for len(result) > 0 && strings.Join(result[len(result)-1], "") == "" {
+		result = result[:len(result)-1]
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("sheet is empty")
+	}
 	return result, nil
 }
 
-// GetBitableContent 获取多维表格的内容
-func (c *Client) GetBitableContent(ctx context.Context, bitableToken string) ([][]string, error) {
-	// bitableToken 的格式是：app_token + "_" + table_id
-	// 例如：CZJHb9XisaEsWosyB1pcAk2WnRg_tblxxxxx
-	// 需要解析出 app_token 和 table_id
+// sheetChunkRanges 将工作表按 sheetChunkRows 行划分为多个读取范围，如 Ml1QzO!A1:F1000
+func sheetChunkRanges(sheetID string, rows, columns int) []string {
+	if rows <= 0 || columns <= 0 {
+		return []string{sheetID}
+	}
+	lastColumn := xlsxColumnName(columns - 1)
+	var ranges []string
+	for start := 1; start <= rows; start += sheetChunkRows {
+		end := min(start+sheetChunkRows-1, rows)
+		ranges = append(ranges, fmt.Sprintf("%s!A%d:%s%d", sheetID, start, lastColumn, end))
+	}
+	return ranges
+}
 
-	// 查找最后一个下划线，分隔 app_token 和 table_id
-	lastUnderscore := strings.LastIndex(bitableToken, "_")
-	if lastUnderscore == -1 {
-		return nil, fmt.Errorf("invalid bitable token format (missing underscore separator): %s", bitableToken)
+// sheetCellText 根据单元格类型提取显示的文本
+func sheetCellText(cell lark.SheetContent) string {
+	switch {
+	case cell.String != nil:
+		// 将换行符转换为 <br> 标签，以便在 markdown 表格中正确显示
+		return strings.ReplaceAll(*cell.String, "\n", "<br>")
+	case cell.Int != nil:
+		return fmt.Sprintf("%d", *cell.Int)
+	case cell.Float != nil:
+		return fmt.Sprintf("%g", *cell.Float)
+	case cell.Link != nil:
+		// 保留超链接，渲染为 markdown 链接
+		if cell.Link.Link != "" {
+			return fmt.Sprintf("[%s](%s)", cell.Link.Text, cell.Link.Link)
+		}
+		return cell.Link.Text
+	case cell.Formula != nil:
+		// 公式类型，Text 字段存储公式本身
+		return cell.Formula.Text
+	case cell.AtUser != nil:
+		return cell.AtUser.Text
+	case cell.AtDoc != nil:
+		return cell.AtDoc.Text
+	case cell.MultiValue != nil:
+		// 下拉列表，可能有多个值，可能是 string、bool 或 number
+		values := make([]string, len(cell.MultiValue.Values))
+		for k, v := range cell.MultiValue.Values {
+			switch val := v.(type) {
+			case float64:
+				values[k] = fmt.Sprintf("%g", val) // 使用 %g 去掉不必要的零
+			default:
+				values[k] = fmt.Sprintf("%v", val)
+			}
+		}
+		return strings.Join(values, ", ")
 	}
+	return ""
+}
 
-	appToken := bitableToken[:lastUnderscore]
-	tableID := bitableToken[lastUnderscore+1:]
+// SheetMerge 描述电子表格中的一个合并单元格区域，行列下标从 0 开始且包含结束位置
+type SheetMerge struct {
+	StartRow    int
+	EndRow      int
+	StartColumn int
+	EndColumn   int
+}
 
-	// 1. 获取表格的字段信息
-	fieldResp, _, err := c.larkClient.Bitable.GetBitableFieldList(ctx, &lark.GetBitableFieldListReq{
-		AppToken: appToken,
-		TableID:  tableID,
+// GetSheetMerges 获取电子表格中合并单元格的信息
+func (c *Client) GetSheetMerges(ctx context.Context, sheetToken string) ([]SheetMerge, error) {
+	lastUnderscore := strings.LastIndex(sheetToken, "_")
+	if lastUnderscore == -1 {
+		return nil, fmt.Errorf("invalid sheet token format (missing underscore separator): %s", sheetToken)
+	}
+
+	resp, _, err := c.lark().Drive.GetSheet(ctx, &lark.GetSheetReq{
+		SpreadSheetToken: sheetToken[:lastUnderscore],
+		SheetID:          sheetToken[lastUnderscore+1:],
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get bitable fields: %w", err)
+		return nil, fmt.Errorf("failed to get sheet merges: %w", err)
+	}
+	if resp.Sheet == nil {
+		return nil, nil
 	}
 
-	// 2. 获取表格的记录
-	recordResp, _, err := c.larkClient.Bitable.GetBitableRecordList(ctx, &lark.GetBitableRecordListReq{
-		AppToken: appToken,
-		TableID:  tableID,
-	})
+	merges := make([]SheetMerge, 0, len(resp.Sheet.Merges))
+	for _, m := range resp.Sheet.Merges {
+		merges = append(merges, SheetMerge{
+			StartRow:    int(m.StartRowIndex),
+			EndRow:      int(m.EndRowIndex),
+			StartColumn: int(m.StartColumnIndex),
+			EndColumn:   int(m.EndColumnIndex),
+		})
+	}
+	return merges, nil
+}
+
+// parseBitableToken 解析多维表格 token
+// 格式是：app_token + "_" + table_id，嵌入块可能还带有 "_" + view_id
+// 例如：CZJHb9XisaEsWosyB1pcAk2WnRg_tblxxxxx_vewxxxxx
+func parseBitableToken(bitableToken string) (appToken, tableID, viewID string, err error) {
+	parts := strings.Split(bitableToken, "_")
+	if len(parts) >= 3 && strings.HasPrefix(parts[len(parts)-1], "vew") {
+		viewID = parts[len(parts)-1]
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) < 2 {
+		return "", "", "", fmt.Errorf("invalid bitable token format (missing underscore separator): %s", bitableToken)
+	}
+	appToken = strings.Join(parts[:len(parts)-1], "_")
+	tableID = parts[len(parts)-1]
+	return appToken, tableID, viewID, nil
+}
+
+// GetBitableContent 获取多维表格的内容
+// 如果 token 带有视图，则遵循视图的字段显示/隐藏、顺序以及记录的筛选排序；
+// fieldNames 不为空时只导出指定名称的字段
+func (c *Client) GetBitableContent(ctx context.Context, bitableToken string, fieldNames []string) ([][]string, error) {
+	table, err := c.GetBitableTable(ctx, bitableToken, fieldNames)
+	if err != nil {
+		return nil, err
+	}
+	return table.Rows, nil
+}
+
+// GetBitableTable is GetBitableContent keeping the files of the attachment fields
+func (c *Client) GetBitableTable(ctx context.Context, bitableToken string, fieldNames []string) (*BitableTable, error) {
+	// The pages are requested with the same app
+	larkClient := c.lark()
+	appToken, tableID, viewID, err := parseBitableToken(bitableToken)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get bitable records: %w", err)
+		return nil, err
+	}
+	var viewIDPtr *string
+	if viewID != "" {
+		viewIDPtr = &viewID
+	}
+
+	// 1. 获取表格的字段信息（指定视图时按视图中的顺序返回）
+	var fields []*lark.GetBitableFieldListRespItem
+	var pageToken *string
+	for {
+		fieldResp, _, err := larkClient.Bitable.GetBitableFieldList(ctx, &lark.GetBitableFieldListReq{
+			AppToken:  appToken,
+			TableID:   tableID,
+			ViewID:    viewIDPtr,
+			PageToken: pageToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get bitable fields: %w", err)
+		}
+		fields = append(fields, fieldResp.Items...)
+		if !fieldResp.HasMore || fieldResp.PageToken == "" {
+			break
+		}
+		pageToken = &fieldResp.PageToken
+	}
+
+	// 过滤视图中隐藏的字段以及未被选择的字段
+	selected := make(map[string]bool, len(fieldNames))
+	for _, name := range fieldNames {
+		selected[name] = true
+	}
+	var visibleFields []*lark.GetBitableFieldListRespItem
+	for _, field := range fields {
+		if viewID != "" && field.IsHidden {
+			continue
+		}
+		if len(selected) > 0 && !selected[field.FieldName] {
+			continue
+		}
+		visibleFields = append(visibleFields, field)
+	}
+
+	// 2. 获取表格的记录（指定视图时遵循视图的筛选与排序）
+	var records []*lark.GetBitableRecordListRespItem
+	pageToken = nil
+	pageSize := int64(500)
+	displayFormulaRef := true
+	for {
+		recordResp, _, err := larkClient.Bitable.GetBitableRecordList(ctx, &lark.GetBitableRecordListReq{
+			AppToken:  appToken,
+			TableID:   tableID,
+			ViewID:    viewIDPtr,
+			PageToken: pageToken,
+			PageSize:  &pageSize,
+			// 公式与查找引用字段按被引用字段的格式返回计算值
+			DisplayFormulaRef: &displayFormulaRef,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get bitable records: %w", err)
+		}
+		records = append(records, recordResp.Items...)
+		if !recordResp.HasMore || recordResp.PageToken == "" {
+			break
+		}
+		pageToken = &recordResp.PageToken
 	}
 
 	// 3. 构建表格数据
 	// 第一行是字段名
 	var result [][]string
+	attachments := make(map[[2]int][]BitableAttachment)
 
 	// 添加表头（字段名）
-	if len(fieldResp.Items) > 0 {
+	if len(visibleFields) > 0 {
 		var header []string
-		for _, field := range fieldResp.Items {
+		for _, field := range visibleFields {
 			header = append(header, field.FieldName)
 		}
 		result = append(result, header)
 	}
 
 	// 添加数据行
-	if len(recordResp.Items) > 0 {
-		for _, record := range recordResp.Items {
-			var row []string
-			for _, field := range fieldResp.Items {
-				// 从记录中获取字段值
-				if value, ok := record.Fields[field.FieldID]; ok {
-					// 将值转换为字符串
-					row = append(row, fmt.Sprintf("%v", value))
-				} else {
-					row = append(row, "")
+	for _, record := range records {
+		var row []string
+		for _, field := range visibleFields {
+			// 记录中的字段以字段名为 key
+			value, ok := record.Fields[field.FieldName]
+			if !ok {
+				value, ok = record.Fields[field.FieldID]
+			}
+			if !ok {
+				row = append(row, "")
+				continue
+			}
+			if field.Type == bitableFieldAttachment {
+				if files := bitableAttachments(value); len(files) > 0 {
+					attachments[[2]int{len(result), len(row)}] = files
 				}
 			}
-			result = append(result, row)
+			row = append(row, BitableCellText(field.Type, value))
 		}
+		result = append(result, row)
 	}
 
-	return result, nil
+	return &BitableTable{Rows: result, Attachments: attachments}, nil
 }
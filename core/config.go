@@ -2,28 +2,245 @@ package core
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 )
 
 type Config struct {
 	Feishu FeishuConfig `json:"feishu"`
 	Output OutputConfig `json:"output"`
+	LLM    LLMConfig    `json:"llm"`
+	Server ServerConfig `json:"server"`
+	HTTP   HTTPConfig   `json:"http"`
+	Notify NotifyConfig `json:"notify"`
+	OCR    OCRConfig    `json:"ocr"`
+	Vector VectorConfig `json:"vector"`
+	Limits LimitsConfig `json:"limits"`
+	// PostProcess are the commands run after the export, see the post
+	// process of the README for the placeholders
+	PostProcess []string `json:"post_process,omitempty"`
+	// Profiles are the named credentials of other feishu apps
+	Profiles map[string]FeishuConfig `json:"profiles,omitempty"`
+	// Overrides are the output settings of some wiki spaces or urls
+	Overrides []OutputOverride `json:"overrides,omitempty"`
+}
+
+// OutputOverride changes the output settings of the documents of a wiki
+// space, or of the urls with a prefix. Output holds only the fields to
+// change, in the format of the output section.
+type OutputOverride struct {
+	SpaceID   string          `json:"space_id,omitempty"`
+	URLPrefix string          `json:"url_prefix,omitempty"`
+	Output    json.RawMessage `json:"output"`
+}
+
+func (o OutputOverride) matches(spaceID, url string) bool {
+	return (o.SpaceID != "" && o.SpaceID == spaceID) ||
+		(o.URLPrefix != "" && strings.HasPrefix(url, o.URLPrefix))
+}
+
+// WithOverrides returns the output config with the overrides matching the
+// wiki space or the url applied in order, the later ones win
+func (o OutputConfig) WithOverrides(overrides []OutputOverride, spaceID, url string) (OutputConfig, error) {
+	for _, override := range overrides {
+		if !override.matches(spaceID, url) || len(override.Output) == 0 {
+			continue
+		}
+		// The unmarshal merges into the map, a copy keeps the fields of an
+		// override out of the base config
+		if o.FrontMatter != nil {
+			frontMatter := make(map[string]interface{}, len(o.FrontMatter))
+			for key, value := range o.FrontMatter {
+				frontMatter[key] = value
+			}
+			o.FrontMatter = frontMatter
+		}
+		if o.SmartBlocks != nil {
+			smartBlocks := make(map[string]string, len(o.SmartBlocks))
+			for key, value := range o.SmartBlocks {
+				smartBlocks[key] = value
+			}
+			o.SmartBlocks = smartBlocks
+		}
+		if err := json.Unmarshal(override.Output, &o); err != nil {
+			return o, fmt.Errorf("invalid output override of %s%s: %v", override.SpaceID, override.URLPrefix, err)
+		}
+	}
+	return o, nil
+}
+
+// HTTPConfig configures the requests to the feishu OPEN API
+type HTTPConfig struct {
+	UserAgent string            `json:"user_agent"`
+	Headers   map[string]string `json:"headers,omitempty"`
+}
+
+// ServerConfig configures the HTTP API of the serve command
+type ServerConfig struct {
+	APIKeys []string `json:"api_keys"`
+	// KeyProfiles are the profiles each API key may select with the
+	// X-Feishu-Profile header, a key without profiles selects none
+	KeyProfiles map[string][]string `json:"key_profiles,omitempty"`
+}
+
+// NotifyConfig sends the summary of the batch and wiki downloads to the
+// webhook of a feishu, dingtalk or slack robot
+type NotifyConfig struct {
+	Webhook string `json:"webhook"`
 }
 
 type FeishuConfig struct {
-	AppId     string `json:"app_id"`
+	AppId             string `json:"app_id"`
+	AppSecret         string `json:"app_secret"`
+	EncryptKey        string `json:"encrypt_key"`
+	VerificationToken string `json:"verification_token"`
+	// Apps are the credentials of other apps, the requests are rotated
+	// among them and the app of AppId to raise the rate limit
+	Apps []AppCredential `json:"apps,omitempty"`
+}
+
+// AppCredential is the credential of an app of the OPEN API
+type AppCredential struct {
+	AppID     string `json:"app_id"`
 	AppSecret string `json:"app_secret"`
 }
 
+// LLMConfig configures an OpenAI compatible API, e.g. https://api.openai.com/v1
+type LLMConfig struct {
+	Endpoint string `json:"endpoint"`
+	APIKey   string `json:"api_key"`
+	Model    string `json:"model"`
+}
+
 type OutputConfig struct {
-	ImageDir        string `json:"image_dir"`
-	TitleAsFilename bool   `json:"title_as_filename"`
-	UseHTMLTags     bool   `json:"use_html_tags"`
-	SkipImgDownload bool   `json:"skip_img_download"`
+	ImageDir        string   `json:"image_dir"`
+	TitleAsFilename bool     `json:"title_as_filename"`
+	UseHTMLTags     bool     `json:"use_html_tags"`
+	Flavor          string   `json:"flavor"`
+	SkipImgDownload bool     `json:"skip_img_download"`
+	ImageURLPrefix  string   `json:"image_url_prefix"`
+	BitableMode     string   `json:"bitable_mode"`
+	BitableFields   []string `json:"bitable_fields"`
+	// BitableAttachments downloads the files of the attachment fields into
+	// the attachments directory under the image directory
+	BitableAttachments bool `json:"bitable_attachments"`
+	SheetMaxRows       int  `json:"sheet_max_rows"`
+	SheetMaxColumns    int  `json:"sheet_max_columns"`
+	// SheetFormulas exports the formulas of the sheet cells instead of the
+	// computed values displayed in feishu
+	SheetFormulas bool `json:"sheet_formulas"`
+	// RefreshMentionTitles fetches the latest titles of the mentioned documents
+	RefreshMentionTitles bool `json:"refresh_mention_titles"`
+	// InlineEmbeds replaces the embedded document previews with their content
+	InlineEmbeds         bool `json:"inline_embeds"`
+	InlineEmbedsMaxDepth int  `json:"inline_embeds_max_depth"`
+	// ListIndentStyle and ListIndentWidth control the indentation of nested lists
+	ListIndentStyle string `json:"list_indent_style"`
+	ListIndentWidth int    `json:"list_indent_width"`
+	// HeaderTemplate and FooterTemplate are injected into every document,
+	// see DocumentVars for the variables they can reference
+	HeaderTemplate string `json:"header_template"`
+	FooterTemplate string `json:"footer_template"`
+	// LinkStyle is "inline", or "reference" or "footnote" to collect the
+	// links at the end
+	LinkStyle string `json:"link_style"`
+	// FigureStyle renders the images with captions as "markdown" or "html"
+	FigureStyle string `json:"figure_style"`
+	// ImageNaming names the downloaded images by their "token", or by their
+	// "original" file names suffixed with -2, -3 and so on for the conflicts
+	ImageNaming string `json:"image_naming"`
+	// ImageAttributes renders the images as <img> with loading="lazy", the
+	// width and the height
+	ImageAttributes bool `json:"image_attributes"`
+	// IframeMode renders the embedded web content as a "notice", a "link" or
+	// the "embed" code of the platform
+	IframeMode string `json:"iframe_mode"`
+	// TableHeader is "auto" to follow the header settings of the tables in
+	// feishu, or forces the header of all tables to be the first "row", the
+	// first "column", "both" or "none"
+	TableHeader string `json:"table_header"`
+	// TrailingPatterns and TrailingBlockTypes drop the blocks at the end of
+	// the documents whose text matches one of the regular expressions or of
+	// the block types, e.g. a fixed copyright notice of a template
+	TrailingPatterns   []string `json:"trailing_patterns"`
+	TrailingBlockTypes []int    `json:"trailing_block_types"`
+	// LinkStripParams are the query parameters removed from the links, e.g.
+	// "from", or "utm_*" for all the parameters with the prefix
+	LinkStripParams []string `json:"link_strip_params"`
+	// ExpandShortLinks replaces the feishu short links with their targets
+	ExpandShortLinks bool `json:"expand_short_links"`
+	// ExternalLinks renders the links outside of feishu as "plain" links,
+	// "annotate"s them with ExternalLinkLabel or "list"s them at the end
+	ExternalLinks     string `json:"external_links"`
+	ExternalLinkLabel string `json:"external_link_label"`
+	// AllowHTML false degrades the html output, e.g. the tables with merged
+	// cells, <u> and <br/>, to the closest markdown
+	AllowHTML bool `json:"allow_html"`
+	// Slug names the directories and the files of the titles, "keep"s the
+	// titles, converts them to "pinyin" or uses their "token"s
+	Slug string `json:"slug"`
+	// ChunkSize and ChunkOverlap are the runes of the chunks of the jsonl
+	// format and of the overlap between them
+	ChunkSize    int `json:"chunk_size"`
+	ChunkOverlap int `json:"chunk_overlap"`
+	// Metadata keeps the tokens and the revision of the documents in a
+	// .meta.json "sidecar" next to them or in their "front_matter"
+	Metadata string `json:"metadata"`
+	// HeadingOffset demotes all the headings by the levels, e.g. 1 renders
+	// the title as ## to leave the # to the template of a site
+	HeadingOffset int `json:"heading_offset"`
+	// OmitPageTitle renders the children of the page without the # title,
+	// which goes into the front matter instead
+	OmitPageTitle bool `json:"omit_page_title"`
+	// LineBreak renders the line breaks inside the texts, see LineBreakKeep
+	// and the other styles
+	LineBreak string `json:"line_break"`
+	// DocumentProperties writes the owner, the times and the other properties
+	// of the documents into the front matter
+	DocumentProperties bool `json:"document_properties"`
+	// FrontMatter are the custom fields of the front matter, e.g.
+	// {"status": "published"} in the override of a wiki space
+	FrontMatter map[string]interface{} `json:"front_matter,omitempty"`
+	// StripEXIF removes the exif and the other metadata of the downloaded
+	// images, e.g. the location of a photo
+	StripEXIF bool `json:"strip_exif"`
+	// SmartBlocks are the static texts of the date reminders and of the ISV
+	// blocks, e.g. a countdown, see SmartBlockReminder and SmartBlockISV
+	SmartBlocks map[string]string `json:"smart_blocks,omitempty"`
 }
 
+// Supported values of OutputConfig.BitableMode
+const (
+	BitableModeMarkdown = "markdown"
+	BitableModeCSV      = "csv"
+	BitableModeXLSX     = "xlsx"
+	BitableModeLink     = "link"
+)
+
+// Supported values of OutputConfig.FigureStyle
+const (
+	FigureStyleMarkdown = "markdown"
+	FigureStyleHTML     = "html"
+)
+
+// Supported values of OutputConfig.TableHeader
+const (
+	TableHeaderAuto   = "auto"
+	TableHeaderRow    = "row"
+	TableHeaderColumn = "column"
+	TableHeaderBoth   = "both"
+	TableHeaderNone   = "none"
+)
+
+// Supported values of OutputConfig.ListIndentStyle
+const (
+	ListIndentSpace = "space"
+	ListIndentTab   = "tab"
+)
+
 func NewConfig(appId, appSecret string) *Config {
 	return &Config{
 		Feishu: FeishuConfig{
@@ -35,10 +252,141 @@ func NewConfig(appId, appSecret string) *Config {
 			TitleAsFilename: false,
 			UseHTMLTags:     false,
 			SkipImgDownload: false,
+			ImageURLPrefix:  "",
+			BitableMode:     BitableModeMarkdown,
+
+			InlineEmbedsMaxDepth: 3,
+			ListIndentStyle:      ListIndentSpace,
+			ListIndentWidth:      4,
+			LinkStyle:            LinkStyleInline,
+			FigureStyle:          FigureStyleMarkdown,
+			IframeMode:           IframeModeNotice,
+			TableHeader:          TableHeaderAuto,
+			ImageNaming:          ImageNamingToken,
+			ExternalLinks:        ExternalLinksPlain,
+			AllowHTML:            true,
+			Slug:                 SlugKeep,
+			ChunkSize:            1000,
+			ChunkOverlap:         100,
+			LineBreak:            LineBreakKeep,
+		},
+		OCR: OCRConfig{
+			Provider: OCRProviderFeishu,
+			Style:    OCRStyleComment,
 		},
 	}
 }
 
+// Validate checks the settings which would otherwise fail in the middle of
+// a download, e.g. an unknown flavor or an invalid regular expression
+func (conf *Config) Validate() error {
+	for i, app := range conf.Feishu.Apps {
+		if app.AppID == "" || app.AppSecret == "" {
+			return fmt.Errorf("app %d of apps requires the app_id and the app_secret", i+1)
+		}
+	}
+	// The API keys are not printed in the errors
+	for _, profiles := range conf.Server.KeyProfiles {
+		for _, profile := range profiles {
+			if _, ok := conf.Profiles[profile]; !ok {
+				return fmt.Errorf("unknown profile %s in server.key_profiles", profile)
+			}
+		}
+	}
+	if _, err := conf.Output.OutputFlavor(); err != nil {
+		return err
+	}
+	switch conf.Output.LinkStyle {
+	case "", LinkStyleInline, LinkStyleReference, LinkStyleFootnote:
+	default:
+		return fmt.Errorf("unsupported link style: %s", conf.Output.LinkStyle)
+	}
+	switch conf.Output.ExternalLinks {
+	case "", ExternalLinksPlain, ExternalLinksAnnotate, ExternalLinksList:
+	default:
+		return fmt.Errorf("unsupported external links mode: %s", conf.Output.ExternalLinks)
+	}
+	switch conf.Output.ImageNaming {
+	case "", ImageNamingToken, ImageNamingOriginal:
+	default:
+		return fmt.Errorf("unsupported image naming: %s", conf.Output.ImageNaming)
+	}
+	switch conf.Output.Slug {
+	case "", SlugKeep, SlugPinyin, SlugToken:
+	default:
+		return fmt.Errorf("unsupported slug: %s", conf.Output.Slug)
+	}
+	if conf.Output.ChunkSize < 0 || conf.Output.ChunkOverlap < 0 ||
+		(conf.Output.ChunkSize > 0 && conf.Output.ChunkOverlap >= conf.Output.ChunkSize) {
+		return fmt.Errorf("invalid chunk size %d or overlap %d, the overlap must be less than the size",
+			conf.Output.ChunkSize, conf.Output.ChunkOverlap)
+	}
+	if conf.Output.HeadingOffset < 0 || conf.Output.HeadingOffset > 5 {
+		return fmt.Errorf("invalid heading offset %d, it must be between 0 and 5", conf.Output.HeadingOffset)
+	}
+	switch conf.Output.LineBreak {
+	case "", LineBreakKeep, LineBreakSpaces, LineBreakBackslash, LineBreakHTML, LineBreakJoin:
+	default:
+		return fmt.Errorf("unsupported line break style: %s", conf.Output.LineBreak)
+	}
+	switch conf.Output.BitableMode {
+	case "", BitableModeMarkdown, BitableModeCSV, BitableModeXLSX, BitableModeLink:
+	default:
+		return fmt.Errorf("unsupported bitable mode: %s", conf.Output.BitableMode)
+	}
+	switch conf.Output.FigureStyle {
+	case "", FigureStyleMarkdown, FigureStyleHTML:
+	default:
+		return fmt.Errorf("unsupported figure style: %s", conf.Output.FigureStyle)
+	}
+	switch conf.Output.ListIndentStyle {
+	case "", ListIndentSpace, ListIndentTab:
+	default:
+		return fmt.Errorf("unsupported list indent style: %s", conf.Output.ListIndentStyle)
+	}
+	switch conf.Output.IframeMode {
+	case "", IframeModeNotice, IframeModeLink, IframeModeEmbed:
+	default:
+		return fmt.Errorf("unsupported iframe mode: %s", conf.Output.IframeMode)
+	}
+	switch conf.Output.TableHeader {
+	case "", TableHeaderAuto, TableHeaderRow, TableHeaderColumn, TableHeaderBoth, TableHeaderNone:
+	default:
+		return fmt.Errorf("unsupported table header: %s", conf.Output.TableHeader)
+	}
+	switch conf.Output.Metadata {
+	case "", MetadataNone, MetadataSidecar, MetadataFrontMatter:
+	default:
+		return fmt.Errorf("unsupported metadata mode: %s", conf.Output.Metadata)
+	}
+	switch conf.OCR.Provider {
+	case "", OCRProviderFeishu:
+	case OCRProviderHTTP:
+		if conf.OCR.Endpoint == "" {
+			return fmt.Errorf("the http ocr provider requires an endpoint")
+		}
+	default:
+		return fmt.Errorf("unsupported ocr provider: %s", conf.OCR.Provider)
+	}
+	switch conf.OCR.Style {
+	case "", OCRStyleComment, OCRStyleAlt:
+	default:
+		return fmt.Errorf("unsupported ocr style: %s", conf.OCR.Style)
+	}
+	if _, err := compileTrailingPatterns(conf.Output.TrailingPatterns); err != nil {
+		return err
+	}
+	for _, override := range conf.Overrides {
+		if override.SpaceID == "" && override.URLPrefix == "" {
+			return fmt.Errorf("an output override requires a space_id or a url_prefix")
+		}
+		if _, err := conf.Output.WithOverrides([]OutputOverride{override}, override.SpaceID, override.URLPrefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func GetConfigFilePath() (string, error) {
 	configPath, err := os.UserConfigDir()
 	if err != nil {
@@ -73,3 +421,17 @@ func (conf *Config) WriteConfig2File(configPath string) error {
 	err = os.WriteFile(configPath, file, 0o644)
 	return err
 }
+
+// ImageLink returns the link of a downloaded image to be written into the
+// markdown file located in mdDir. The link is relative to mdDir unless an
+// ImageURLPrefix is configured, e.g. "/static/images/" for publishing.
+func (o OutputConfig) ImageLink(mdDir, imgPath string) string {
+	if o.ImageURLPrefix != "" {
+		return strings.TrimSuffix(o.ImageURLPrefix, "/") + "/" + filepath.Base(imgPath)
+	}
+	rel, err := filepath.Rel(mdDir, imgPath)
+	if err != nil {
+		return filepath.ToSlash(imgPath)
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,142 @@
+package core_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImageLink(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  string
+		mdDir   string
+		imgPath string
+		want    string
+	}{
+		{
+			name:    "relative to the markdown file",
+			mdDir:   "output",
+			imgPath: "output/static/token.png",
+			want:    "static/token.png",
+		},
+		{
+			name:    "nested markdown directory",
+			mdDir:   "output/wiki/sub",
+			imgPath: "output/wiki/sub/static/token.png",
+			want:    "static/token.png",
+		},
+		{
+			name:    "configured url prefix",
+			prefix:  "/static/images/",
+			mdDir:   "output",
+			imgPath: "output/static/token.png",
+			want:    "/static/images/token.png",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := core.NewConfig("", "").Output
+			output.ImageURLPrefix = tt.prefix
+			assert.Equal(t, tt.want, output.ImageLink(tt.mdDir, tt.imgPath))
+		})
+	}
+}
+
+func TestOutputWithOverrides(t *testing.T) {
+	config := core.NewConfig("", "")
+	err := json.Unmarshal([]byte(`{"overrides": [
+		{"space_id": "7001", "output": {"image_url_prefix": "/img/", "title_as_filename": true}},
+		{"url_prefix": "https://example.feishu.cn/wiki/", "output": {"image_url_prefix": "/wiki/"}}
+	]}`), config)
+	assert.NoError(t, err)
+
+	output, err := config.Output.WithOverrides(config.Overrides, "7001", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "/img/", output.ImageURLPrefix)
+	assert.True(t, output.TitleAsFilename)
+	assert.Equal(t, "static", output.ImageDir)
+
+	output, err = config.Output.WithOverrides(config.Overrides, "7001", "https://example.feishu.cn/wiki/abc")
+	assert.NoError(t, err)
+	assert.Equal(t, "/wiki/", output.ImageURLPrefix)
+	assert.True(t, output.TitleAsFilename)
+
+	output, err = config.Output.WithOverrides(config.Overrides, "7002", "https://example.feishu.cn/docx/abc")
+	assert.NoError(t, err)
+	assert.Equal(t, config.Output, output)
+}
+
+func TestConfigValidate(t *testing.T) {
+	config := core.NewConfig("", "")
+	assert.NoError(t, config.Validate())
+
+	config.Output.TrailingPatterns = []string{"("}
+	assert.ErrorContains(t, config.Validate(), "invalid trailing pattern")
+
+	config = core.NewConfig("", "")
+	config.Output.Metadata = "xattr"
+	assert.ErrorContains(t, config.Validate(), "unsupported metadata mode")
+
+	config = core.NewConfig("", "")
+	config.Output.BitableMode = "cvs"
+	assert.ErrorContains(t, config.Validate(), "unsupported bitable mode")
+	config = core.NewConfig("", "")
+	config.Output.FigureStyle = "figure"
+	assert.ErrorContains(t, config.Validate(), "unsupported figure style")
+	config = core.NewConfig("", "")
+	config.Output.ListIndentStyle = "tabs"
+	assert.ErrorContains(t, config.Validate(), "unsupported list indent style")
+	config = core.NewConfig("", "")
+	config.Output.IframeMode = "iframe"
+	assert.ErrorContains(t, config.Validate(), "unsupported iframe mode")
+
+	config = core.NewConfig("", "")
+	config.Server.KeyProfiles = map[string][]string{"key": {"team-a"}}
+	assert.ErrorContains(t, config.Validate(), "unknown profile team-a")
+	config.Profiles = map[string]core.FeishuConfig{"team-a": {AppId: "id", AppSecret: "secret"}}
+	assert.NoError(t, config.Validate())
+
+	config = core.NewConfig("", "")
+	config.Overrides = []core.OutputOverride{{Output: []byte(`{"flavor": "gfm"}`)}}
+	assert.Error(t, config.Validate())
+	config.Overrides[0].SpaceID = "7001"
+	assert.NoError(t, config.Validate())
+}
+
+func TestOutputFrontMatterOverrides(t *testing.T) {
+	config := core.NewConfig("", "")
+	err := json.Unmarshal([]byte(`{"output": {"front_matter": {"status": "draft", "team": "docs"}}, "overrides": [
+		{"space_id": "7001", "output": {"front_matter": {"status": "published"}}}
+	]}`), config)
+	assert.NoError(t, err)
+
+	output, err := config.Output.WithOverrides(config.Overrides, "7001", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []core.FrontMatterField{
+		{Key: "status", Value: "published"},
+		{Key: "team", Value: "docs"},
+	}, output.FrontMatterFields())
+	// The base config is not changed by the override
+	assert.Equal(t, "draft", config.Output.FrontMatter["status"])
+}
+
+func TestFeishuApps(t *testing.T) {
+	config := core.NewConfig("cli_main", "secret")
+	err := json.Unmarshal([]byte(`{"feishu": {"app_id": "cli_main", "app_secret": "secret", "apps": [
+		{"app_id": "cli_second", "app_secret": "secret2"},
+		{"app_id": "cli_main", "app_secret": "secret"}
+	]}}`), config)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, config.Validate())
+	// The main app is not added twice
+	client := core.NewClient(config.Feishu.AppId, config.Feishu.AppSecret, core.WithAppCredentials(config.Feishu.Apps))
+	assert.Equal(t, 2, client.Apps())
+
+	config.Feishu.Apps = append(config.Feishu.Apps, core.AppCredential{AppID: "cli_third"})
+	assert.Error(t, config.Validate())
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"context"
+
+	"github.com/chyroc/lark"
+)
+
+// PageFetcher requests a page of a paginated API, pageToken is "" for the
+// first page. It returns the items of the page, the token of the next page
+// and whether there are more pages.
+type PageFetcher[T any] func(ctx context.Context, pageToken string) (items []T, next string, hasMore bool, err error)
+
+// PageCursor iterates the items of a paginated API, the pages are requested
+// as the items are consumed:
+//
+//	cursor := client.FolderFiles(folderToken)
+//	for cursor.Next(ctx) {
+//		file := cursor.Item()
+//	}
+//	if err := cursor.Err(); err != nil {
+//		...
+//	}
+//
+// The iteration ends at the last page, at a page without the token of the
+// next one, or at a token already requested, which would repeat the pages.
+type PageCursor[T any] struct {
+	fetch PageFetcher[T]
+	token string
+	page  []T
+	item  T
+	// requested are the page tokens already requested
+	requested map[string]bool
+	last      bool
+	err       error
+}
+
+// NewPageCursor returns a cursor starting at the page of pageToken, "" for
+// the first page
+func NewPageCursor[T any](pageToken string, fetch PageFetcher[T]) *PageCursor[T] {
+	return &PageCursor[T]{fetch: fetch, token: pageToken, requested: make(map[string]bool)}
+}
+
+// Next moves to the next item, requesting the next page if needed. It
+// returns false at the end of the items or on an error, see Err.
+func (c *PageCursor[T]) Next(ctx context.Context) bool {
+	for len(c.page) == 0 {
+		if c.last || c.err != nil {
+			return false
+		}
+		c.requested[c.token] = true
+		items, next, hasMore, err := c.fetch(ctx, c.token)
+		if err != nil {
+			c.err = err
+			return false
+		}
+		c.page = items
+		c.last = !hasMore || next == "" || c.requested[next]
+		c.token = next
+	}
+	c.item = c.page[0]
+	c.page = c.page[1:]
+	return true
+}
+
+// Item returns the current item
+func (c *PageCursor[T]) Item() T {
+	return c.item
+}
+
+// Err returns the error of the request which ended the iteration
+func (c *PageCursor[T]) Err() error {
+	return c.err
+}
+
+// All returns the remaining items of all the pages
+func (c *PageCursor[T]) All(ctx context.Context) ([]T, error) {
+	items := make([]T, 0)
+	for c.Next(ctx) {
+		items = append(items, c.Item())
+	}
+	return items, c.Err()
+}
+
+// FolderFiles iterates the files of a folder of the drive, an empty token
+// for the root folder of the personal space
+func (c *Client) FolderFiles(folderToken string) *PageCursor[*lark.GetDriveFileListRespFile] {
+	return NewPageCursor("", c.folderFilesFetcher(folderToken))
+}
+
+func (c *Client) folderFilesFetcher(folderToken string) PageFetcher[*lark.GetDriveFileListRespFile] {
+	// The pages are requested with the same app
+	larkClient := c.lark()
+	return func(ctx context.Context, pageToken string) ([]*lark.GetDriveFileListRespFile, string, bool, error) {
+		req := &lark.GetDriveFileListReq{}
+		if folderToken != "" {
+			req.FolderToken = &folderToken
+		}
+		if pageToken != "" {
+			req.PageToken = &pageToken
+		}
+		resp, _, err := larkClient.Drive.GetDriveFileList(ctx, req)
+		if err != nil {
+			return nil, "", false, err
+		}
+		return resp.Files, resp.NextPageToken, resp.HasMore, nil
+	}
+}
+
+// WikiNodes iterates the child nodes of a wiki node, or the top level nodes
+// of the space if parentNodeToken is nil
+func (c *Client) WikiNodes(spaceID string, parentNodeToken *string) *PageCursor[*lark.GetWikiNodeListRespItem] {
+	larkClient := c.lark()
+	return NewPageCursor("", func(ctx context.Context, pageToken string) ([]*lark.GetWikiNodeListRespItem, string, bool, error) {
+		req := &lark.GetWikiNodeListReq{SpaceID: spaceID, ParentNodeToken: parentNodeToken}
+		if pageToken != "" {
+			req.PageToken = &pageToken
+		}
+		resp, _, err := larkClient.Drive.GetWikiNodeList(ctx, req)
+		if err != nil {
+			return nil, "", false, err
+		}
+		return resp.Items, resp.PageToken, resp.HasMore, nil
+	})
+}
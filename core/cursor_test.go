@@ -0,0 +1,113 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePage struct {
+	items   []string
+	next    string
+	hasMore bool
+	err     error
+}
+
+// fakeFetcher serves the pages by their tokens and records the requests
+func fakeFetcher(pages map[string]fakePage, requested *[]string) core.PageFetcher[string] {
+	return func(ctx context.Context, pageToken string) ([]string, string, bool, error) {
+		*requested = append(*requested, pageToken)
+		page := pages[pageToken]
+		return page.items, page.next, page.hasMore, page.err
+	}
+}
+
+func TestPageCursor(t *testing.T) {
+	failed := errors.New("failed")
+	tests := []struct {
+		name      string
+		start     string
+		pages     map[string]fakePage
+		items     []string
+		requested []string
+		err       error
+	}{
+		{
+			name:      "single page",
+			pages:     map[string]fakePage{"": {items: []string{"a", "b"}}},
+			items:     []string{"a", "b"},
+			requested: []string{""},
+		},
+		{
+			name: "multiple pages",
+			pages: map[string]fakePage{
+				"":   {items: []string{"a"}, next: "p2", hasMore: true},
+				"p2": {items: []string{"b"}, next: "p3", hasMore: true},
+				"p3": {items: []string{"c"}, next: "p4"},
+			},
+			items:     []string{"a", "b", "c"},
+			requested: []string{"", "p2", "p3"},
+		},
+		{
+			name: "empty page in the middle",
+			pages: map[string]fakePage{
+				"":   {items: []string{"a"}, next: "p2", hasMore: true},
+				"p2": {next: "p3", hasMore: true},
+				"p3": {items: []string{"c"}},
+			},
+			items:     []string{"a", "c"},
+			requested: []string{"", "p2", "p3"},
+		},
+		{
+			name:      "more pages without a token",
+			pages:     map[string]fakePage{"": {items: []string{"a"}, hasMore: true}},
+			items:     []string{"a"},
+			requested: []string{""},
+		},
+		{
+			name: "repeated token",
+			pages: map[string]fakePage{
+				"":   {items: []string{"a"}, next: "p2", hasMore: true},
+				"p2": {items: []string{"b"}, next: "p2", hasMore: true},
+			},
+			items:     []string{"a", "b"},
+			requested: []string{"", "p2"},
+		},
+		{
+			name:  "start token",
+			start: "p2",
+			pages: map[string]fakePage{
+				"p2": {items: []string{"b"}, next: "p3", hasMore: true},
+				"p3": {items: []string{"c"}},
+			},
+			items:     []string{"b", "c"},
+			requested: []string{"p2", "p3"},
+		},
+		{
+			name: "error on a later page",
+			pages: map[string]fakePage{
+				"":   {items: []string{"a"}, next: "p2", hasMore: true},
+				"p2": {err: failed},
+			},
+			items:     []string{"a"},
+			requested: []string{"", "p2"},
+			err:       failed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requested []string
+			cursor := core.NewPageCursor(tt.start, fakeFetcher(tt.pages, &requested))
+			items, err := cursor.All(context.Background())
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.items, items)
+			assert.Equal(t, tt.requested, requested)
+			// The cursor stays at the end
+			assert.False(t, cursor.Next(context.Background()))
+			assert.Equal(t, tt.requested, requested)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"regexp"
+	"strings"
+)
+
+// emojis maps the emoji short codes of feishu, i.e. the callout icons, the
+// reaction keys and the "[微笑]" codes of the messages, to unicode emojis
+var emojis = map[string]string{
+	// Callout icons, named after the common emoji short codes
+	"100":                      "💯",
+	"bar_chart":                "📊",
+	"bell":                     "🔔",
+	"book":                     "📖",
+	"bookmark":                 "🔖",
+	"books":                    "📚",
+	"bulb":                     "💡",
+	"calendar":                 "📅",
+	"chart_with_upwards_trend": "📈",
+	"clap":                     "👏",
+	"construction":             "🚧",
+	"exclamation":              "❗",
+	"eyes":                     "👀",
+	"fire":                     "🔥",
+	"gift":                     "🎁",
+	"grinning":                 "😀",
+	"heart":                    "❤️",
+	"heavy_check_mark":         "✔️",
+	"information_source":       "ℹ️",
+	"joy":                      "😂",
+	"key":                      "🔑",
+	"link":                     "🔗",
+	"lock":                     "🔒",
+	"mag":                      "🔍",
+	"memo":                     "📝",
+	"muscle":                   "💪",
+	"no_entry":                 "⛔",
+	"ok_hand":                  "👌",
+	"point_right":              "👉",
+	"pray":                     "🙏",
+	"pushpin":                  "📌",
+	"question":                 "❓",
+	"rocket":                   "🚀",
+	"round_pushpin":            "📍",
+	"smile":                    "😄",
+	"smiley":                   "😃",
+	"sparkles":                 "✨",
+	"speech_balloon":           "💬",
+	"star":                     "⭐",
+	"tada":                     "🎉",
+	"thinking_face":            "🤔",
+	"thumbsup":                 "👍",
+	"warning":                  "⚠️",
+	"white_check_mark":         "✅",
+	"x":                        "❌",
+	"zap":                      "⚡",
+
+	// Reaction keys
+	"APPLAUSE":    "👏",
+	"CheckMark":   "✅",
+	"CrossMark":   "❌",
+	"FINGERHEART": "🫰",
+	"HEART":       "❤️",
+	"JIAYI":       "➕",
+	"LAUGH":       "😆",
+	"LOL":         "😂",
+	"MUSCLE":      "💪",
+	"OK":          "👌",
+	"PARTY":       "🎉",
+	"SMILE":       "😊",
+	"THANKS":      "🙏",
+	"THINKING":    "🤔",
+	"THUMBSUP":    "👍",
+	"WOW":         "😮",
+
+	// Codes of the messages
+	"[OK]": "👌",
+	"[赞]":  "👍",
+	"[微笑]": "😊",
+	"[大笑]": "😆",
+	"[笑哭]": "😂",
+	"[捂脸]": "🤦",
+	"[思考]": "🤔",
+	"[流泪]": "😢",
+	"[生气]": "😠",
+	"[惊讶]": "😮",
+	"[害羞]": "😳",
+	"[鼓掌]": "👏",
+	"[握手]": "🤝",
+	"[感谢]": "🙏",
+	"[加油]": "💪",
+	"[庆祝]": "🎉",
+	"[爱心]": "❤️",
+	"[玫瑰]": "🌹",
+	"[强]":  "💪",
+	"[完成]": "✅",
+}
+
+// Emoji returns the unicode emoji of a feishu emoji id, unknown ids are kept
+// as a ":id:" short code so that they are not lost
+func Emoji(id string) string {
+	if id == "" {
+		return ""
+	}
+	if emoji, ok := emojis[id]; ok {
+		return emoji
+	}
+	if emoji, ok := emojis[strings.ToLower(id)]; ok {
+		return emoji
+	}
+	return ":" + id + ":"
+}
+
+var emojiCodeRegexp = regexp.MustCompile(`:[a-z0-9_+\-]+:|\[[^\[\]\s]{1,4}\]`)
+
+// ReplaceEmojiCodes replaces the known ":bulb:" and "[微笑]" codes in a text
+// with unicode emojis, the other text is left untouched
+func ReplaceEmojiCodes(text string) string {
+	if !strings.ContainsAny(text, ":[") {
+		return text
+	}
+	return emojiCodeRegexp.ReplaceAllStringFunc(text, func(code string) string {
+		key := code
+		if strings.HasPrefix(code, ":") {
+			key = strings.Trim(code, ":")
+		}
+		if emoji, ok := emojis[key]; ok {
+			return emoji
+		}
+		return code
+	})
+}
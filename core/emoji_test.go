@@ -0,0 +1,22 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmoji(t *testing.T) {
+	assert.Equal(t, "💡", core.Emoji("bulb"))
+	assert.Equal(t, "👍", core.Emoji("THUMBSUP"))
+	assert.Equal(t, ":custom_emoji:", core.Emoji("custom_emoji"))
+	assert.Equal(t, "", core.Emoji(""))
+}
+
+func TestReplaceEmojiCodes(t *testing.T) {
+	assert.Equal(t, "好的👍 收到😊", core.ReplaceEmojiCodes("好的[赞] 收到[微笑]"))
+	assert.Equal(t, "注意⚠️", core.ReplaceEmojiCodes("注意:warning:"))
+	// Unknown codes and plain text are untouched
+	assert.Equal(t, "[链接] at 10:30:00 :unknown:", core.ReplaceEmojiCodes("[链接] at 10:30:00 :unknown:"))
+}
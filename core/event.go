@@ -0,0 +1,31 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/chyroc/lark"
+)
+
+// DocumentEventHandler is called when a subscribed document is edited
+type DocumentEventHandler func(ctx context.Context, fileToken, fileType string)
+
+// HandleDocumentEvents registers the handler of the document edit events
+func (c *Client) HandleDocumentEvents(handler DocumentEventHandler) {
+	c.larkClient.EventCallback.HandlerEventV2DriveFileEditV1(func(ctx context.Context, cli *lark.Lark, schema string, header *lark.EventHeaderV2, event *lark.EventV2DriveFileEditV1) (string, error) {
+		handler(ctx, event.FileToken, string(event.FileType))
+		return "", nil
+	})
+}
+
+// ListenEventCallback handles an event callback request of the open platform.
+// The payload is decrypted with the encrypt key set by WithEventCallback, and
+// the X-Lark-Signature header is checked if verifySignature is true.
+func (c *Client) ListenEventCallback(ctx context.Context, header http.Header, body io.Reader, writer http.ResponseWriter, verifySignature bool) {
+	if verifySignature {
+		c.larkClient.EventCallback.ListenSecurityCallback(ctx, header, body, writer)
+	} else {
+		c.larkClient.EventCallback.ListenCallback(ctx, body, writer)
+	}
+}
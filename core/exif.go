@@ -0,0 +1,170 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// SetStripEXIF removes the exif and the other metadata of the downloaded
+// images, see StripEXIF
+func (c *Client) SetStripEXIF(strip bool) {
+	c.stripEXIF.Store(strip)
+}
+
+// imageContent returns the content of a downloaded image, without its
+// metadata if SetStripEXIF is set
+func (c *Client) imageContent(r io.Reader) io.Reader {
+	if !c.stripEXIF.Load() {
+		return r
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		// The error is returned by the next read
+		return io.MultiReader(bytes.NewReader(data), errReader{err})
+	}
+	return bytes.NewReader(StripEXIF(data))
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// jpegMetadataPrefixes are the APP1 segments removed from a jpeg, the exif
+// and the xmp which hold the location, the camera and so on
+var jpegMetadataPrefixes = [][]byte{
+	[]byte("Exif\x00"),
+	[]byte("http://ns.adobe.com/xap/1.0/\x00"),
+	[]byte("http://ns.adobe.com/xmp/extension/\x00"),
+}
+
+// StripEXIF removes the exif and the other metadata from a jpeg, png or
+// webp image without decoding it, the pixels and the color profile are
+// kept. The orientation of the exif is lost as well. The other images and a
+// malformed image are returned unchanged.
+func StripEXIF(data []byte) []byte {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		if stripped, ok := stripJPEG(data); ok {
+			return stripped
+		}
+	case bytes.HasPrefix(data, pngSignature):
+		if stripped, ok := stripPNG(data); ok {
+			return stripped
+		}
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		if stripped, ok := stripWebP(data); ok {
+			return stripped
+		}
+	}
+	return data
+}
+
+// stripJPEG drops the exif and xmp APP1 segments and the APP13 segment of
+// the IPTC, the segments after the start of scan are copied as is
+func stripJPEG(data []byte) ([]byte, bool) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	i := 2
+	for i < len(data) {
+		if data[i] != 0xff || i+1 >= len(data) {
+			return nil, false
+		}
+		marker := data[i+1]
+		// The markers without a length
+		if marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7) || marker == 0xff {
+			out.WriteByte(data[i])
+			i++
+			continue
+		}
+		if i+4 > len(data) {
+			return nil, false
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:i+4]))
+		if end > len(data) {
+			return nil, false
+		}
+		if marker == 0xda {
+			// The start of scan, the compressed data follows
+			out.Write(data[i:])
+			return out.Bytes(), true
+		}
+		payload := data[i+4 : end]
+		drop := marker == 0xed
+		if marker == 0xe1 {
+			for _, prefix := range jpegMetadataPrefixes {
+				if bytes.HasPrefix(payload, prefix) {
+					drop = true
+				}
+			}
+		}
+		if !drop {
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	return out.Bytes(), true
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngMetadataChunks are the chunks of the exif, the texts holding the xmp
+// and the comments, and the modification time
+var pngMetadataChunks = map[string]bool{"eXIf": true, "tEXt": true, "zTXt": true, "iTXt": true, "tIME": true}
+
+func stripPNG(data []byte) ([]byte, bool) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+	i := len(pngSignature)
+	for i < len(data) {
+		if i+8 > len(data) {
+			return nil, false
+		}
+		// The length, the type, the data and the crc
+		end := i + 12 + int(binary.BigEndian.Uint32(data[i:i+4]))
+		if end > len(data) || end < i {
+			return nil, false
+		}
+		if !pngMetadataChunks[string(data[i+4:i+8])] {
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	return out.Bytes(), true
+}
+
+// stripWebP drops the EXIF and XMP chunks and clears their flags in the VP8X
+// chunk, the size of the RIFF container is updated
+func stripWebP(data []byte) ([]byte, bool) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+	i := 12
+	for i < len(data) {
+		if i+8 > len(data) {
+			return nil, false
+		}
+		size := int(binary.LittleEndian.Uint32(data[i+4 : i+8]))
+		// The chunks are padded to an even size
+		end := i + 8 + size + size%2
+		if end > len(data) || end < i {
+			return nil, false
+		}
+		switch string(data[i : i+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte{}, data[i:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04
+			}
+			out.Write(chunk)
+		default:
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	stripped := out.Bytes()
+	binary.LittleEndian.PutUint32(stripped[4:8], uint32(len(stripped)-8))
+	return stripped, true
+}
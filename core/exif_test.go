@@ -0,0 +1,79 @@
+package core_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripEXIFJPEG(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if !assert.NoError(t, jpeg.Encode(buf, image.NewGray(image.Rect(0, 0, 4, 4)), nil)) {
+		return
+	}
+	original := buf.Bytes()
+	segment := func(marker byte, payload string) []byte {
+		data := []byte{0xff, marker, 0, 0}
+		binary.BigEndian.PutUint16(data[2:], uint16(len(payload)+2))
+		return append(data, payload...)
+	}
+	// The exif and the xmp after the SOI, the ICC profile is kept
+	withEXIF := append([]byte{}, original[:2]...)
+	withEXIF = append(withEXIF, segment(0xe1, "Exif\x00\x00GPS")...)
+	withEXIF = append(withEXIF, segment(0xe1, "http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta/>")...)
+	withEXIF = append(withEXIF, segment(0xe2, "ICC_PROFILE\x00")...)
+	withEXIF = append(withEXIF, original[2:]...)
+
+	stripped := core.StripEXIF(withEXIF)
+	assert.NotContains(t, string(stripped), "GPS")
+	assert.NotContains(t, string(stripped), "xmpmeta")
+	assert.Contains(t, string(stripped), "ICC_PROFILE")
+	_, err := jpeg.Decode(bytes.NewReader(stripped))
+	assert.NoError(t, err)
+	assert.Equal(t, original, core.StripEXIF(original))
+}
+
+func TestStripEXIFPNG(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if !assert.NoError(t, png.Encode(buf, image.NewGray(image.Rect(0, 0, 4, 4)))) {
+		return
+	}
+	original := buf.Bytes()
+	chunk := []byte{0, 0, 0, 3, 'e', 'X', 'I', 'f', 'G', 'P', 'S', 0, 0, 0, 0}
+	// The exif chunk after the IHDR chunk of 25 bytes
+	withEXIF := append(append(append([]byte{}, original[:33]...), chunk...), original[33:]...)
+
+	stripped := core.StripEXIF(withEXIF)
+	assert.Equal(t, original, stripped)
+	_, err := png.Decode(bytes.NewReader(stripped))
+	assert.NoError(t, err)
+}
+
+func TestStripEXIFWebP(t *testing.T) {
+	chunk := func(fourcc, data string) string {
+		size := make([]byte, 4)
+		binary.LittleEndian.PutUint32(size, uint32(len(data)))
+		if len(data)%2 == 1 {
+			data += "\x00"
+		}
+		return fourcc + string(size) + data
+	}
+	riff := func(chunks string) []byte {
+		size := make([]byte, 4)
+		binary.LittleEndian.PutUint32(size, uint32(len(chunks)+4))
+		return []byte("RIFF" + string(size) + "WEBP" + chunks)
+	}
+	webp := riff(chunk("VP8X", "\x0c\x00\x00\x00\x03\x00\x00\x03\x00\x00") + chunk("VP8L", "pixels") +
+		chunk("EXIF", "GPS") + chunk("XMP ", "<x:xmpmeta/>"))
+	assert.Equal(t, riff(chunk("VP8X", "\x00\x00\x00\x00\x03\x00\x00\x03\x00\x00")+chunk("VP8L", "pixels")),
+		core.StripEXIF(webp))
+
+	// A malformed image is returned unchanged
+	assert.Equal(t, webp[:20], core.StripEXIF(webp[:20]))
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/chyroc/lark"
+)
+
+// exportPollInterval is the interval to query the result of an export task
+var exportPollInterval = time.Second
+
+// ExportDocument converts a document with an export task of feishu, e.g. a
+// docx to pdf, and saves the exported file as filePath
+func (c *Client) ExportDocument(ctx context.Context, token, docType, extension, filePath string) error {
+	// The export task is only visible to the app which created it
+	larkClient := c.lark()
+	task, _, err := larkClient.Drive.CreateDriveExportTask(ctx, &lark.CreateDriveExportTaskReq{
+		FileExtension: extension,
+		Token:         token,
+		Type:          docType,
+	})
+	if err != nil {
+		return err
+	}
+
+	// The export task is asynchronous, wait until it finishes
+	var result *lark.GetDriveExportTaskRespResult
+	for result == nil {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(exportPollInterval):
+		}
+		resp, _, err := larkClient.Drive.GetDriveExportTask(ctx, &lark.GetDriveExportTaskReq{
+			Ticket: task.Ticket,
+			Token:  token,
+		})
+		if err != nil {
+			return err
+		}
+		switch status := resp.Result.JobStatus; status {
+		case 0:
+			result = resp.Result
+		case 1, 2:
+		default:
+			return fmt.Errorf("export task of %s failed with status %d: %s", token, status, resp.Result.JobErrorMsg)
+		}
+	}
+
+	resp, _, err := larkClient.Drive.DownloadDriveExportTask(ctx, &lark.DownloadDriveExportTaskReq{
+		FileToken: result.FileToken,
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return err
+	}
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	written, err := io.Copy(file, resp.File)
+	if err != nil {
+		return err
+	}
+	c.stats.addDownloadedBytes(written)
+	return nil
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Supported values of OutputConfig.ExternalLinks
+const (
+	// ExternalLinksPlain renders the external links as the other links
+	ExternalLinksPlain = "plain"
+	// ExternalLinksAnnotate appends ExternalLinkLabel to the external links
+	ExternalLinksAnnotate = "annotate"
+	// ExternalLinksList collects the external links at the end of the document
+	ExternalLinksList = "list"
+)
+
+// defaultExternalLinkLabel annotates the external links without a configured label
+const defaultExternalLinkLabel = "外部链接"
+
+// externalLinksHeading is the heading of the list of the external links
+const externalLinksHeading = "## 外部资源"
+
+// internalDomains are the domains of feishu and lark, the links to the other
+// hosts are external
+var internalDomains = []string{"feishu.cn", "feishu.net", "larksuite.com", "larkoffice.com"}
+
+// IsExternalLink reports whether a link points outside of feishu/lark, e.g.
+// to a third-party cloud drive. The relative links and the anchors are not.
+func IsExternalLink(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range internalDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return false
+		}
+	}
+	return true
+}
+
+// externalLink is an external link of a document for the list at its end
+type externalLink struct {
+	text string
+	url  string
+}
+
+// noteExternalLink records an external link and returns the annotation to
+// append to it, if any
+func (p *Parser) noteExternalLink(text, link string) string {
+	switch p.config.ExternalLinks {
+	case ExternalLinksAnnotate:
+		label := p.config.ExternalLinkLabel
+		if label == "" {
+			label = defaultExternalLinkLabel
+		}
+		return fmt.Sprintf("（%s）", label)
+	case ExternalLinksList:
+		for _, l := range p.externalLinks {
+			if l.url == link {
+				return ""
+			}
+		}
+		p.externalLinks = append(p.externalLinks, externalLink{text: strings.TrimSpace(text), url: link})
+	}
+	return ""
+}
+
+// externalLinksList renders the collected external links as a list under a
+// heading, or nothing if there are none
+func (p *Parser) externalLinksList() string {
+	if len(p.externalLinks) == 0 {
+		return ""
+	}
+	buf := new(strings.Builder)
+	buf.WriteString(externalLinksHeading + "\n\n")
+	for _, l := range p.externalLinks {
+		text := l.text
+		if text == "" {
+			text = l.url
+		}
+		buf.WriteString(fmt.Sprintf("- [%s](%s)\n", text, l.url))
+	}
+	buf.WriteString("\n")
+	return buf.String()
+}
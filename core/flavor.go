@@ -0,0 +1,120 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/chyroc/lark"
+)
+
+// Flavor decides how the markdown output renders the styles and blocks that
+// are not covered by every markdown dialect
+//
+//	| feature       | gfm            | commonmark  | html-rich           |
+//	| ------------- | -------------- | ----------- | ------------------- |
+//	| bold/italic   | ** / _         | ** / _      | <strong> / <em>     |
+//	| strikethrough | ~~             | <del>       | <del>               |
+//	| underline     | <u>            | <u>         | <u>                 |
+//	| highlight     | plain text     | plain text  | <mark>              |
+//	| task list     | - [x]          | - ☑         | <input> checkbox    |
+//	| table         | pipe table (*) | <table>     | <table>             |
+//
+// (*) tables with merged cells fall back to <table>
+type Flavor string
+
+const (
+	FlavorGFM        Flavor = "gfm"
+	FlavorCommonMark Flavor = "commonmark"
+	FlavorHTMLRich   Flavor = "html-rich"
+)
+
+// ParseFlavor validates the name of a flavor
+func ParseFlavor(name string) (Flavor, error) {
+	switch flavor := Flavor(name); flavor {
+	case FlavorGFM, FlavorCommonMark, FlavorHTMLRich:
+		return flavor, nil
+	}
+	return "", fmt.Errorf("unsupported flavor: %s", name)
+}
+
+// OutputFlavor returns the configured flavor, use_html_tags selects html-rich
+// when no flavor is configured
+func (o OutputConfig) OutputFlavor() (Flavor, error) {
+	if o.Flavor == "" {
+		if o.UseHTMLTags {
+			return FlavorHTMLRich, nil
+		}
+		return FlavorGFM, nil
+	}
+	return ParseFlavor(o.Flavor)
+}
+
+// textStyle returns the opening and closing marks of a text style, the link is
+// the outermost and inline code the innermost
+func (f Flavor) textStyle(style *lark.DocxTextElementStyle) (string, string) {
+	var marks [][2]string
+	if style.Bold {
+		if f == FlavorHTMLRich {
+			marks = append(marks, [2]string{"<strong>", "</strong>"})
+		} else {
+			marks = append(marks, [2]string{"**", "**"})
+		}
+	}
+	if style.Italic {
+		if f == FlavorHTMLRich {
+			marks = append(marks, [2]string{"<em>", "</em>"})
+		} else {
+			marks = append(marks, [2]string{"_", "_"})
+		}
+	}
+	if style.Strikethrough {
+		if f == FlavorGFM {
+			marks = append(marks, [2]string{"~~", "~~"})
+		} else {
+			marks = append(marks, [2]string{"<del>", "</del>"})
+		}
+	}
+	if style.Underline {
+		marks = append(marks, [2]string{"<u>", "</u>"})
+	}
+	if style.BackgroundColor != 0 && f == FlavorHTMLRich {
+		marks = append(marks, [2]string{"<mark>", "</mark>"})
+	}
+	if style.InlineCode {
+		marks = append(marks, [2]string{"`", "`"})
+	}
+
+	open, close := "", ""
+	if link := style.Link; link != nil {
+		open = "["
+	}
+	for i := range marks {
+		open += marks[i][0]
+		close = marks[i][1] + close
+	}
+	return open, close
+}
+
+// taskMarker returns the list marker of a todo block
+func (f Flavor) taskMarker(done bool) string {
+	switch f {
+	case FlavorCommonMark:
+		if done {
+			return "- ☑ "
+		}
+		return "- ☐ "
+	case FlavorHTMLRich:
+		if done {
+			return `- <input type="checkbox" checked disabled> `
+		}
+		return `- <input type="checkbox" disabled> `
+	}
+	if done {
+		return "- [x] "
+	}
+	return "- [ ] "
+}
+
+// htmlTable reports whether a table is rendered as html rather than a pipe table
+func (f Flavor) htmlTable(merged bool) bool {
+	return f != FlavorGFM || merged
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// FrontMatterField is a key and value of a front matter
+type FrontMatterField struct {
+	Key   string
+	Value interface{}
+}
+
+// FrontMatter renders the fields as a yaml front matter, the values are
+// written as json which is valid yaml. A field replaces the value of an
+// earlier field with the same key, so the later fields take precedence.
+func FrontMatter(fields ...FrontMatterField) string {
+	merged := make([]FrontMatterField, 0, len(fields))
+	index := make(map[string]int)
+	for _, field := range fields {
+		if i, ok := index[field.Key]; ok {
+			merged[i].Value = field.Value
+			continue
+		}
+		index[field.Key] = len(merged)
+		merged = append(merged, field)
+	}
+
+	buf := new(strings.Builder)
+	buf.WriteString("---\n")
+	for _, field := range merged {
+		value, err := json.Marshal(field.Value)
+		if err != nil {
+			continue
+		}
+		buf.WriteString(fmt.Sprintf("%s: %s\n", field.Key, value))
+	}
+	buf.WriteString("---\n\n")
+	return buf.String()
+}
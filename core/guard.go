@@ -0,0 +1,118 @@
+package core
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// LimitsConfig pauses the new downloads of a batch/wiki download or of the
+// server while the output directory or the memory of the process exceeds
+// its limit, 0 for no limit
+type LimitsConfig struct {
+	DiskQuotaMB   int64 `json:"disk_quota_mb"`
+	MemoryLimitMB int64 `json:"memory_limit_mb"`
+}
+
+// diskUsageTTL is how long the size of the output directory is reused, the
+// directory is walked at most once within it
+const diskUsageTTL = 5 * time.Second
+
+// ResourceGuard checks the size of an output directory and the memory of the
+// process against the limits. It is safe to use concurrently.
+type ResourceGuard struct {
+	dir         string
+	diskQuota   int64
+	memoryLimit uint64
+
+	mu        sync.Mutex
+	diskUsage int64
+	checkedAt time.Time
+}
+
+// NewResourceGuard returns a guard of the directory, nil if there is no limit
+func NewResourceGuard(dir string, limits LimitsConfig) *ResourceGuard {
+	if limits.DiskQuotaMB <= 0 && limits.MemoryLimitMB <= 0 {
+		return nil
+	}
+	g := &ResourceGuard{dir: dir}
+	if limits.DiskQuotaMB > 0 {
+		g.diskQuota = limits.DiskQuotaMB << 20
+	}
+	if limits.MemoryLimitMB > 0 {
+		g.memoryLimit = uint64(limits.MemoryLimitMB) << 20
+	}
+	return g
+}
+
+// Check returns an error describing the exceeded limit, nil within the
+// limits or for a nil guard
+func (g *ResourceGuard) Check() error {
+	if g == nil {
+		return nil
+	}
+	if g.memoryLimit > 0 {
+		used := processMemory()
+		if used > g.memoryLimit {
+			// Return the garbage to the system before giving up
+			debug.FreeOSMemory()
+			used = processMemory()
+		}
+		if used > g.memoryLimit {
+			return fmt.Errorf("the memory of the process %s exceeds the limit %s", formatBytes(int64(used)), formatBytes(int64(g.memoryLimit)))
+		}
+	}
+	if g.diskQuota > 0 {
+		used, err := g.dirSize()
+		if err != nil {
+			return fmt.Errorf("failed to measure %s: %w", g.dir, err)
+		}
+		if used > g.diskQuota {
+			return fmt.Errorf("the output directory %s of %s exceeds the quota %s", g.dir, formatBytes(used), formatBytes(g.diskQuota))
+		}
+	}
+	return nil
+}
+
+// processMemory returns the memory obtained from the system and not released
+func processMemory() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.Sys - stats.HeapReleased
+}
+
+// dirSize returns the size of the files under the directory, measured again
+// after diskUsageTTL
+func (g *ResourceGuard) dirSize() (int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.checkedAt.IsZero() && time.Since(g.checkedAt) < diskUsageTTL {
+		return g.diskUsage, nil
+	}
+	var size int64
+	err := filepath.WalkDir(g.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// A file removed during the walk
+			if path != g.dir {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	g.diskUsage, g.checkedAt = size, time.Now()
+	return size, nil
+}
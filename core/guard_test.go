@@ -0,0 +1,38 @@
+package core_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceGuard(t *testing.T) {
+	assert.Nil(t, core.NewResourceGuard(t.TempDir(), core.LimitsConfig{}))
+	var none *core.ResourceGuard
+	assert.NoError(t, none.Check())
+
+	dir := t.TempDir()
+	if !assert.NoError(t, os.MkdirAll(filepath.Join(dir, "static"), 0o755)) {
+		return
+	}
+	if !assert.NoError(t, os.WriteFile(filepath.Join(dir, "static", "big.bin"), make([]byte, 3<<20), 0o644)) {
+		return
+	}
+	assert.NoError(t, core.NewResourceGuard(dir, core.LimitsConfig{DiskQuotaMB: 4}).Check())
+	err := core.NewResourceGuard(dir, core.LimitsConfig{DiskQuotaMB: 2}).Check()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "exceeds the quota 2.0 MiB")
+	}
+	// A directory not created yet is empty
+	assert.NoError(t, core.NewResourceGuard(filepath.Join(dir, "missing"), core.LimitsConfig{DiskQuotaMB: 1}).Check())
+
+	// The runtime alone takes more than 1 MB
+	err = core.NewResourceGuard(dir, core.LimitsConfig{MemoryLimitMB: 1}).Check()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "memory of the process")
+	}
+	assert.NoError(t, core.NewResourceGuard(dir, core.LimitsConfig{MemoryLimitMB: 1 << 20}).Check())
+}
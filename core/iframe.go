@@ -0,0 +1,69 @@
+package core
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Supported values of OutputConfig.IframeMode
+const (
+	// IframeModeNotice describes the embedded content with a notice
+	IframeModeNotice = "notice"
+	// IframeModeLink renders a clickable link to the embedded content
+	IframeModeLink = "link"
+	// IframeModeEmbed renders the embed code of the platform, e.g. Figma
+	IframeModeEmbed = "embed"
+)
+
+// iframeTypeNames are the names of the platforms by iframe type
+var iframeTypeNames = map[int]string{
+	1:  "哔哩哔哩",
+	2:  "西瓜视频",
+	3:  "优酷",
+	4:  "Airtable",
+	5:  "百度地图",
+	6:  "高德地图",
+	7:  "TikTok",
+	8:  "Figma",
+	9:  "墨刀",
+	10: "Canva",
+	11: "CodePen",
+	12: "飞书问卷",
+	13: "金数据",
+	14: "谷歌地图",
+	15: "YouTube",
+	99: "其他",
+}
+
+var (
+	codePenRegexp  = regexp.MustCompile(`^https?://codepen\.io/([^/]+)/(?:pen|full|details)/([^/?#]+)`)
+	youTubeRegexp  = regexp.MustCompile(`^https?://(?:www\.)?(?:youtube\.com/watch\?(?:.*&)?v=|youtu\.be/)([\w-]+)`)
+	bilibiliRegexp = regexp.MustCompile(`^https?://(?:www\.)?bilibili\.com/video/(BV\w+)`)
+)
+
+// iframeEmbedURL returns the url to embed the content of a platform in an
+// iframe, following the embed code of Figma, CodePen, YouTube and Bilibili
+func iframeEmbedURL(iframeType int, link string) string {
+	switch {
+	case iframeType == 8 || strings.Contains(link, "figma.com/"):
+		return "https://www.figma.com/embed?embed_host=share&url=" + url.QueryEscape(link)
+	case codePenRegexp.MatchString(link):
+		m := codePenRegexp.FindStringSubmatch(link)
+		return fmt.Sprintf("https://codepen.io/%s/embed/%s?default-tab=result", m[1], m[2])
+	case youTubeRegexp.MatchString(link):
+		return "https://www.youtube.com/embed/" + youTubeRegexp.FindStringSubmatch(link)[1]
+	case bilibiliRegexp.MatchString(link):
+		return "https://player.bilibili.com/player.html?bvid=" + bilibiliRegexp.FindStringSubmatch(link)[1]
+	}
+	return link
+}
+
+// iframeEmbedCode returns the html embed code of the content
+func iframeEmbedCode(iframeType int, link string) string {
+	return fmt.Sprintf(
+		"<iframe src=\"%s\" width=\"800\" height=\"450\" frameborder=\"0\" allowfullscreen></iframe>\n",
+		html.EscapeString(iframeEmbedURL(iframeType, link)))
+}
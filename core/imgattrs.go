@@ -0,0 +1,56 @@
+package core
+
+import (
+	"fmt"
+	"html"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/chyroc/lark"
+)
+
+// imageTag renders an image as <img> with loading="lazy", and the width and
+// the height of the image metadata if known, to avoid the layout shifts of
+// the published pages
+func (p *Parser) imageTag(img *lark.DocxBlockImage, alt string) string {
+	buf := new(strings.Builder)
+	buf.WriteString(fmt.Sprintf(`<img src="%s" alt="%s" loading="lazy"`, img.Token, html.EscapeString(alt)))
+	if img.Width > 0 && img.Height > 0 {
+		buf.WriteString(fmt.Sprintf(` width="%d" height="%d"`, img.Width, img.Height))
+	}
+	buf.WriteString(">")
+	return buf.String()
+}
+
+var imgTagRegexp = regexp.MustCompile(`<img src="([^"]*)"([^>]*)>`)
+
+// FillImageSizes adds the width and the height to the <img> tags without
+// them, e.g. the images whose metadata has no size, size returns the size of
+// the image at the src or false if unknown
+func FillImageSizes(markdown string, size func(src string) (int, int, bool)) string {
+	return imgTagRegexp.ReplaceAllStringFunc(markdown, func(tag string) string {
+		m := imgTagRegexp.FindStringSubmatch(tag)
+		if strings.Contains(m[2], " width=") {
+			return tag
+		}
+		width, height, ok := size(m[1])
+		if !ok {
+			return tag
+		}
+		return fmt.Sprintf(`<img src="%s"%s width="%d" height="%d">`, m[1], m[2], width, height)
+	})
+}
+
+// ImageSize decodes the width and the height of a png, jpeg or gif image
+func ImageSize(r io.Reader) (int, int, bool) {
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return 0, 0, false
+	}
+	return config.Width, config.Height, true
+}
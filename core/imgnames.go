@@ -0,0 +1,80 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Wsine/feishu2md/utils"
+)
+
+// Supported values of OutputConfig.ImageNaming
+const (
+	ImageNamingToken    = "token"
+	ImageNamingOriginal = "original"
+)
+
+// imageNamer names the downloaded images by their tokens, or by their
+// original file names with a suffix for the conflicts in a directory
+type imageNamer struct {
+	mu       sync.Mutex
+	original bool
+	// used are the file names taken in each directory
+	used map[string]map[string]bool
+	// paths are the paths of the images already named, by directory and token
+	paths map[string]string
+}
+
+// SetImageNaming names the downloaded images by their "token", the default,
+// or their "original" file names
+func (c *Client) SetImageNaming(naming string) {
+	c.images.mu.Lock()
+	defer c.images.mu.Unlock()
+	c.images.original = naming == ImageNamingOriginal
+}
+
+// imagePath returns the path of an image in the directory, head is the
+// beginning of the content to recognize an svg without an extension. An
+// image downloaded twice keeps its path.
+func (c *Client) imagePath(dir, imgToken, filename string, head []byte) string {
+	ext := filepath.Ext(filename)
+	if ext == "" && isSVG(head) {
+		ext = ".svg"
+	}
+
+	n := &c.images
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.original {
+		return fmt.Sprintf("%s/%s%s", dir, imgToken, ext)
+	}
+	if n.paths == nil {
+		n.paths = make(map[string]string)
+		n.used = make(map[string]map[string]bool)
+	}
+	key := dir + "\x00" + imgToken
+	if path, ok := n.paths[key]; ok {
+		return path
+	}
+	name := utils.SanitizeFileName(strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)))
+	if name == "" || name == "." {
+		name = imgToken
+	}
+	if n.used[dir] == nil {
+		n.used[dir] = make(map[string]bool)
+	}
+	path := filepath.Join(dir, UniqueFileName(n.used[dir], name+ext))
+	n.paths[key] = path
+	return path
+}
+
+// isSVG reports whether the content looks like an svg image
+func isSVG(head []byte) bool {
+	head = bytes.TrimSpace(head)
+	if bytes.HasPrefix(head, []byte("<?xml")) || bytes.HasPrefix(head, []byte("<!--")) {
+		return bytes.Contains(head, []byte("<svg"))
+	}
+	return bytes.HasPrefix(head, []byte("<svg"))
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// IncrementalFileName is the archive of the files changed since the previous export
+const IncrementalFileName = "incremental.tar.gz"
+
+// IncrementalInfoName is the description of the increment inside the archive
+const IncrementalInfoName = "incremental.json"
+
+// IncrementalInfo describes an incremental archive. BaseManifest is the
+// SHA256 of the manifest.json the increment applies to, empty for the first
+// export whose increment contains all the files.
+type IncrementalInfo struct {
+	BaseManifest string   `json:"base_manifest"`
+	Manifest     string   `json:"manifest"`
+	Changed      []string `json:"changed"`
+	Deleted      []string `json:"deleted"`
+}
+
+// ManifestChecksum returns the SHA256 of the manifest file in the root, or
+// an empty string if there is none
+func ManifestChecksum(root string) (string, error) {
+	sum, err := fileChecksum(filepath.Join(root, ManifestFileName))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return sum, err
+}
+
+// WriteIncremental packs the changed files, the new manifest and the
+// incremental.json into incremental.tar.gz in the root directory. The info
+// records the base manifest, the changed and the deleted paths.
+func WriteIncremental(root string, info IncrementalInfo) error {
+	file, err := os.Create(filepath.Join(root, IncrementalFileName))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	writer := tar.NewWriter(gz)
+	if info.Changed == nil {
+		info.Changed = []string{}
+	}
+	if info.Deleted == nil {
+		info.Deleted = []string{}
+	}
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writer.WriteHeader(&tar.Header{
+		Name:    IncrementalInfoName,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return err
+	}
+
+	for _, name := range append([]string{ManifestFileName}, info.Changed...) {
+		if err := addTarFile(writer, root, name); err != nil {
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// addTarFile adds the file at the slash separated path relative to the root
+func addTarFile(writer *tar.Writer, root, name string) error {
+	src, err := os.Open(filepath.Join(root, filepath.FromSlash(name)))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	stat, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := tar.FileInfoHeader(stat, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if err := writer.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, src)
+	return err
+}
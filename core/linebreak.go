@@ -0,0 +1,102 @@
+package core
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Supported values of OutputConfig.LineBreak
+const (
+	// LineBreakKeep keeps the line breaks inside a text as soft breaks,
+	// which most renderers join into one line
+	LineBreakKeep      = "keep"
+	LineBreakSpaces    = "spaces"
+	LineBreakBackslash = "backslash"
+	LineBreakHTML      = "html"
+	// LineBreakJoin joins the lines, with a space unless between CJK characters
+	LineBreakJoin = "join"
+)
+
+// HardLineBreaks reports whether the line break style writes the markdown
+// hard breaks, which the formatting has to keep
+func (o OutputConfig) HardLineBreaks() bool {
+	return o.LineBreak == LineBreakSpaces || o.LineBreak == LineBreakBackslash
+}
+
+// breakLines renders the line breaks inside the content of a text run with
+// the line break style
+func (p *Parser) breakLines(content string) string {
+	if !strings.Contains(content, "\n") {
+		return content
+	}
+	switch p.config.LineBreak {
+	case LineBreakSpaces:
+		return strings.ReplaceAll(content, "\n", "  \n")
+	case LineBreakBackslash:
+		return strings.ReplaceAll(content, "\n", "\\\n")
+	case LineBreakHTML:
+		return strings.ReplaceAll(content, "\n", "<br/>\n")
+	case LineBreakJoin:
+		return joinLines(content)
+	}
+	return content
+}
+
+// joinLines joins the lines with a space, the lines ending or starting with
+// a CJK character are joined without one
+func joinLines(content string) string {
+	lines := strings.Split(content, "\n")
+	buf := new(strings.Builder)
+	for i, line := range lines {
+		if i > 0 && buf.Len() > 0 && line != "" {
+			last, _ := utf8.DecodeLastRuneInString(buf.String())
+			first, _ := utf8.DecodeRuneInString(line)
+			if !isCJK(last) && !isCJK(first) && !unicode.IsSpace(last) {
+				buf.WriteString(" ")
+			}
+		}
+		buf.WriteString(line)
+	}
+	return buf.String()
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) ||
+		(r >= 0x3000 && r <= 0x303f) || (r >= 0xff00 && r <= 0xffef)
+}
+
+// ApplyLineBreakStyle restores the hard breaks of the spaces style after the
+// formatting of the markdown, which writes them as backslashes. The code
+// blocks are kept verbatim.
+func ApplyLineBreakStyle(style, markdown string) string {
+	if style != LineBreakSpaces {
+		return markdown
+	}
+	buf := new(strings.Builder)
+	fence := ""
+	for _, line := range strings.SplitAfter(markdown, "\n") {
+		trimmed := strings.TrimLeft(line, " \t>")
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) && strings.TrimSpace(strings.TrimLeft(trimmed, fence[:1])) == "" {
+				fence = ""
+			}
+			buf.WriteString(line)
+			continue
+		}
+		if marker := fenceMarker(trimmed); marker != "" {
+			fence = marker
+			buf.WriteString(line)
+			continue
+		}
+		content := strings.TrimSuffix(line, "\n")
+		// An odd number of backslashes, an even one is escaped backslashes
+		backslashes := len(content) - len(strings.TrimRight(content, "\\"))
+		if backslashes%2 == 1 && strings.HasSuffix(line, "\n") {
+			line = content[:len(content)-1] + "  \n"
+		}
+		buf.WriteString(line)
+	}
+	return buf.String()
+}
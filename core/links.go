@@ -0,0 +1,136 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Supported values of OutputConfig.LinkStyle
+const (
+	LinkStyleInline    = "inline"
+	LinkStyleReference = "reference"
+	LinkStyleFootnote  = "footnote"
+)
+
+var inlineLinkRegexp = regexp.MustCompile(
+	`(!?)\[((?:[^\[\]]|\[[^\[\]]*\])*)\]\((<[^<>\n]*>|[^()\s]*(?:\([^()\s]*\)[^()\s]*)*)(?:\s+"([^"\n]*)")?\)`)
+
+// ReferenceLinks rewrites the inline links and images of a markdown document as
+// reference links, e.g. [text][1], whose definitions are collected at the end of
+// the document in the order of appearance. The code blocks and code spans are
+// left untouched.
+func ReferenceLinks(markdown string) string {
+	return rewriteLinks(markdown, false)
+}
+
+// FootnoteLinks moves the urls of the inline links of a markdown document into
+// footnotes, e.g. text[^1], listed at the end of the document. The images are
+// left untouched.
+func FootnoteLinks(markdown string) string {
+	return rewriteLinks(markdown, true)
+}
+
+// ApplyLinkStyle rewrites the links of a markdown document in the link style,
+// the inline links are kept for an empty or unknown style
+func ApplyLinkStyle(style, markdown string) string {
+	switch style {
+	case LinkStyleReference:
+		return ReferenceLinks(markdown)
+	case LinkStyleFootnote:
+		return FootnoteLinks(markdown)
+	}
+	return markdown
+}
+
+func rewriteLinks(markdown string, footnote bool) string {
+	refs := make(map[string]int)
+	definitions := new(strings.Builder)
+	replace := func(text string) string {
+		return inlineLinkRegexp.ReplaceAllStringFunc(text, func(link string) string {
+			m := inlineLinkRegexp.FindStringSubmatch(link)
+			if m[3] == "" || (footnote && m[1] != "") {
+				return link
+			}
+			key := m[3] + "\x00" + m[4]
+			n, ok := refs[key]
+			if !ok {
+				n = len(refs) + 1
+				refs[key] = n
+				if footnote {
+					definitions.WriteString(fmt.Sprintf("[^%d]: %s", n, strings.Trim(m[3], "<>")))
+					if m[4] != "" {
+						definitions.WriteString(" " + m[4])
+					}
+				} else {
+					definitions.WriteString(fmt.Sprintf("[%d]: %s", n, m[3]))
+					if m[4] != "" {
+						definitions.WriteString(fmt.Sprintf(" \"%s\"", m[4]))
+					}
+				}
+				definitions.WriteString("\n")
+			}
+			if footnote {
+				return fmt.Sprintf("%s[^%d]", m[2], n)
+			}
+			return fmt.Sprintf("%s[%s][%d]", m[1], m[2], n)
+		})
+	}
+
+	buf := new(strings.Builder)
+	fence := ""
+	for _, line := range strings.SplitAfter(markdown, "\n") {
+		trimmed := strings.TrimLeft(line, " \t>")
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) && strings.TrimSpace(strings.TrimLeft(trimmed, fence[:1])) == "" {
+				fence = ""
+			}
+			buf.WriteString(line)
+			continue
+		}
+		if marker := fenceMarker(trimmed); marker != "" {
+			fence = marker
+			buf.WriteString(line)
+			continue
+		}
+		writeOutsideCodeSpans(buf, line, replace)
+	}
+	if definitions.Len() == 0 {
+		return markdown
+	}
+
+	result := strings.TrimRight(buf.String(), "\n")
+	return result + "\n\n" + definitions.String()
+}
+
+// fenceMarker returns the opening fence of a code block line, e.g. "```"
+func fenceMarker(line string) string {
+	for _, c := range []string{"`", "~"} {
+		n := len(line) - len(strings.TrimLeft(line, c))
+		if n >= 3 {
+			return strings.Repeat(c, n)
+		}
+	}
+	return ""
+}
+
+// writeOutsideCodeSpans writes the line, applying replace to the text outside
+// of the `code spans`
+func writeOutsideCodeSpans(buf *strings.Builder, line string, replace func(string) string) {
+	for line != "" {
+		start := strings.Index(line, "`")
+		if start < 0 {
+			break
+		}
+		ticks := len(line[start:]) - len(strings.TrimLeft(line[start:], "`"))
+		end := strings.Index(line[start+ticks:], line[start:start+ticks])
+		if end < 0 {
+			break
+		}
+		end += start + 2*ticks
+		buf.WriteString(replace(line[:start]))
+		buf.WriteString(line[start:end])
+		line = line[end:]
+	}
+	buf.WriteString(replace(line))
+}
@@ -0,0 +1,32 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReferenceLinks(t *testing.T) {
+	markdown := "# Title\n\n" +
+		"See [doc](https://example.com/a) and ![image](static/a.png \"A\").\n\n" +
+		"Again [the doc](https://example.com/a), but not `[code](https://example.com/b)`.\n\n" +
+		"```\n[code](https://example.com/c)\n```\n"
+	expected := "# Title\n\n" +
+		"See [doc][1] and ![image][2].\n\n" +
+		"Again [the doc][1], but not `[code](https://example.com/b)`.\n\n" +
+		"```\n[code](https://example.com/c)\n```\n\n" +
+		"[1]: https://example.com/a\n" +
+		"[2]: static/a.png \"A\"\n"
+	assert.Equal(t, expected, core.ReferenceLinks(markdown))
+
+	// Nothing to rewrite
+	assert.Equal(t, "plain text\n", core.ReferenceLinks("plain text\n"))
+}
+
+func TestFootnoteLinks(t *testing.T) {
+	markdown := "See [doc](https://example.com/a) and ![image](static/a.png), [again](https://example.com/a).\n"
+	expected := "See doc[^1] and ![image](static/a.png), again[^1].\n\n" +
+		"[^1]: https://example.com/a\n"
+	assert.Equal(t, expected, core.FootnoteLinks(markdown))
+}
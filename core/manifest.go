@@ -0,0 +1,187 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// ManifestFileName is the name of the manifest file in the root of an export
+const ManifestFileName = "manifest.json"
+
+// ManifestEntry describes a file produced by an export
+type ManifestEntry struct {
+	Path      string `json:"path"`
+	NodeToken string `json:"node_token,omitempty"`
+	ObjToken  string `json:"obj_token,omitempty"`
+	ObjType   string `json:"obj_type,omitempty"`
+	Title     string `json:"title,omitempty"`
+	// Revision is the revision of a docx when it was exported
+	Revision int64 `json:"revision,omitempty"`
+	// The times of the wiki nodes, in unix seconds
+	ObjCreateTime  string `json:"obj_create_time,omitempty"`
+	ObjEditTime    string `json:"obj_edit_time,omitempty"`
+	NodeCreateTime string `json:"node_create_time,omitempty"`
+	// SHA256 is the checksum of the file content when the manifest was written
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// Manifest records the files produced by an export, relative to its root directory.
+// It is safe to add entries concurrently.
+type Manifest struct {
+	mu      sync.Mutex
+	root    string
+	Entries []ManifestEntry `json:"entries"`
+	// Directories maps the directories renamed by the slug to their titles
+	Directories map[string]string `json:"directories,omitempty"`
+}
+
+func NewManifest(root string) *Manifest {
+	return &Manifest{root: root, Entries: make([]ManifestEntry, 0)}
+}
+
+// ReadManifest reads the manifest in the root directory, an empty manifest is
+// returned if there is none yet
+func ReadManifest(root string) (*Manifest, error) {
+	manifest := NewManifest(root)
+	file, err := os.ReadFile(filepath.Join(root, ManifestFileName))
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(file, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Root returns the root directory of the manifest
+func (m *Manifest) Root() string {
+	return m.root
+}
+
+// Add records the file at filePath, the path of the entry is set relative to the root
+func (m *Manifest) Add(filePath string, entry ManifestEntry) {
+	rel, err := filepath.Rel(m.root, filePath)
+	if err != nil {
+		rel = filePath
+	}
+	entry.Path = filepath.ToSlash(rel)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Entries = append(m.Entries, entry)
+}
+
+// AddDirectory records the title of a directory whose name differs from it
+func (m *Manifest) AddDirectory(dirPath, title string) {
+	rel, err := filepath.Rel(m.root, dirPath)
+	if err != nil {
+		rel = dirPath
+	}
+	rel = filepath.ToSlash(rel)
+	if path.Base(rel) == title {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Directories == nil {
+		m.Directories = make(map[string]string)
+	}
+	m.Directories[rel] = title
+}
+
+// Write saves the manifest sorted by path into the root directory
+func (m *Manifest) Write() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sort.SliceStable(m.Entries, func(i, j int) bool { return m.Entries[i].Path < m.Entries[j].Path })
+	// The same file may be recorded more than once, e.g. a shared image
+	entries := m.Entries[:0]
+	for i, entry := range m.Entries {
+		if i == 0 || entry.Path != m.Entries[i-1].Path {
+			entries = append(entries, entry)
+		}
+	}
+	m.Entries = entries
+	for i, entry := range m.Entries {
+		if sum, err := fileChecksum(filepath.Join(m.root, filepath.FromSlash(entry.Path))); err == nil {
+			m.Entries[i].SHA256 = sum
+		}
+	}
+	if err := os.MkdirAll(m.root, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.root, ManifestFileName), data, 0o644)
+}
+
+// AddEntries records entries whose path is already relative to the root
+func (m *Manifest) AddEntries(entries ...ManifestEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Entries = append(m.Entries, entries...)
+}
+
+// Changed returns the entries whose content differs from the previous
+// manifest, including the new files. The manifest must be written first so
+// that the checksums are up to date.
+func (m *Manifest) Changed(previous *Manifest) []ManifestEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sums := make(map[string]string, len(previous.Entries))
+	for _, entry := range previous.Entries {
+		sums[entry.Path] = entry.SHA256
+	}
+	var changed []ManifestEntry
+	for _, entry := range m.Entries {
+		if sum, ok := sums[entry.Path]; !ok || sum == "" || sum != entry.SHA256 {
+			changed = append(changed, entry)
+		}
+	}
+	return changed
+}
+
+// Stale returns the entries recorded in the previous manifest but not in this one
+func (m *Manifest) Stale(previous *Manifest) []ManifestEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current := make(map[string]bool, len(m.Entries))
+	for _, entry := range m.Entries {
+		current[entry.Path] = true
+	}
+	var stale []ManifestEntry
+	for _, entry := range previous.Entries {
+		if !current[entry.Path] {
+			stale = append(stale, entry)
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Path < stale[j].Path })
+	return stale
+}
+
+// fileChecksum returns the hex encoded SHA256 of a file
+func fileChecksum(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,123 @@
+package core_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestStale(t *testing.T) {
+	root := t.TempDir()
+
+	previous := core.NewManifest(root)
+	previous.Add(filepath.Join(root, "a.md"), core.ManifestEntry{ObjToken: "a"})
+	previous.Add(filepath.Join(root, "sub", "b.md"), core.ManifestEntry{ObjToken: "b"})
+	previous.Add(filepath.Join(root, "sub", "b.md"), core.ManifestEntry{ObjToken: "b"})
+	assert.NoError(t, previous.Write())
+
+	read, err := core.ReadManifest(root)
+	assert.NoError(t, err)
+	assert.Len(t, read.Entries, 2)
+	assert.Equal(t, "sub/b.md", read.Entries[1].Path)
+
+	current := core.NewManifest(root)
+	current.Add(filepath.Join(root, "a.md"), core.ManifestEntry{ObjToken: "a"})
+	stale := current.Stale(read)
+	assert.Len(t, stale, 1)
+	assert.Equal(t, "b", stale[0].ObjToken)
+}
+
+func TestReadManifestMissing(t *testing.T) {
+	manifest, err := core.ReadManifest(t.TempDir())
+	assert.NoError(t, err)
+	assert.Empty(t, manifest.Entries)
+}
+
+func TestWriteIncremental(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0o755))
+		assert.NoError(t, os.WriteFile(filepath.Join(root, name), []byte(content), 0o644))
+	}
+
+	write("a.md", "a")
+	write("b.md", "b")
+	previous := core.NewManifest(root)
+	previous.Add(filepath.Join(root, "a.md"), core.ManifestEntry{ObjToken: "a"})
+	previous.Add(filepath.Join(root, "b.md"), core.ManifestEntry{ObjToken: "b"})
+	assert.NoError(t, previous.Write())
+	base, err := core.ManifestChecksum(root)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, base)
+
+	write("b.md", "b2")
+	write("sub/c.md", "c")
+	current := core.NewManifest(root)
+	for _, name := range []string{"a.md", "b.md", "sub/c.md"} {
+		current.Add(filepath.Join(root, name), core.ManifestEntry{})
+	}
+	assert.NoError(t, current.Write())
+
+	var changed []string
+	for _, entry := range current.Changed(previous) {
+		changed = append(changed, entry.Path)
+	}
+	assert.Equal(t, []string{"b.md", "sub/c.md"}, changed)
+
+	assert.NoError(t, core.WriteIncremental(root, core.IncrementalInfo{BaseManifest: base, Changed: changed}))
+	file, err := os.Open(filepath.Join(root, core.IncrementalFileName))
+	assert.NoError(t, err)
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	assert.NoError(t, err)
+	reader := tar.NewReader(gz)
+	var names []string
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		names = append(names, header.Name)
+	}
+	assert.Equal(t, []string{core.IncrementalInfoName, core.ManifestFileName, "b.md", "sub/c.md"}, names)
+}
+
+func TestManifestChecksums(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{"a.md": "# A\n", "static/b.png": "png"} {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0o755))
+		assert.NoError(t, os.WriteFile(filepath.Join(root, name), []byte(content), 0o644))
+	}
+	manifest := core.NewManifest(root)
+	manifest.Add(filepath.Join(root, "a.md"), core.ManifestEntry{ObjToken: "a"})
+	manifest.Add(filepath.Join(root, "static", "b.png"), core.ManifestEntry{ObjToken: "b"})
+	// A tracked file missing locally is left out
+	manifest.Add(filepath.Join(root, "c.md"), core.ManifestEntry{ObjToken: "c"})
+	assert.NoError(t, manifest.Write())
+	assert.NoError(t, manifest.WriteChecksums())
+
+	data, err := os.ReadFile(filepath.Join(root, core.ChecksumsFileName))
+	assert.NoError(t, err)
+	assert.Regexp(t, `^[0-9a-f]{64}  a\.md\n[0-9a-f]{64}  manifest\.json\n[0-9a-f]{64}  static/b\.png\n$`, string(data))
+
+	problems, checked, err := core.VerifyChecksums(root)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, checked)
+	assert.Empty(t, problems)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "a.md"), []byte("# changed\n"), 0o644))
+	assert.NoError(t, os.Remove(filepath.Join(root, "static", "b.png")))
+	problems, _, err = core.VerifyChecksums(root)
+	assert.NoError(t, err)
+	assert.Equal(t, []core.ChecksumProblem{
+		{Path: "a.md", Reason: core.ChecksumMismatch},
+		{Path: "static/b.png", Reason: core.ChecksumMissing},
+	}, problems)
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"strings"
+)
+
+// MarpSlides converts a markdown document into a Marp slide deck: every level
+// 1 or 2 heading starts a new slide, and the front matter enables Marp. The
+// fields of an existing front matter are kept.
+func MarpSlides(title, markdown string) string {
+	fields := []FrontMatterField{
+		{Key: "marp", Value: true},
+		{Key: "title", Value: title},
+		{Key: "paginate", Value: true},
+	}
+	var existing string
+	if strings.HasPrefix(markdown, "---\n") {
+		if end := strings.Index(markdown[4:], "\n---\n"); end >= 0 {
+			existing = markdown[4 : 4+end+1]
+			markdown = strings.TrimLeft(markdown[4+end+5:], "\n")
+		}
+	}
+
+	buf := new(strings.Builder)
+	frontMatter := FrontMatter(fields...)
+	// Insert the existing fields before the closing line of the front matter
+	closing := strings.LastIndex(frontMatter, "---\n")
+	buf.WriteString(frontMatter[:closing])
+	for _, line := range strings.SplitAfter(existing, "\n") {
+		if line != "" && !strings.HasPrefix(line, "title:") {
+			buf.WriteString(line)
+		}
+	}
+	buf.WriteString(frontMatter[closing:])
+
+	fence := ""
+	content, blank := false, false
+	for _, line := range strings.SplitAfter(markdown, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+			}
+		} else if marker := fenceMarker(trimmed); marker != "" {
+			fence = marker
+		} else if content && (strings.HasPrefix(line, "# ") || strings.HasPrefix(line, "## ")) {
+			if !blank {
+				buf.WriteString("\n")
+			}
+			buf.WriteString("---\n\n")
+		}
+		if trimmed != "" {
+			content = true
+		}
+		blank = trimmed == ""
+		buf.WriteString(line)
+	}
+	return buf.String()
+}
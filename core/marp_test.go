@@ -0,0 +1,16 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarpSlides(t *testing.T) {
+	markdown := "---\nsummary: \"s\"\n---\n\n" +
+		"# Talk\n\nintro\n\n## Part 1\n\n```\n## not a slide\n```\n\n### Detail\n\n## Part 2\n"
+	expected := "---\nmarp: true\ntitle: \"Talk\"\npaginate: true\nsummary: \"s\"\n---\n\n" +
+		"# Talk\n\nintro\n\n---\n\n## Part 1\n\n```\n## not a slide\n```\n\n### Detail\n\n---\n\n## Part 2\n"
+	assert.Equal(t, expected, core.MarpSlides("Talk", markdown))
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/88250/lute"
+)
+
+// AssetWriter stores the files produced while parsing a document, i.e. the
+// attachments and the csv/xlsx files of the large tables
+type AssetWriter interface {
+	WriteAsset(filePath string, r io.Reader) (int64, error)
+}
+
+// DiskAssets writes the assets into the local files
+type DiskAssets struct{}
+
+func (DiskAssets) WriteAsset(filePath string, r io.Reader) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return 0, err
+	}
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o666)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return io.Copy(file, r)
+}
+
+// MemoryAssets keeps the assets in memory, keyed by their slash separated paths
+type MemoryAssets struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func NewMemoryAssets() *MemoryAssets {
+	return &MemoryAssets{files: make(map[string][]byte)}
+}
+
+func (m *MemoryAssets) WriteAsset(filePath string, r io.Reader) (int64, error) {
+	buf := new(bytes.Buffer)
+	written, err := buf.ReadFrom(r)
+	if err != nil {
+		return written, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[filepath.ToSlash(filePath)] = buf.Bytes()
+	return written, nil
+}
+
+// Files returns the assets written so far
+func (m *MemoryAssets) Files() map[string][]byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	files := make(map[string][]byte, len(m.files))
+	for name, data := range m.files {
+		files[name] = data
+	}
+	return files
+}
+
+// MemoryDocument is a docx converted without touching the disk
+type MemoryDocument struct {
+	Token    string
+	Title    string
+	Markdown string
+	// Files maps the paths relative to the markdown file to the content of
+	// the images and the other assets, e.g. static/xxx.png
+	Files map[string][]byte
+}
+
+// ConvertDocument converts a docx in memory, the images and the assets are
+// returned with the markdown so that the caller decides where to put them
+func (c *Client) ConvertDocument(ctx context.Context, docToken string, config OutputConfig) (*MemoryDocument, error) {
+	docx, blocks, err := c.GetDocxContent(ctx, docToken)
+	if err != nil {
+		return nil, err
+	}
+	assets := NewMemoryAssets()
+	parser := NewParser(config, c)
+	parser.SetContext(ctx)
+	parser.SetOutputDir(config.ImageDir)
+	parser.SetAssetWriter(assets)
+	markdown := parser.ParseDocxContent(docx, blocks)
+
+	files := assets.Files()
+	if !config.SkipImgDownload {
+		for _, imgToken := range parser.ImgTokens {
+			localLink, rawImage, err := c.DownloadImageRaw(ctx, imgToken, config.ImageDir)
+			if err != nil {
+				return nil, err
+			}
+			link := config.ImageLink(".", localLink)
+			files[link] = rawImage
+			markdown = strings.Replace(markdown, imgToken, link, 1)
+		}
+		if config.ImageAttributes {
+			markdown = FillImageSizes(markdown, func(src string) (int, int, bool) {
+				if data, ok := files[src]; ok {
+					return ImageSize(bytes.NewReader(data))
+				}
+				return 0, 0, false
+			})
+		}
+	}
+
+	engine := lute.New(func(l *lute.Lute) {
+		l.RenderOptions.AutoSpace = true
+	})
+	result := engine.FormatStr("md", markdown)
+	result = ApplyLinkStyle(config.LinkStyle, result)
+	return &MemoryDocument{
+		Token:    docToken,
+		Title:    docx.Title,
+		Markdown: result,
+		Files:    files,
+	}, nil
+}
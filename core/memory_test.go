@@ -0,0 +1,23 @@
+package core_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryAssets(t *testing.T) {
+	assets := core.NewMemoryAssets()
+	data, err := core.EncodeCSV([][]string{{"名称", "数量"}, {"苹果", "3"}})
+	assert.NoError(t, err)
+
+	written, err := assets.WriteAsset("static/table.csv", strings.NewReader(string(data)))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), written)
+
+	files := assets.Files()
+	assert.Len(t, files, 1)
+	assert.Equal(t, "\ufeff名称,数量\n苹果,3\n", string(files["static/table.csv"]))
+}
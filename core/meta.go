@@ -0,0 +1,65 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// Supported values of OutputConfig.Metadata
+const (
+	MetadataNone        = "none"
+	MetadataSidecar     = "sidecar"
+	MetadataFrontMatter = "front_matter"
+)
+
+// MetaFileSuffix is the suffix of the sidecar next to an exported document,
+// e.g. 标题.meta.json of 标题.md
+const MetaFileSuffix = ".meta.json"
+
+// DocumentMeta identifies the feishu document of an exported file. Unlike the
+// file name, it does not change with the title, a sync matches the files
+// with it.
+type DocumentMeta struct {
+	NodeToken string `json:"node_token,omitempty"`
+	ObjToken  string `json:"obj_token"`
+	ObjType   string `json:"obj_type"`
+	Revision  int64  `json:"revision,omitempty"`
+	Title     string `json:"title"`
+	URL       string `json:"url,omitempty"`
+}
+
+// WriteDocumentMeta writes the sidecar of the document at basePath, the
+// output path without the extension, and returns its path
+func WriteDocumentMeta(basePath string, meta DocumentMeta) (string, error) {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	metaPath := basePath + MetaFileSuffix
+	return metaPath, os.WriteFile(metaPath, append(data, '\n'), 0o644)
+}
+
+// ReadDocumentMeta reads a sidecar written by WriteDocumentMeta
+func ReadDocumentMeta(metaPath string) (DocumentMeta, error) {
+	meta := DocumentMeta{}
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		return meta, err
+	}
+	return meta, json.Unmarshal(data, &meta)
+}
+
+// FrontMatterFields returns the tokens and the revision as the front matter
+// fields, prefixed with feishu_ to keep them apart from the fields of the
+// static site generators
+func (m DocumentMeta) FrontMatterFields() []FrontMatterField {
+	fields := make([]FrontMatterField, 0, 3)
+	if m.NodeToken != "" {
+		fields = append(fields, FrontMatterField{Key: "feishu_node_token", Value: m.NodeToken})
+	}
+	fields = append(fields, FrontMatterField{Key: "feishu_obj_token", Value: m.ObjToken})
+	if m.Revision != 0 {
+		fields = append(fields, FrontMatterField{Key: "feishu_revision", Value: m.Revision})
+	}
+	return fields
+}
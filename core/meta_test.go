@@ -0,0 +1,24 @@
+package core_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentMeta(t *testing.T) {
+	meta := core.DocumentMeta{NodeToken: "wikcn1", ObjToken: "doxcn1", ObjType: "docx", Revision: 12, Title: "标题"}
+	assert.Equal(t, "---\nfeishu_node_token: \"wikcn1\"\nfeishu_obj_token: \"doxcn1\"\nfeishu_revision: 12\n---\n\n",
+		core.FrontMatter(meta.FrontMatterFields()...))
+
+	metaPath, err := core.WriteDocumentMeta(filepath.Join(t.TempDir(), "标题"), meta)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "标题.meta.json", filepath.Base(metaPath))
+	read, err := core.ReadDocumentMeta(metaPath)
+	assert.NoError(t, err)
+	assert.Equal(t, meta, read)
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"strings"
+
+	"github.com/chyroc/lark"
+)
+
+// withoutHTML turns off the settings which output raw html when AllowHTML is
+// false, for the platforms filtering it out
+func (o OutputConfig) withoutHTML() OutputConfig {
+	if o.AllowHTML {
+		return o
+	}
+	o.Flavor = string(FlavorGFM)
+	o.FigureStyle = FigureStyleMarkdown
+	o.ImageAttributes = false
+	if o.IframeMode == IframeModeEmbed {
+		o.IframeMode = IframeModeLink
+	}
+	if o.LineBreak == LineBreakHTML {
+		o.LineBreak = LineBreakBackslash
+	}
+	return o
+}
+
+// plainStyle approximates the underline with an emphasis when html is not
+// allowed, markdown has no underline
+func (p *Parser) plainStyle(style *lark.DocxTextElementStyle) *lark.DocxTextElementStyle {
+	if p.config.AllowHTML || !style.Underline {
+		return style
+	}
+	plain := *style
+	plain.Italic = true
+	plain.Underline = false
+	return &plain
+}
+
+// lineBreak separates the lines inside a table cell, a space when html is
+// not allowed
+func (p *Parser) lineBreak() string {
+	if p.config.AllowHTML {
+		return "<br/>"
+	}
+	return " "
+}
+
+// plainCells replaces the <br> of the sheet cells when html is not allowed
+func (p *Parser) plainCells(values [][]string) [][]string {
+	if p.config.AllowHTML {
+		return values
+	}
+	for _, row := range values {
+		for i, cell := range row {
+			row[i] = strings.ReplaceAll(cell, "<br>", " ")
+		}
+	}
+	return values
+}
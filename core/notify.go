@@ -0,0 +1,57 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// webhookPayload returns the text message of a chat robot webhook: feishu or
+// lark, dingtalk, and the slack format for the others
+func webhookPayload(webhook, text string) interface{} {
+	switch {
+	case strings.Contains(webhook, "/open-apis/bot/"):
+		return map[string]interface{}{"msg_type": "text", "content": map[string]string{"text": text}}
+	case strings.Contains(webhook, "dingtalk.com"):
+		return map[string]interface{}{"msgtype": "text", "text": map[string]string{"content": text}}
+	}
+	return map[string]string{"text": text}
+}
+
+// Notify sends a text message to the webhook of a chat robot
+func Notify(ctx context.Context, webhook, text string) error {
+	body, err := json.Marshal(webhookPayload(webhook, text))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, data)
+	}
+	// The robots report the errors such as an invalid signature in the body
+	result := struct {
+		Code    int    `json:"code"`
+		ErrCode int    `json:"errcode"`
+		Msg     string `json:"msg"`
+		ErrMsg  string `json:"errmsg"`
+	}{}
+	if json.NewDecoder(resp.Body).Decode(&result) == nil && (result.Code != 0 || result.ErrCode != 0) {
+		return fmt.Errorf("webhook returned an error: %s%s", result.Msg, result.ErrMsg)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotify(t *testing.T) {
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload = nil
+		json.NewDecoder(r.Body).Decode(&payload)
+		if r.URL.Path == "/open-apis/bot/v2/hook/invalid" {
+			w.Write([]byte(`{"code":19021,"msg":"sign match fail"}`))
+			return
+		}
+		w.Write([]byte(`{"code":0}`))
+	}))
+	defer server.Close()
+
+	assert.NoError(t, core.Notify(context.Background(), server.URL+"/open-apis/bot/v2/hook/abc", "导出失败"))
+	assert.Equal(t, map[string]interface{}{"msg_type": "text", "content": map[string]interface{}{"text": "导出失败"}}, payload)
+
+	assert.NoError(t, core.Notify(context.Background(), server.URL+"/services/T000/B000", "done"))
+	assert.Equal(t, map[string]interface{}{"text": "done"}, payload)
+
+	assert.ErrorContains(t, core.Notify(context.Background(), server.URL+"/open-apis/bot/v2/hook/invalid", "x"), "sign match fail")
+}
@@ -0,0 +1,179 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chyroc/lark"
+)
+
+// Supported values of OCRConfig.Provider
+const (
+	// OCRProviderFeishu uses the OCR of the feishu OPEN API with the
+	// credentials of the app
+	OCRProviderFeishu = "feishu"
+	// OCRProviderHTTP posts {"image": "<base64>"} to the endpoint, which
+	// replies with {"text": "..."} or {"text_list": ["..."]}
+	OCRProviderHTTP = "http"
+)
+
+// Supported values of OCRConfig.Style
+const (
+	// OCRStyleComment appends the text as an HTML comment below the image
+	OCRStyleComment = "comment"
+	// OCRStyleAlt appends the text to the alt text of the image
+	OCRStyleAlt = "alt"
+)
+
+// OCRConfig configures the recognition of the text in the exported images
+type OCRConfig struct {
+	Provider string `json:"provider"`
+	Endpoint string `json:"endpoint"`
+	APIKey   string `json:"api_key"`
+	Style    string `json:"style"`
+}
+
+// OCR recognizes the text of the images, the results are cached by image
+// token since an image may be shared by many documents. It is safe to use
+// concurrently.
+type OCR struct {
+	config     OCRConfig
+	client     *Client
+	httpClient *http.Client
+	mu         sync.Mutex
+	cache      map[string]string
+}
+
+func NewOCR(config OCRConfig, client *Client) *OCR {
+	return &OCR{
+		config:     config,
+		client:     client,
+		httpClient: &http.Client{Timeout: time.Minute},
+		cache:      make(map[string]string),
+	}
+}
+
+// Recognize returns the text of an image, the lines joined by "\n"
+func (o *OCR) Recognize(ctx context.Context, imgToken string, image []byte) (string, error) {
+	o.mu.Lock()
+	text, ok := o.cache[imgToken]
+	o.mu.Unlock()
+	if ok {
+		return text, nil
+	}
+
+	var lines []string
+	var err error
+	if o.config.Provider == OCRProviderHTTP {
+		lines, err = o.recognizeHTTP(ctx, image)
+	} else {
+		lines, err = o.recognizeFeishu(ctx, image)
+	}
+	if err != nil {
+		return "", err
+	}
+	text = strings.TrimSpace(strings.Join(lines, "\n"))
+
+	o.mu.Lock()
+	o.cache[imgToken] = text
+	o.mu.Unlock()
+	return text, nil
+}
+
+func (o *OCR) recognizeFeishu(ctx context.Context, image []byte) ([]string, error) {
+	encoded := base64.StdEncoding.EncodeToString(image)
+	resp, _, err := o.client.lark().AI.RecognizeBasicImage(ctx, &lark.RecognizeBasicImageReq{Image: &encoded})
+	if err != nil {
+		return nil, err
+	}
+	return resp.TextList, nil
+}
+
+func (o *OCR) recognizeHTTP(ctx context.Context, image []byte) ([]string, error) {
+	body, err := json.Marshal(map[string]string{"image": base64.StdEncoding.EncodeToString(image)})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.config.APIKey)
+	}
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ocr endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	result := struct {
+		Text     string   `json:"text"`
+		TextList []string `json:"text_list"`
+	}{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse the ocr result: %v", err)
+	}
+	if result.Text != "" {
+		return []string{result.Text}, nil
+	}
+	return result.TextList, nil
+}
+
+var spacesRegexp = regexp.MustCompile(`\s+`)
+
+// AnnotateImageText attaches the recognized text to the first image of the
+// markdown whose source is src. The "alt" style appends the text to the alt
+// text of a markdown image, otherwise an HTML comment is appended: below the
+// image if it is alone on its line, or right after it, e.g. in a table cell.
+func AnnotateImageText(markdown, src, text, style string) string {
+	text = strings.TrimSpace(text)
+	i := strings.Index(markdown, src)
+	if text == "" || i < 0 {
+		return markdown
+	}
+	if style == OCRStyleAlt && strings.HasSuffix(markdown[:i], "](") {
+		if start := strings.LastIndex(markdown[:i], "!["); start >= 0 {
+			alt := markdown[start+2 : i-2]
+			if alt != "" {
+				alt += " "
+			}
+			alt += strings.NewReplacer("[", "\\[", "]", "\\]").Replace(spacesRegexp.ReplaceAllString(text, " "))
+			return markdown[:start] + "![" + alt + markdown[i-2:]
+		}
+	}
+	// The end of the image: the closing parenthesis of the markdown or the
+	// end of the <img> tag
+	end := i + len(src)
+	if closing := strings.IndexAny(markdown[end:], ")>"); closing >= 0 {
+		end += closing + 1
+	}
+	lineEnd := strings.IndexByte(markdown[end:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(markdown) - end
+	}
+	// "--" is not allowed inside an HTML comment
+	text = strings.ReplaceAll(text, "--", "- -")
+	if strings.TrimSpace(markdown[end:end+lineEnd]) == "" {
+		lineEnd += end
+		return markdown[:lineEnd] + "\n<!-- OCR: " + text + " -->" + markdown[lineEnd:]
+	}
+	comment := "<!-- OCR: " + spacesRegexp.ReplaceAllString(text, " ") + " -->"
+	return markdown[:end] + comment + markdown[end:]
+}
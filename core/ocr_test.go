@@ -0,0 +1,49 @@
+package core_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOCRRecognizeHTTP(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		payload := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("png")), payload["image"])
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"text_list":["架构图","API Gateway"]}`))
+	}))
+	defer server.Close()
+
+	ocr := core.NewOCR(core.OCRConfig{Provider: core.OCRProviderHTTP, Endpoint: server.URL, APIKey: "secret"}, nil)
+	text, err := ocr.Recognize(context.Background(), "imgtoken", []byte("png"))
+	assert.NoError(t, err)
+	assert.Equal(t, "架构图\nAPI Gateway", text)
+	// The text of an image is recognized once
+	text, err = ocr.Recognize(context.Background(), "imgtoken", []byte("png"))
+	assert.NoError(t, err)
+	assert.Equal(t, "架构图\nAPI Gateway", text)
+	assert.Equal(t, 1, requests)
+}
+
+func TestAnnotateImageText(t *testing.T) {
+	markdown := "正文\n![](img1)\n| ![](img2) | b |\n"
+	assert.Equal(t, "正文\n![](img1)\n<!-- OCR: 第一行\n第二行 -->\n| ![](img2) | b |\n",
+		core.AnnotateImageText(markdown, "img1", "第一行\n第二行", core.OCRStyleComment))
+	assert.Equal(t, "正文\n![](img1)\n| ![](img2)<!-- OCR: a b - - c --> | b |\n",
+		core.AnnotateImageText(markdown, "img2", "a\nb -- c", core.OCRStyleComment))
+	assert.Equal(t, "正文\n![第一行 第二行](img1)\n| ![](img2) | b |\n",
+		core.AnnotateImageText(markdown, "img1", "第一行\n第二行", core.OCRStyleAlt))
+	assert.Equal(t, "![题注 \\[x\\]](img1)\n",
+		core.AnnotateImageText("![题注](img1)\n", "img1", "[x]", core.OCRStyleAlt))
+	assert.Equal(t, markdown, core.AnnotateImageText(markdown, "img1", " ", core.OCRStyleComment))
+}
@@ -1,11 +1,15 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"fmt"
-	"os"
+	"html"
+	"io"
+	"path"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
 
 	"github.com/Wsine/feishu2md/utils"
@@ -14,22 +18,55 @@ import (
 )
 
 type Parser struct {
-	client      *Client
-	useHTMLTags bool
-	ImgTokens   []string
-	blockMap    map[string]*lark.DocxBlock
-	ctx         context.Context
-	outputDir   string
+	client    *Client
+	config    OutputConfig
+	flavor    Flavor
+	ImgTokens []string
+	DocLinks  []string
+	blockMap  map[string]*lark.DocxBlock
+	ctx       context.Context
+	outputDir string
+	assets    AssetWriter
+	// embedDepth and embedVisited limit the recursion of the inlined documents
+	embedDepth   int
+	embedVisited map[string]bool
+	// Diagnostics are the problems of the blocks rendered as placeholders
+	Diagnostics []string
+	// trailingPatterns are the compiled OutputConfig.TrailingPatterns
+	trailingPatterns []*regexp.Regexp
+	// headingAnchors are the anchors of the headings by block id
+	headingAnchors map[string]string
+	// externalLinks are collected for the list at the end of the document
+	externalLinks []externalLink
+	// renderedBlocks and ignoredTypes are compared with the blocks of the
+	// document, see BlockCount
+	renderedBlocks map[string]bool
+	ignoredTypes   map[int64]int
 }
 
 func NewParser(config OutputConfig, client *Client) *Parser {
+	config = config.withoutHTML()
+	flavor, err := config.OutputFlavor()
+	if err != nil {
+		flavor = FlavorGFM
+	}
+	// The patterns are validated with the config, an invalid one is ignored here
+	trailingPatterns, _ := compileTrailingPatterns(config.TrailingPatterns)
 	return &Parser{
-		client:      client,
-		useHTMLTags: config.UseHTMLTags,
-		ImgTokens:   make([]string, 0),
-		blockMap:    make(map[string]*lark.DocxBlock),
-		ctx:         context.Background(),
-		outputDir:   "",
+		client:    client,
+		config:    config,
+		flavor:    flavor,
+		ImgTokens: make([]string, 0),
+		DocLinks:  make([]string, 0),
+		blockMap:  make(map[string]*lark.DocxBlock),
+		ctx:       context.Background(),
+		outputDir: "",
+		assets:    DiskAssets{},
+
+		embedVisited:     make(map[string]bool),
+		trailingPatterns: trailingPatterns,
+		renderedBlocks:   make(map[string]bool),
+		ignoredTypes:     make(map[int64]int),
 	}
 }
 
@@ -43,10 +80,44 @@ func (p *Parser) SetOutputDir(outputDir string) {
 	p.outputDir = outputDir
 }
 
+// SetAssetWriter sets where the attachments and the table files are written,
+// e.g. NewMemoryAssets() to keep them off the disk
+func (p *Parser) SetAssetWriter(assets AssetWriter) {
+	p.assets = assets
+}
+
+// writeTable writes the rows as a csv or xlsx asset
+func (p *Parser) writeTable(filePath, format string, rows [][]string) error {
+	var (
+		data []byte
+		err  error
+	)
+	if format == BitableModeXLSX {
+		data, err = EncodeXLSX(rows)
+	} else {
+		data, err = EncodeCSV(rows)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = p.assets.WriteAsset(filePath, bytes.NewReader(data))
+	return err
+}
+
 // =============================================================
 // Parser utils
 // =============================================================
 
+// assetLink returns the link of a file saved into the output directory,
+// relative to the markdown file or with the configured url prefix
+func (p *Parser) assetLink(filePath string) string {
+	link := p.config.ImageLink(p.outputDir, filePath)
+	if p.config.ImageURLPrefix != "" {
+		return link
+	}
+	return path.Join(filepath.ToSlash(p.config.ImageDir), link)
+}
+
 var DocxCodeLang2MdStr = map[lark.DocxCodeLanguage]string{
 	lark.DocxCodeLanguagePlainText:    "",
 	lark.DocxCodeLanguageABAP:         "abap",
@@ -136,21 +207,84 @@ func renderMarkdownTable(data [][]string) string {
 // =============================================================
 
 func (p *Parser) ParseDocxContent(doc *lark.DocxDocument, blocks []*lark.DocxBlock) string {
+	buf := new(strings.Builder)
+	// Writing into a strings.Builder never fails
+	_ = p.WriteDocxContent(buf, doc, blocks)
+	return buf.String()
+}
+
+// WriteDocxContent renders a document into w, the top level blocks are
+// written one by one rather than concatenated into a large string first
+func (p *Parser) WriteDocxContent(w io.Writer, doc *lark.DocxDocument, blocks []*lark.DocxBlock) error {
+	p.LoadDocxBlocks(blocks)
+	p.embedVisited[doc.DocumentID] = true
+
+	entryBlock := p.blockMap[doc.DocumentID]
+	p.collectHeadingAnchors(entryBlock)
+	buf := new(bytes.Buffer)
+	if entryBlock != nil && entryBlock.BlockType == lark.DocxBlockTypePage && entryBlock.Page != nil {
+		return p.writeDocxBlockPage(buf, entryBlock, w)
+	}
+	p.writeDocxBlock(buf, entryBlock, 0)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// LoadDocxBlocks indexes the blocks of a document so that its subtrees can be parsed
+func (p *Parser) LoadDocxBlocks(blocks []*lark.DocxBlock) {
 	for _, block := range blocks {
 		p.blockMap[block.BlockID] = block
 	}
+}
 
-	entryBlock := p.blockMap[doc.DocumentID]
-	return p.ParseDocxBlock(entryBlock, 0)
+// ParseSubtree renders the block with blockID and all of its children only,
+// the blocks of the document must be loaded first
+func (p *Parser) ParseSubtree(blockID string) (string, error) {
+	block, ok := p.blockMap[blockID]
+	if !ok {
+		return "", fmt.Errorf("block %s not found in the document", blockID)
+	}
+	return p.ParseDocxBlock(block, 0), nil
 }
 
+func (p *Parser) diagnose(format string, args ...interface{}) {
+	p.Diagnostics = append(p.Diagnostics, fmt.Sprintf(format, args...))
+}
+
+// ParseDocxBlock renders a block and its children. A block failing to render,
+// e.g. with a missing field, is replaced by a placeholder and recorded in the
+// Diagnostics instead of aborting the whole document.
 func (p *Parser) ParseDocxBlock(b *lark.DocxBlock, indentLevel int) string {
-	buf := new(strings.Builder)
-	buf.WriteString(strings.Repeat("\t", indentLevel))
+	buf := new(bytes.Buffer)
+	p.writeDocxBlock(buf, b, indentLevel)
+	return buf.String()
+}
+
+// writeDocxBlock renders a block and its children into buf, the containers
+// write their children into the same buffer to avoid the intermediate strings
+func (p *Parser) writeDocxBlock(buf *bytes.Buffer, b *lark.DocxBlock, indentLevel int) {
+	if b == nil {
+		// A child missing from the block list
+		return
+	}
+	start := buf.Len()
+	defer func() {
+		if r := recover(); r != nil {
+			p.diagnose("failed to render block %s of type %d: %v", b.BlockID, b.BlockType, r)
+			// Drop the partial output of the block
+			buf.Truncate(start)
+			buf.WriteString(p.indent(indentLevel))
+			buf.WriteString(fmt.Sprintf("> ⚠️ 此处的内容无法渲染（block %s）\n", b.BlockID))
+		}
+	}()
+
+	buf.WriteString(p.indent(indentLevel))
+	p.markRendered(b.BlockID, false)
 
 	switch b.BlockType {
 	case lark.DocxBlockTypePage:
-		buf.WriteString(p.ParseDocxBlockPage(b))
+		// Writing into a buffer never fails
+		_ = p.writeDocxBlockPage(buf, b, nil)
 	case lark.DocxBlockTypeText:
 		buf.WriteString(p.ParseDocxBlockText(b.Text))
 	case lark.DocxBlockTypeCallout:
@@ -178,9 +312,7 @@ func (p *Parser) ParseDocxBlock(b *lark.DocxBlock, indentLevel int) string {
 	case lark.DocxBlockTypeOrdered:
 		buf.WriteString(p.ParseDocxBlockOrdered(b, indentLevel))
 	case lark.DocxBlockTypeCode:
-		buf.WriteString("```" + DocxCodeLang2MdStr[b.Code.Style.Language] + "\n")
-		buf.WriteString(strings.TrimSpace(p.ParseDocxBlockText(b.Code)))
-		buf.WriteString("\n```\n")
+		buf.WriteString(p.ParseDocxBlockCode(b.Code))
 	case lark.DocxBlockTypeQuote:
 		buf.WriteString("> ")
 		buf.WriteString(p.ParseDocxBlockText(b.Quote))
@@ -189,16 +321,16 @@ func (p *Parser) ParseDocxBlock(b *lark.DocxBlock, indentLevel int) string {
 		buf.WriteString(p.ParseDocxBlockText(b.Equation))
 		buf.WriteString("\n$$\n")
 	case lark.DocxBlockTypeTodo:
-		if b.Todo.Style.Done {
-			buf.WriteString("- [x] ")
-		} else {
-			buf.WriteString("- [ ] ")
-		}
+		buf.WriteString(p.flavor.taskMarker(b.Todo.Style != nil && b.Todo.Style.Done))
 		buf.WriteString(p.ParseDocxBlockText(b.Todo))
 	case lark.DocxBlockTypeDivider:
 		buf.WriteString("---\n")
 	case lark.DocxBlockTypeImage:
-		buf.WriteString(p.ParseDocxBlockImage(b.Image))
+		if caption := p.imageCaption(b); caption != "" {
+			buf.WriteString(p.ParseDocxBlockFigure(b.Image, caption))
+		} else {
+			buf.WriteString(p.ParseDocxBlockImage(b.Image))
+		}
 	case lark.DocxBlockTypeFile:
 		buf.WriteString(p.ParseDocxBlockFile(b.File))
 	case lark.DocxBlockTypeBitable:
@@ -210,37 +342,67 @@ func (p *Parser) ParseDocxBlock(b *lark.DocxBlock, indentLevel int) string {
 	case lark.DocxBlockTypeTableCell:
 		buf.WriteString(p.ParseDocxBlockTableCell(b))
 	case lark.DocxBlockTypeTable:
-		buf.WriteString(p.ParseDocxBlockTable(b.Table))
+		buf.WriteString(p.ParseDocxBlockTable(b.Table, p.tableHeader(b)))
 	case lark.DocxBlockTypeSheet:
 		buf.WriteString(p.ParseDocxBlockSheet(b.Sheet))
 	case lark.DocxBlockTypeQuoteContainer:
 		buf.WriteString(p.ParseDocxBlockQuoteContainer(b))
 	case lark.DocxBlockTypeGrid:
-		buf.WriteString(p.ParseDocxBlockGrid(b, indentLevel))
+		p.writeDocxBlockGrid(buf, b, indentLevel)
+	case lark.DocxBlockTypeView:
+		buf.WriteString(p.ParseDocxBlockView(b, indentLevel))
+	case lark.DocxBlockTypeISV:
+		if text, ok := p.ParseDocxBlockISV(b.ISV); ok {
+			buf.WriteString(text)
+			break
+		}
+		p.ignoreBlock(b)
 	default:
 		// 对于不支持的 block type，仍然处理其 children
+		p.ignoreBlock(b)
 		for _, childId := range b.Children {
-			childBlock := p.blockMap[childId]
-			buf.WriteString(p.ParseDocxBlock(childBlock, indentLevel))
+			p.writeDocxBlock(buf, p.blockMap[childId], indentLevel)
 		}
 	}
-	return buf.String()
 }
 
 func (p *Parser) ParseDocxBlockPage(b *lark.DocxBlock) string {
-	buf := new(strings.Builder)
-
-	buf.WriteString("# ")
-	buf.WriteString(p.ParseDocxBlockText(b.Page))
-	buf.WriteString("\n")
+	buf := new(bytes.Buffer)
+	_ = p.writeDocxBlockPage(buf, b, nil)
+	return buf.String()
+}
 
-	for _, childId := range b.Children {
-		childBlock := p.blockMap[childId]
-		buf.WriteString(p.ParseDocxBlock(childBlock, 0))
+// writeDocxBlockPage renders the title and the children of a page into buf,
+// the buffer is flushed into out after every child if out is not nil
+func (p *Parser) writeDocxBlockPage(buf *bytes.Buffer, b *lark.DocxBlock, out io.Writer) error {
+	p.markRendered(b.BlockID, false)
+	if !p.config.OmitPageTitle {
+		buf.WriteString(p.headingMarker(1))
+		buf.WriteString(" ")
+		buf.WriteString(p.ParseDocxBlockText(b.Page))
 		buf.WriteString("\n")
 	}
 
-	return buf.String()
+	children := p.trimTrailingBlocks(b.Children)
+	// The trailing blocks are removed on purpose
+	for _, childId := range b.Children[len(children):] {
+		p.markRendered(childId, true)
+	}
+	for _, childId := range children {
+		p.writeDocxBlock(buf, p.blockMap[childId], 0)
+		buf.WriteString("\n")
+		if out != nil {
+			if _, err := buf.WriteTo(out); err != nil {
+				return err
+			}
+		}
+	}
+	buf.WriteString(p.externalLinksList())
+	if out != nil {
+		_, err := buf.WriteTo(out)
+		return err
+	}
+	return nil
 }
 
 func (p *Parser) ParseDocxBlockText(b *lark.DocxBlockText) string {
@@ -254,18 +416,77 @@ func (p *Parser) ParseDocxBlockText(b *lark.DocxBlockText) string {
 	return buf.String()
 }
 
+// ParseDocxBlockCode keeps the content of a code block verbatim, the elements
+// are concatenated without any inline style or decoration
+func (p *Parser) ParseDocxBlockCode(b *lark.DocxBlockText) string {
+	content := new(strings.Builder)
+	for _, e := range b.Elements {
+		switch {
+		case e.TextRun != nil:
+			content.WriteString(e.TextRun.Content)
+		case e.MentionDoc != nil:
+			content.WriteString(utils.UnescapeURL(e.MentionDoc.URL))
+		case e.MentionUser != nil:
+			content.WriteString(e.MentionUser.UserID)
+		case e.Equation != nil:
+			content.WriteString(e.Equation.Content)
+		}
+	}
+	code := content.String()
+	if code != "" && !strings.HasSuffix(code, "\n") {
+		code += "\n"
+	}
+
+	// The fence must be longer than any backtick run in the code
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	lang := ""
+	if b.Style != nil {
+		lang = DocxCodeLang2MdStr[b.Style.Language]
+	}
+	return fence + lang + "\n" + code + fence + "\n"
+}
+
 func (p *Parser) ParseDocxBlockCallout(b *lark.DocxBlock) string {
 	buf := new(strings.Builder)
 
+	// GitHub renders an alert only if its marker is alone on the line, the
+	// icon leads the content on the next line
 	buf.WriteString(">[!TIP] \n")
+	if b.Callout != nil {
+		if icon := Emoji(b.Callout.EmojiID); icon != "" {
+			buf.WriteString("> " + icon + "\n")
+		}
+	}
 
-	for _, childId := range b.Children {
+	// Every line of the children is quoted, including the nested lists and
+	// the code fences, an empty quote line separates the children
+	for i, childId := range b.Children {
+		if i > 0 {
+			buf.WriteString(">\n")
+		}
 		childBlock := p.blockMap[childId]
-		buf.WriteString(p.ParseDocxBlock(childBlock, 0))
+		buf.WriteString(quoteLines(strings.TrimRight(p.ParseDocxBlock(childBlock, 0), "\n")))
 	}
 
 	return buf.String()
 }
+
+// quoteLines prefixes every line of the text with a quote marker
+func quoteLines(text string) string {
+	buf := new(strings.Builder)
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			buf.WriteString(">\n")
+		} else {
+			buf.WriteString("> " + line + "\n")
+		}
+	}
+	return buf.String()
+}
+
 func (p *Parser) ParseDocxTextElement(e *lark.DocxTextElement, inline bool) string {
 	buf := new(strings.Builder)
 	if e.TextRun != nil {
@@ -275,8 +496,18 @@ func (p *Parser) ParseDocxTextElement(e *lark.DocxTextElement, inline bool) stri
 		buf.WriteString(e.MentionUser.UserID)
 	}
 	if e.MentionDoc != nil {
+		title := e.MentionDoc.Title
+		if p.config.RefreshMentionTitles && p.client != nil {
+			if latest, err := p.client.GetDocumentTitle(p.ctx, e.MentionDoc.Token, e.MentionDoc.ObjType); err == nil && latest != "" {
+				title = latest
+			}
+		}
+		p.DocLinks = append(p.DocLinks, e.MentionDoc.Token)
 		buf.WriteString(
-			fmt.Sprintf("[%s](%s)", e.MentionDoc.Title, utils.UnescapeURL(e.MentionDoc.URL)))
+			fmt.Sprintf("[%s](%s)", title, p.cleanLink(utils.UnescapeURL(e.MentionDoc.URL))))
+	}
+	if e.Reminder != nil {
+		buf.WriteString(p.ParseDocxTextElementReminder(e.Reminder))
 	}
 	if e.Equation != nil {
 		symbol := "$$"
@@ -292,50 +523,45 @@ func (p *Parser) ParseDocxTextElementTextRun(tr *lark.DocxTextElementTextRun) st
 	buf := new(strings.Builder)
 	postWrite := ""
 	if style := tr.TextElementStyle; style != nil {
-		if style.Bold {
-			if p.useHTMLTags {
-				buf.WriteString("<strong>")
-				postWrite = "</strong>"
-			} else {
-				buf.WriteString("**")
-				postWrite = "**"
-			}
-		} else if style.Italic {
-			if p.useHTMLTags {
-				buf.WriteString("<em>")
-				postWrite = "</em>"
-			} else {
-				buf.WriteString("_")
-				postWrite = "_"
+		open, close := p.flavor.textStyle(p.plainStyle(style))
+		buf.WriteString(open)
+		postWrite = close
+		if link := style.Link; link != nil {
+			linkURL := p.cleanLink(utils.UnescapeURL(link.URL))
+			if anchor, ok := p.headingLink(linkURL); ok {
+				// A jump to a heading of the same document
+				linkURL = "#" + anchor
+			} else if _, docToken, err := utils.ValidateDocumentURL(linkURL); err == nil {
+				p.DocLinks = append(p.DocLinks, docToken)
 			}
-		} else if style.Strikethrough {
-			if p.useHTMLTags {
-				buf.WriteString("<del>")
-				postWrite = "</del>"
-			} else {
-				buf.WriteString("~~")
-				postWrite = "~~"
+			postWrite += fmt.Sprintf("](%s)", linkURL)
+			if IsExternalLink(linkURL) {
+				postWrite += p.noteExternalLink(tr.Content, linkURL)
 			}
-		} else if style.Underline {
-			buf.WriteString("<u>")
-			postWrite = "</u>"
-		} else if style.InlineCode {
-			buf.WriteString("`")
-			postWrite = "`"
-		} else if link := style.Link; link != nil {
-			buf.WriteString("[")
-			postWrite = fmt.Sprintf("](%s)", utils.UnescapeURL(link.URL))
-		}
-	}
-	buf.WriteString(tr.Content)
+		}
+	}
+	if style := tr.TextElementStyle; style != nil && style.InlineCode {
+		buf.WriteString(tr.Content)
+	} else {
+		buf.WriteString(p.breakLines(ReplaceEmojiCodes(tr.Content)))
+	}
 	buf.WriteString(postWrite)
 	return buf.String()
 }
 
+// headingMarker returns the # of a heading level shifted by the heading
+// offset, a shifted heading stops at level 6, the deepest of markdown
+func (p *Parser) headingMarker(level int) string {
+	if p.config.HeadingOffset > 0 {
+		level = min(level+p.config.HeadingOffset, 6)
+	}
+	return strings.Repeat("#", level)
+}
+
 func (p *Parser) ParseDocxBlockHeading(b *lark.DocxBlock, headingLevel int) string {
 	buf := new(strings.Builder)
 
-	buf.WriteString(strings.Repeat("#", headingLevel))
+	buf.WriteString(p.headingMarker(headingLevel))
 	buf.WriteString(" ")
 
 	headingText := reflect.ValueOf(b).Elem().FieldByName(fmt.Sprintf("Heading%d", headingLevel))
@@ -351,12 +577,40 @@ func (p *Parser) ParseDocxBlockHeading(b *lark.DocxBlock, headingLevel int) stri
 
 func (p *Parser) ParseDocxBlockImage(img *lark.DocxBlockImage) string {
 	buf := new(strings.Builder)
-	buf.WriteString(fmt.Sprintf("![](%s)", img.Token))
+	if p.config.ImageAttributes {
+		buf.WriteString(p.imageTag(img, ""))
+	} else {
+		buf.WriteString(fmt.Sprintf("![](%s)", img.Token))
+	}
 	buf.WriteString("\n")
 	p.ImgTokens = append(p.ImgTokens, img.Token)
 	return buf.String()
 }
 
+func (p *Parser) imageCaption(b *lark.DocxBlock) string {
+	if p.client == nil {
+		return ""
+	}
+	return p.client.ImageCaption(b.BlockID)
+}
+
+// ParseDocxBlockFigure renders an image with its caption, as a <figure> for
+// the "html" figure style or an image followed by an italic caption line
+func (p *Parser) ParseDocxBlockFigure(img *lark.DocxBlockImage, caption string) string {
+	p.ImgTokens = append(p.ImgTokens, img.Token)
+	if p.config.FigureStyle == FigureStyleHTML {
+		if p.config.ImageAttributes {
+			return fmt.Sprintf("<figure>\n%s\n<figcaption>%s</figcaption>\n</figure>\n",
+				p.imageTag(img, caption), html.EscapeString(caption))
+		}
+		caption = html.EscapeString(caption)
+		return fmt.Sprintf("<figure>\n<img src=\"%s\" alt=\"%s\">\n<figcaption>%s</figcaption>\n</figure>\n",
+			img.Token, caption, caption)
+	}
+	alt := strings.NewReplacer("[", "\\[", "]", "\\]").Replace(caption)
+	return fmt.Sprintf("![%s](%s)\n\n*%s*\n", alt, img.Token, strings.TrimSpace(caption))
+}
+
 func (p *Parser) ParseDocxBlockFile(file *lark.DocxBlockFile) string {
 	buf := new(strings.Builder)
 
@@ -393,7 +647,7 @@ func (p *Parser) ParseDocxBlockFile(file *lark.DocxBlockFile) string {
 	// For file blocks inside documents, we should use DownloadDriveMedia
 	if p.ctx != nil && p.outputDir != "" && p.client != nil {
 		// Use DownloadDriveMedia for file blocks inside documents
-		resp, _, err := p.client.larkClient.Drive.DownloadDriveMedia(p.ctx, &lark.DownloadDriveMediaReq{
+		resp, _, err := p.client.lark().Drive.DownloadDriveMedia(p.ctx, &lark.DownloadDriveMediaReq{
 			FileToken: file.Token,
 		})
 
@@ -405,16 +659,11 @@ func (p *Parser) ParseDocxBlockFile(file *lark.DocxBlockFile) string {
 			}
 
 			filePath := filepath.Join(p.outputDir, downloadedFilename)
-			err := os.MkdirAll(filepath.Dir(filePath), 0o755)
+			written, err := p.assets.WriteAsset(filePath, resp.File)
 			if err == nil {
-				file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY, 0o666)
-				if err == nil {
-					written, err := file.ReadFrom(resp.File)
-					if err == nil {
-						buf.WriteString(fmt.Sprintf("**下载成功**: 文件已保存到 `%s` (大小: %d bytes)\n\n", filePath, written))
-						return buf.String()
-					}
-				}
+				p.client.stats.addDownloadedBytes(written)
+				buf.WriteString(fmt.Sprintf("**下载成功**: 文件已保存到 `%s` (大小: %d bytes)\n\n", filePath, written))
+				return buf.String()
 			}
 		}
 		// Download failed, fall through to placeholder
@@ -436,7 +685,7 @@ func (p *Parser) ParseDocxBlockBullet(b *lark.DocxBlock, indentLevel int) string
 	buf := new(strings.Builder)
 
 	buf.WriteString("- ")
-	buf.WriteString(p.ParseDocxBlockText(b.Bullet))
+	buf.WriteString(p.alignContinuation(p.ParseDocxBlockText(b.Bullet), indentLevel, "- "))
 
 	for _, childId := range b.Children {
 		childBlock := p.blockMap[childId]
@@ -465,8 +714,9 @@ func (p *Parser) ParseDocxBlockOrdered(b *lark.DocxBlock, indentLevel int) strin
 		}
 	}
 
-	buf.WriteString(fmt.Sprintf("%d. ", order))
-	buf.WriteString(p.ParseDocxBlockText(b.Ordered))
+	marker := fmt.Sprintf("%d. ", order)
+	buf.WriteString(marker)
+	buf.WriteString(p.alignContinuation(p.ParseDocxBlockText(b.Ordered), indentLevel, marker))
 
 	for _, childId := range b.Children {
 		childBlock := p.blockMap[childId]
@@ -476,25 +726,86 @@ func (p *Parser) ParseDocxBlockOrdered(b *lark.DocxBlock, indentLevel int) strin
 	return buf.String()
 }
 
+// indent returns the indentation of a nested list level
+func (p *Parser) indent(level int) string {
+	if p.config.ListIndentStyle == ListIndentTab {
+		return strings.Repeat("\t", level)
+	}
+	width := p.config.ListIndentWidth
+	if width <= 0 {
+		width = 4
+	}
+	return strings.Repeat(" ", width*level)
+}
+
+// alignContinuation indents the continuation lines of a list item to the
+// column of its content after the marker
+func (p *Parser) alignContinuation(text string, indentLevel int, marker string) string {
+	content := strings.TrimSuffix(text, "\n")
+	if !strings.Contains(content, "\n") {
+		return text
+	}
+	prefix := p.indent(indentLevel) + strings.Repeat(" ", len(marker))
+	lines := strings.Split(content, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = prefix + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n") + strings.TrimPrefix(text, content)
+}
+
 func (p *Parser) ParseDocxBlockTableCell(b *lark.DocxBlock) string {
 	buf := new(strings.Builder)
 
-	for _, child := range b.Children {
+	for i, child := range b.Children {
 		block := p.blockMap[child]
-		content := p.ParseDocxBlock(block, 0)
-		buf.WriteString(content + "<br/>")
+		content := strings.TrimRight(p.ParseDocxBlock(block, 0), "\n")
+		if i > 0 {
+			buf.WriteString(p.lineBreak())
+		}
+		buf.WriteString(content)
 	}
 
 	return buf.String()
 }
 
-func (p *Parser) ParseDocxBlockTable(t *lark.DocxBlockTable) string {
+// tableHeader decides the header of a table block, the first row is the
+// header if the setting in feishu is unknown
+func (p *Parser) tableHeader(b *lark.DocxBlock) TableHeader {
+	switch p.config.TableHeader {
+	case TableHeaderRow:
+		return TableHeader{Row: true}
+	case TableHeaderColumn:
+		return TableHeader{Column: true}
+	case TableHeaderBoth:
+		return TableHeader{Row: true, Column: true}
+	case TableHeaderNone:
+		return TableHeader{}
+	}
+	if p.client != nil {
+		if header, ok := p.client.TableHeader(b.BlockID); ok {
+			return header
+		}
+	}
+	return TableHeader{Row: true}
+}
+
+func (p *Parser) ParseDocxBlockTable(t *lark.DocxBlockTable, header TableHeader) string {
+	if t == nil || t.Property == nil || t.Property.ColumnSize <= 0 {
+		p.diagnose("skipped a table without columns")
+		return ""
+	}
 	var rows [][]string
 	mergeInfoMap := map[int64]map[int64]*lark.DocxBlockTablePropertyMergeInfo{}
 
 	// 构建单元格合并信息的映射
+	merged := false
 	if t.Property.MergeInfo != nil {
 		for i, merge := range t.Property.MergeInfo {
+			if merge.RowSpan > 1 || merge.ColSpan > 1 {
+				merged = true
+			}
 			rowIndex := int64(i) / t.Property.ColumnSize
 			colIndex := int64(i) % t.Property.ColumnSize
 			if _, exists := mergeInfoMap[int64(rowIndex)]; !exists {
@@ -524,6 +835,23 @@ func (p *Parser) ParseDocxBlockTable(t *lark.DocxBlockTable) string {
 		rows[rowIndex][colIndex] = cellContent
 	}
 
+	if len(rows) > 0 && (!p.flavor.htmlTable(merged) || !p.config.AllowHTML) {
+		for _, row := range rows {
+			for i, cell := range row {
+				row[i] = strings.ReplaceAll(cell, "|", "\\|")
+			}
+			// markdown 表格没有表头列，加粗第一列代替
+			if header.Column && len(row) > 0 && row[0] != "" {
+				row[0] = "**" + row[0] + "**"
+			}
+		}
+		// markdown 表格必须有表头行，没有表头时使用空表头
+		if !header.Row {
+			rows = append([][]string{make([]string, len(rows[0]))}, rows...)
+		}
+		return renderMarkdownTable(rows)
+	}
+
 	// 渲染为 HTML 表格
 	buf := new(strings.Builder)
 	buf.WriteString("<table>\n")
@@ -553,9 +881,10 @@ func (p *Parser) ParseDocxBlockTable(t *lark.DocxBlockTable) string {
 				if mergeInfo.ColSpan > 1 {
 					attributes += fmt.Sprintf(` colspan="%d"`, mergeInfo.ColSpan)
 				}
+				tag := tableCellTag(header, rowIndex, colIndex)
 				buf.WriteString(fmt.Sprintf(
-					`<td%s>%s</td>`,
-					attributes, cellContent,
+					`<%s%s>%s</%s>`,
+					tag, attributes, cellContent, tag,
 				))
 				// 标记合并范围内的所有单元格为已处理
 				for r := rowIndex; r < rowIndex+int(mergeInfo.RowSpan); r++ {
@@ -565,7 +894,8 @@ func (p *Parser) ParseDocxBlockTable(t *lark.DocxBlockTable) string {
 				}
 			} else {
 				// 普通单元格
-				buf.WriteString(fmt.Sprintf("<td>%s</td>", cellContent))
+				tag := tableCellTag(header, rowIndex, colIndex)
+				buf.WriteString(fmt.Sprintf("<%s>%s</%s>", tag, cellContent, tag))
 			}
 		}
 		buf.WriteString("</tr>\n")
@@ -575,6 +905,14 @@ func (p *Parser) ParseDocxBlockTable(t *lark.DocxBlockTable) string {
 	return buf.String()
 }
 
+// tableCellTag returns th for the cells in the header row or column
+func tableCellTag(header TableHeader, rowIndex, colIndex int) string {
+	if (header.Row && rowIndex == 0) || (header.Column && colIndex == 0) {
+		return "th"
+	}
+	return "td"
+}
+
 func (p *Parser) ParseDocxBlockQuoteContainer(b *lark.DocxBlock) string {
 	buf := new(strings.Builder)
 
@@ -597,17 +935,19 @@ func (p *Parser) ParseDocxBlockQuoteContainer(b *lark.DocxBlock) string {
 }
 
 func (p *Parser) ParseDocxBlockGrid(b *lark.DocxBlock, indentLevel int) string {
-	buf := new(strings.Builder)
+	buf := new(bytes.Buffer)
+	p.writeDocxBlockGrid(buf, b, indentLevel)
+	return buf.String()
+}
 
+func (p *Parser) writeDocxBlockGrid(buf *bytes.Buffer, b *lark.DocxBlock, indentLevel int) {
 	for _, child := range b.Children {
 		columnBlock := p.blockMap[child]
+		p.markRendered(child, false)
 		for _, child := range columnBlock.Children {
-			block := p.blockMap[child]
-			buf.WriteString(p.ParseDocxBlock(block, indentLevel))
+			p.writeDocxBlock(buf, p.blockMap[child], indentLevel)
 		}
 	}
-
-	return buf.String()
 }
 
 func (p *Parser) ParseDocxBlockSheet(s *lark.DocxBlockSheet) string {
@@ -616,54 +956,45 @@ func (p *Parser) ParseDocxBlockSheet(s *lark.DocxBlockSheet) string {
 
 	// 如果没有 client 或 token，则返回占位符
 	if p.client == nil || s.Token == "" {
-		buf.WriteString("\n\n")
-		buf.WriteString("> **📊 嵌入的电子表格**\n")
-		buf.WriteString(">\n")
-		if s.Token != "" {
-			buf.WriteString(fmt.Sprintf("> Token: `%s`\n", s.Token))
-		}
-		buf.WriteString(">\n")
-		buf.WriteString("> *注：无法获取电子表格内容（缺少 client 或 token）*\n")
-		buf.WriteString("\n\n")
-		return buf.String()
+		return p.tablePlaceholder("嵌入的电子表格", "sheet", s.Token, sheetURL(s.Token), "注：无法获取电子表格内容（缺少 client 或 token）")
 	}
 
 	// 尝试获取电子表格的实际内容
-	ctx := context.Background()
-	values, err := p.client.GetSheetContent(ctx, s.Token)
+	values, err := p.client.GetSheetContent(p.ctx, s.Token, p.config.SheetFormulas)
 	if err != nil {
 		// 如果获取失败，返回占位符
-		buf.WriteString("\n\n")
-		buf.WriteString("> **📊 嵌入的电子表格**\n")
-		buf.WriteString(">\n")
-		if s.Token != "" {
-			buf.WriteString(fmt.Sprintf("> Token: `%s`\n", s.Token))
-		}
-		buf.WriteString(">\n")
+		note := fmt.Sprintf("获取电子表格内容失败: %v", err)
 		// 检查是否是 token 格式问题
 		if strings.Contains(err.Error(), "invalid spreadsheet token format") {
-			buf.WriteString("> *注：此电子表格使用了不支持的嵌入方式，无法获取内容*\n")
+			note = "注：此电子表格使用了不支持的嵌入方式，无法获取内容"
 		} else if strings.Contains(err.Error(), "91402") || strings.Contains(err.Error(), "NOTEXIST") {
-			buf.WriteString("> *注：无法访问电子表格（可能没有权限或电子表格不存在）*\n")
-		} else {
-			buf.WriteString(fmt.Sprintf("> *获取电子表格内容失败: %v*\n", err))
+			note = "注：无法访问电子表格（可能没有权限或电子表格不存在）"
 		}
-		buf.WriteString("\n\n")
-		return buf.String()
+		return p.tablePlaceholder("嵌入的电子表格", "sheet", s.Token, sheetURL(s.Token), note)
 	}
 
 	// 将电子表格数据转换为 markdown 表格
 	if len(values) == 0 {
-		buf.WriteString("\n\n")
-		buf.WriteString("> **📊 嵌入的电子表格**\n")
-		buf.WriteString(">\n")
-		if s.Token != "" {
-			buf.WriteString(fmt.Sprintf("> Token: `%s`\n", s.Token))
+		return p.tablePlaceholder("嵌入的电子表格", "sheet", s.Token, sheetURL(s.Token), "电子表格为空")
+	}
+
+	// 大表截断：只保留前 N 行/列，完整内容导出为 CSV 附件或指向原表格
+	values, summary := p.truncateSheet(s.Token, values)
+	values = p.plainCells(values)
+
+	// 按 flavor 渲染为 HTML 表格（gfm 仅在存在合并单元格时），保留 rowspan/colspan；不允许 HTML 时降级为 markdown 表格
+	if p.config.AllowHTML {
+		merges, err := p.client.GetSheetMerges(p.ctx, s.Token)
+		if err != nil {
+			merges = nil
+		}
+		if p.flavor.htmlTable(len(merges) > 0) {
+			buf.WriteString("\n\n")
+			buf.WriteString(renderSheetHTMLTable(values, merges))
+			buf.WriteString(summary)
+			buf.WriteString("\n")
+			return buf.String()
 		}
-		buf.WriteString(">\n")
-		buf.WriteString("> *电子表格为空*\n")
-		buf.WriteString("\n\n")
-		return buf.String()
 	}
 
 	// 生成 markdown 表格
@@ -688,58 +1019,154 @@ func (p *Parser) ParseDocxBlockSheet(s *lark.DocxBlockSheet) string {
 		}
 		buf.WriteString("\n")
 	}
+	buf.WriteString(summary)
 	buf.WriteString("\n")
 
 	return buf.String()
 }
 
+// truncateSheet 按 SheetMaxRows/SheetMaxColumns 截断电子表格，并返回追加在表格后的提示
+func (p *Parser) truncateSheet(token string, values [][]string) ([][]string, string) {
+	maxRows, maxColumns := p.config.SheetMaxRows, p.config.SheetMaxColumns
+	totalRows, totalColumns := len(values)-1, len(values[0])
+	rowsExceeded := maxRows > 0 && totalRows > maxRows
+	columnsExceeded := maxColumns > 0 && totalColumns > maxColumns
+	if !rowsExceeded && !columnsExceeded {
+		return values, ""
+	}
+
+	// 完整表格优先导出为 CSV 附件，否则指向飞书原表格
+	link := sheetURL(token)
+	if p.outputDir != "" {
+		filePath := filepath.Join(p.outputDir, token+".csv")
+		if err := p.writeTable(filePath, BitableModeCSV, values); err == nil {
+			link = p.assetLink(filePath)
+		}
+	}
+
+	truncated := values
+	if rowsExceeded {
+		truncated = truncated[:maxRows+1]
+	}
+	if columnsExceeded {
+		rows := make([][]string, len(truncated))
+		for i, row := range truncated {
+			if len(row) > maxColumns {
+				row = row[:maxColumns]
+			}
+			rows[i] = row
+		}
+		truncated = rows
+	}
+
+	summary := fmt.Sprintf("\n> 共 %d 行 %d 列，仅显示前 %d 行 %d 列，[完整表格](%s)\n",
+		totalRows, totalColumns, len(truncated)-1, len(truncated[0]), link)
+	return truncated, summary
+}
+
+// sheetURL 根据 spreadsheet_token + "_" + sheet_id 格式的 token 生成电子表格的访问链接
+func sheetURL(token string) string {
+	lastUnderscore := strings.LastIndex(token, "_")
+	if lastUnderscore == -1 {
+		return "https://feishu.cn/sheets/" + token
+	}
+	return fmt.Sprintf("https://feishu.cn/sheets/%s?sheet=%s", token[:lastUnderscore], token[lastUnderscore+1:])
+}
+
+var markdownLinkRegexp = regexp.MustCompile(`\[([^\]]*)\]\(([^)\s]+)\)`)
+
+// renderSheetHTMLTable 将电子表格数据渲染为带合并单元格的 HTML 表格
+func renderSheetHTMLTable(values [][]string, merges []SheetMerge) string {
+	// 记录每个合并区域左上角的单元格以及被覆盖的单元格
+	spans := map[string]SheetMerge{}
+	covered := map[string]bool{}
+	for _, m := range merges {
+		spans[fmt.Sprintf("%d-%d", m.StartRow, m.StartColumn)] = m
+		for r := m.StartRow; r <= m.EndRow; r++ {
+			for c := m.StartColumn; c <= m.EndColumn; c++ {
+				if r != m.StartRow || c != m.StartColumn {
+					covered[fmt.Sprintf("%d-%d", r, c)] = true
+				}
+			}
+		}
+	}
+
+	buf := new(strings.Builder)
+	buf.WriteString("<table>\n")
+	for rowIndex, row := range values {
+		buf.WriteString("<tr>\n")
+		for colIndex, cell := range row {
+			cellKey := fmt.Sprintf("%d-%d", rowIndex, colIndex)
+			if covered[cellKey] {
+				continue
+			}
+			// 先转义单元格文本，保留换行转换出的 <br>；HTML 块中的 markdown 链接不会被渲染，转换为 <a> 标签
+			lines := strings.Split(cell, "<br>")
+			for i, line := range lines {
+				lines[i] = html.EscapeString(line)
+			}
+			content := markdownLinkRegexp.ReplaceAllString(strings.Join(lines, "<br>"), `<a href="$2">$1</a>`)
+			attributes := ""
+			if m, ok := spans[cellKey]; ok {
+				if rowSpan := m.EndRow - m.StartRow + 1; rowSpan > 1 {
+					attributes += fmt.Sprintf(` rowspan="%d"`, rowSpan)
+				}
+				if colSpan := m.EndColumn - m.StartColumn + 1; colSpan > 1 {
+					attributes += fmt.Sprintf(` colspan="%d"`, colSpan)
+				}
+			}
+			buf.WriteString(fmt.Sprintf("<td%s>%s</td>", attributes, content))
+		}
+		buf.WriteString("</tr>\n")
+	}
+	buf.WriteString("</table>\n")
+	return buf.String()
+}
+
 // ParseDocxBlockBitable 解析多维表格块
 func (p *Parser) ParseDocxBlockBitable(bitable *lark.DocxBlockBitable) string {
 	buf := new(strings.Builder)
 
 	// 如果没有 client 或 token，则返回占位符
 	if p.client == nil || bitable.Token == "" {
+		return p.tablePlaceholder("多维表格", "bitable", bitable.Token, bitableURL(bitable.Token), "注：无法获取多维表格内容（缺少 client 或 token）")
+	}
+
+	if p.config.BitableMode == BitableModeLink {
 		buf.WriteString("\n\n")
-		buf.WriteString("> **📊 多维表格**\n")
-		buf.WriteString(">\n")
-		if bitable.Token != "" {
-			buf.WriteString(fmt.Sprintf("> Token: `%s`\n", bitable.Token))
-		}
-		buf.WriteString(">\n")
-		buf.WriteString("> *注：无法获取多维表格内容（缺少 client 或 token）*\n")
+		buf.WriteString(fmt.Sprintf("[📊 多维表格](%s)\n", bitableURL(bitable.Token)))
 		buf.WriteString("\n\n")
 		return buf.String()
 	}
 
+	// csv/xlsx 模式下生成旁路文件，正文只放链接
+	sidecar := (p.config.BitableMode == BitableModeCSV || p.config.BitableMode == BitableModeXLSX) && p.outputDir != ""
+
 	// 尝试获取多维表格的实际内容
-	ctx := context.Background()
-	values, err := p.client.GetBitableContent(ctx, bitable.Token)
+	table, err := p.client.GetBitableTable(p.ctx, bitable.Token, p.config.BitableFields)
 	if err != nil {
 		// 如果获取失败，返回占位符
-		buf.WriteString("\n\n")
-		buf.WriteString("> **📊 多维表格**\n")
-		buf.WriteString(">\n")
-		if bitable.Token != "" {
-			buf.WriteString(fmt.Sprintf("> Token: `%s`\n", bitable.Token))
-		}
-		buf.WriteString(">\n")
-		buf.WriteString(fmt.Sprintf("> *获取多维表格内容失败: %v*\n", err))
-		buf.WriteString("\n\n")
-		return buf.String()
+		return p.tablePlaceholder("多维表格", "bitable", bitable.Token, bitableURL(bitable.Token), fmt.Sprintf("获取多维表格内容失败: %v", err))
+	}
+	if p.config.BitableAttachments && p.outputDir != "" {
+		p.downloadBitableAttachments(table, sidecar)
 	}
+	values := table.Rows
 
 	// 将多维表格数据转换为 markdown 表格
 	if len(values) == 0 {
-		buf.WriteString("\n\n")
-		buf.WriteString("> **📊 多维表格**\n")
-		buf.WriteString(">\n")
-		if bitable.Token != "" {
-			buf.WriteString(fmt.Sprintf("> Token: `%s`\n", bitable.Token))
+		return p.tablePlaceholder("多维表格", "bitable", bitable.Token, bitableURL(bitable.Token), "多维表格为空")
+	}
+
+	if sidecar {
+		filePath := filepath.Join(p.outputDir, fmt.Sprintf("%s.%s", bitable.Token, p.config.BitableMode))
+		if err := p.writeTable(filePath, p.config.BitableMode, values); err == nil {
+			buf.WriteString("\n\n")
+			buf.WriteString(fmt.Sprintf("[📊 多维表格（%d 行）](%s)\n", len(values)-1, p.assetLink(filePath)))
+			buf.WriteString("\n\n")
+			return buf.String()
 		}
-		buf.WriteString(">\n")
-		buf.WriteString("> *多维表格为空*\n")
-		buf.WriteString("\n\n")
-		return buf.String()
+		// 写文件失败时退回 markdown 表格
 	}
 
 	// 生成 markdown 表格
@@ -769,6 +1196,64 @@ func (p *Parser) ParseDocxBlockBitable(bitable *lark.DocxBlockBitable) string {
 	return buf.String()
 }
 
+// downloadBitableAttachments saves the files of the attachment fields and
+// replaces the cells with the links, or the relative paths for the csv/xlsx files
+func (p *Parser) downloadBitableAttachments(table *BitableTable, sidecar bool) {
+	for cell, files := range table.Attachments {
+		links := make([]string, 0, len(files))
+		for _, file := range files {
+			name := file.Name
+			if name == "" {
+				name = file.FileToken
+			}
+			filePath := filepath.Join(p.outputDir, "attachments", file.FileToken+"_"+utils.SanitizeFileName(name))
+			if err := p.downloadMedia(file.FileToken, filePath); err != nil {
+				p.diagnose("failed to download the bitable attachment %s: %v", name, err)
+				links = append(links, name)
+				continue
+			}
+			if sidecar {
+				links = append(links, p.config.ImageLink(p.outputDir, filePath))
+			} else {
+				links = append(links, fmt.Sprintf("[%s](%s)", name, p.assetLink(filePath)))
+			}
+		}
+		sep := "<br/>"
+		if sidecar || !p.config.AllowHTML {
+			sep = ", "
+		}
+		table.Rows[cell[0]][cell[1]] = strings.Join(links, sep)
+	}
+}
+
+// downloadMedia downloads a media file of the drive as an asset
+func (p *Parser) downloadMedia(fileToken, filePath string) error {
+	resp, _, err := p.client.lark().Drive.DownloadDriveMedia(p.ctx, &lark.DownloadDriveMediaReq{
+		FileToken: fileToken,
+	})
+	if err != nil {
+		return err
+	}
+	written, err := p.assets.WriteAsset(filePath, resp.File)
+	if err != nil {
+		return err
+	}
+	p.client.stats.addDownloadedBytes(written)
+	return nil
+}
+
+// bitableURL 根据 app_token + "_" + table_id 格式的 token 生成多维表格的访问链接
+func bitableURL(token string) string {
+	appToken, tableID, viewID, err := parseBitableToken(token)
+	if err != nil {
+		return "https://feishu.cn/base/" + token
+	}
+	if viewID != "" {
+		return fmt.Sprintf("https://feishu.cn/base/%s?table=%s&view=%s", appToken, tableID, viewID)
+	}
+	return fmt.Sprintf("https://feishu.cn/base/%s?table=%s", appToken, tableID)
+}
+
 // ParseDocxBlockDiagram 解析流程图/UML块
 func (p *Parser) ParseDocxBlockDiagram(diagram *lark.DocxBlockDiagram) string {
 	buf := new(strings.Builder)
@@ -786,36 +1271,139 @@ func (p *Parser) ParseDocxBlockDiagram(diagram *lark.DocxBlockDiagram) string {
 	return buf.String()
 }
 
-// ParseDocxBlockIframe 解析内嵌块
+// ParseDocxBlockView renders the card or preview of an embedded document as a
+// link, or inlines the content of the document with the InlineEmbeds option
+func (p *Parser) ParseDocxBlockView(b *lark.DocxBlock, indentLevel int) string {
+	mention := p.viewMentionDoc(b)
+	if mention == nil {
+		buf := new(strings.Builder)
+		for _, childId := range b.Children {
+			buf.WriteString(p.ParseDocxBlock(p.blockMap[childId], indentLevel))
+		}
+		return buf.String()
+	}
+
+	// The text block of the mention is rendered as the link
+	p.markRendered(b.Children[0], true)
+	title := mention.Title
+	if title == "" {
+		title = mention.Token
+	}
+	link := fmt.Sprintf("📄 [%s](%s)\n", title, utils.UnescapeURL(mention.URL))
+	p.DocLinks = append(p.DocLinks, mention.Token)
+	if !p.config.InlineEmbeds || p.client == nil {
+		return link
+	}
+
+	content, err := p.inlineEmbed(mention)
+	if err != nil {
+		return link + fmt.Sprintf("<!-- failed to inline the embedded document: %v -->\n", err)
+	}
+	return link + "\n" + content
+}
+
+// viewMentionDoc returns the document embedded by a view block, which wraps a
+// text block with a single mentioned document
+func (p *Parser) viewMentionDoc(b *lark.DocxBlock) *lark.DocxTextElementMentionDoc {
+	if len(b.Children) != 1 {
+		return nil
+	}
+	child := p.blockMap[b.Children[0]]
+	if child == nil || child.Text == nil {
+		return nil
+	}
+	var mention *lark.DocxTextElementMentionDoc
+	for _, e := range child.Text.Elements {
+		if e.MentionDoc != nil && mention == nil {
+			mention = e.MentionDoc
+		} else if e.TextRun == nil || strings.TrimSpace(e.TextRun.Content) != "" {
+			return nil
+		}
+	}
+	return mention
+}
+
+// inlineEmbed parses the content of an embedded docx below its title, up to
+// InlineEmbedsMaxDepth levels and skipping the documents already inlined
+func (p *Parser) inlineEmbed(mention *lark.DocxTextElementMentionDoc) (string, error) {
+	maxDepth := p.config.InlineEmbedsMaxDepth
+	if maxDepth <= 0 {
+		maxDepth = 3
+	}
+	if p.embedDepth >= maxDepth {
+		return "", fmt.Errorf("maximum depth %d reached", maxDepth)
+	}
+
+	docToken := mention.Token
+	switch mention.ObjType {
+	case 22:
+	case 16:
+		node, err := p.client.GetWikiNodeInfo(p.ctx, mention.Token)
+		if err != nil {
+			return "", err
+		}
+		if node.ObjType != "docx" {
+			return "", fmt.Errorf("unsupported document type: %s", node.ObjType)
+		}
+		docToken = node.ObjToken
+	default:
+		return "", fmt.Errorf("unsupported document type: %d", mention.ObjType)
+	}
+	if p.embedVisited[docToken] {
+		return "", fmt.Errorf("document %s is already inlined", docToken)
+	}
+
+	doc, blocks, err := p.client.GetDocxContent(p.ctx, docToken)
+	if err != nil {
+		return "", err
+	}
+	embed := NewParser(p.config, p.client)
+	embed.SetContext(p.ctx)
+	embed.SetOutputDir(p.outputDir)
+	embed.SetAssetWriter(p.assets)
+	embed.embedDepth = p.embedDepth + 1
+	embed.embedVisited = p.embedVisited
+	embed.embedVisited[docToken] = true
+	embed.LoadDocxBlocks(blocks)
+
+	buf := new(strings.Builder)
+	if page := embed.blockMap[doc.DocumentID]; page != nil {
+		for _, childId := range page.Children {
+			buf.WriteString(embed.ParseDocxBlock(embed.blockMap[childId], 0))
+			buf.WriteString("\n")
+		}
+	}
+	p.ImgTokens = append(p.ImgTokens, embed.ImgTokens...)
+	p.DocLinks = append(p.DocLinks, embed.DocLinks...)
+	p.Diagnostics = append(p.Diagnostics, embed.Diagnostics...)
+	return buf.String(), nil
+}
+
+// ParseDocxBlockIframe 解析内嵌块，按 IframeMode 输出提示、可点击链接或嵌入代码
 func (p *Parser) ParseDocxBlockIframe(iframe *lark.DocxBlockIframe) string {
 	buf := new(strings.Builder)
 
+	if iframe.Component != nil && iframe.Component.URL != "" {
+		iframeType := int(iframe.Component.IframeType)
+		link := utils.UnescapeURL(iframe.Component.URL)
+		name, ok := iframeTypeNames[iframeType]
+		if !ok {
+			name = "嵌入内容"
+		}
+		switch p.config.IframeMode {
+		case IframeModeLink:
+			return fmt.Sprintf("\n[🔗 %s](%s)\n\n", name, link)
+		case IframeModeEmbed:
+			return "\n" + iframeEmbedCode(iframeType, link) + "\n"
+		}
+	}
+
 	buf.WriteString("\n\n")
 	buf.WriteString("**🔗 嵌入内容**\n\n")
 
 	if iframe.Component != nil {
-		// 获取 iframe 类型名称
-		typeNames := map[int]string{
-			1:  "哔哩哔哩",
-			2:  "西瓜视频",
-			3:  "优酷",
-			4:  "Airtable",
-			5:  "百度地图",
-			6:  "高德地图",
-			7:  "TikTok",
-			8:  "Figma",
-			9:  "墨刀",
-			10: "Canva",
-			11: "CodePen",
-			12: "飞书问卷",
-			13: "金数据",
-			14: "谷歌地图",
-			15: "YouTube",
-			99: "其他",
-		}
-
 		typeName := "未知类型"
-		if name, ok := typeNames[int(iframe.Component.IframeType)]; ok {
+		if name, ok := iframeTypeNames[int(iframe.Component.IframeType)]; ok {
 			typeName = name
 		}
 
@@ -1,12 +1,19 @@
 package core_test
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/png"
 	"io"
 	"os"
 	"path"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/88250/lute"
 	"github.com/Wsine/feishu2md/core"
@@ -42,6 +49,11 @@ func TestParseDocxContent(t *testing.T) {
 			parser := core.NewParser(core.NewConfig("", "").Output, nil)
 			mdParsed := parser.ParseDocxContent(data.Document, data.Blocks)
 			fmt.Println(mdParsed)
+
+			written := new(strings.Builder)
+			parser = core.NewParser(core.NewConfig("", "").Output, nil)
+			assert.NoError(t, parser.WriteDocxContent(written, data.Document, data.Blocks))
+			assert.Equal(t, mdParsed, written.String())
 			mdParsed = engine.FormatStr("md", mdParsed)
 
 			mdFile, err := os.ReadFile(path.Join(root, "testdata", td+".md"))
@@ -52,3 +64,566 @@ func TestParseDocxContent(t *testing.T) {
 		})
 	}
 }
+
+func TestParseDocxTextElementTextRunFlavor(t *testing.T) {
+	textRun := &lark.DocxTextElementTextRun{
+		Content: "text",
+		TextElementStyle: &lark.DocxTextElementStyle{
+			Bold:            true,
+			Strikethrough:   true,
+			BackgroundColor: 3,
+		},
+	}
+	tests := []struct {
+		flavor core.Flavor
+		want   string
+	}{
+		{core.FlavorGFM, "**~~text~~**"},
+		{core.FlavorCommonMark, "**<del>text</del>**"},
+		{core.FlavorHTMLRich, "<strong><del><mark>text</mark></del></strong>"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.flavor), func(t *testing.T) {
+			config := core.NewConfig("", "").Output
+			config.Flavor = string(tt.flavor)
+			parser := core.NewParser(config, nil)
+			assert.Equal(t, tt.want, parser.ParseDocxTextElementTextRun(textRun))
+		})
+	}
+}
+
+func TestParseDocxBlockCode(t *testing.T) {
+	code := &lark.DocxBlockText{
+		Style: &lark.DocxTextStyle{Language: lark.DocxCodeLanguageGo},
+		Elements: []*lark.DocxTextElement{
+			{TextRun: &lark.DocxTextElementTextRun{Content: "    if ok {\n"}},
+			{TextRun: &lark.DocxTextElementTextRun{
+				Content:          "        return **x**\n",
+				TextElementStyle: &lark.DocxTextElementStyle{Bold: true},
+			}},
+			{TextRun: &lark.DocxTextElementTextRun{Content: "    }\n\n"}},
+		},
+	}
+	parser := core.NewParser(core.NewConfig("", "").Output, nil)
+	assert.Equal(t,
+		"```go\n    if ok {\n        return **x**\n    }\n\n```\n",
+		parser.ParseDocxBlockCode(code))
+
+	fenced := &lark.DocxBlockText{Elements: []*lark.DocxTextElement{
+		{TextRun: &lark.DocxTextElementTextRun{Content: "```md\n```"}},
+	}}
+	assert.Equal(t, "````\n```md\n```\n````\n", parser.ParseDocxBlockCode(fenced))
+}
+
+func TestParseDocxBlockNestedList(t *testing.T) {
+	textBlock := func(content string) *lark.DocxBlockText {
+		return &lark.DocxBlockText{Elements: []*lark.DocxTextElement{
+			{TextRun: &lark.DocxTextElementTextRun{Content: content}},
+		}}
+	}
+	blocks := []*lark.DocxBlock{
+		{BlockID: "page", BlockType: lark.DocxBlockTypePage, Children: []string{"item"}},
+		{BlockID: "item", ParentID: "page", BlockType: lark.DocxBlockTypeOrdered,
+			Ordered: textBlock("first\nline"), Children: []string{"child"}},
+		{BlockID: "child", ParentID: "item", BlockType: lark.DocxBlockTypeBullet,
+			Bullet: textBlock("child")},
+	}
+
+	tests := []struct {
+		style string
+		width int
+		want  string
+	}{
+		{core.ListIndentSpace, 4, "1. first\n   line\n    - child\n"},
+		{core.ListIndentSpace, 2, "1. first\n   line\n  - child\n"},
+		{core.ListIndentTab, 0, "1. first\n   line\n\t- child\n"},
+	}
+	for _, tt := range tests {
+		config := core.NewConfig("", "").Output
+		config.ListIndentStyle = tt.style
+		config.ListIndentWidth = tt.width
+		parser := core.NewParser(config, nil)
+		parser.LoadDocxBlocks(blocks)
+		md, err := parser.ParseSubtree("item")
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, md)
+	}
+}
+
+func TestParseSubtree(t *testing.T) {
+	jsonFile, err := os.ReadFile(path.Join(utils.RootDir(), "testdata", "testdocx.1.json"))
+	utils.CheckErr(err)
+	data := struct {
+		Document *lark.DocxDocument `json:"document"`
+		Blocks   []*lark.DocxBlock  `json:"blocks"`
+	}{}
+	json.Unmarshal(jsonFile, &data)
+
+	parser := core.NewParser(core.NewConfig("", "").Output, nil)
+	parser.LoadDocxBlocks(data.Blocks)
+
+	md, err := parser.ParseSubtree("doxcns8Sg4e80OoIQu1PvN5OTqb")
+	assert.NoError(t, err)
+	assert.Equal(t, "## 现有的方法痛点\n", md)
+
+	_, err = parser.ParseSubtree("not-exist")
+	assert.Error(t, err)
+}
+
+func TestParseDocxBlockView(t *testing.T) {
+	view := &lark.DocxBlock{
+		BlockID:   "view",
+		BlockType: lark.DocxBlockTypeView,
+		Children:  []string{"text"},
+		View:      &lark.DocxBlockView{ViewType: lark.DocxViewTypeCard},
+	}
+	text := &lark.DocxBlock{
+		BlockID:   "text",
+		ParentID:  "view",
+		BlockType: lark.DocxBlockTypeText,
+		Text: &lark.DocxBlockText{Elements: []*lark.DocxTextElement{{
+			MentionDoc: &lark.DocxTextElementMentionDoc{
+				Token:   "doxcnEmbedded",
+				ObjType: 22,
+				URL:     "https%3A%2F%2Fexample.feishu.cn%2Fdocx%2FdoxcnEmbedded",
+				Title:   "Embedded",
+			},
+		}}},
+	}
+
+	parser := core.NewParser(core.NewConfig("", "").Output, nil)
+	parser.LoadDocxBlocks([]*lark.DocxBlock{view, text})
+	md, err := parser.ParseSubtree("view")
+	assert.NoError(t, err)
+	assert.Equal(t, "📄 [Embedded](https://example.feishu.cn/docx/doxcnEmbedded)\n", md)
+	assert.Equal(t, []string{"doxcnEmbedded"}, parser.DocLinks)
+}
+
+func TestParseDocxBlockFigure(t *testing.T) {
+	img := &lark.DocxBlockImage{Token: "boxcnImage"}
+
+	config := core.NewConfig("", "").Output
+	parser := core.NewParser(config, nil)
+	assert.Equal(t, "![架构 \\[v2\\]](boxcnImage)\n\n*架构 [v2]*\n",
+		parser.ParseDocxBlockFigure(img, "架构 [v2]"))
+	assert.Equal(t, []string{"boxcnImage"}, parser.ImgTokens)
+
+	config.FigureStyle = core.FigureStyleHTML
+	parser = core.NewParser(config, nil)
+	assert.Equal(t,
+		"<figure>\n<img src=\"boxcnImage\" alt=\"A &amp; B\">\n<figcaption>A &amp; B</figcaption>\n</figure>\n",
+		parser.ParseDocxBlockFigure(img, "A & B"))
+}
+
+func TestParseDocxBlockIframe(t *testing.T) {
+	iframe := &lark.DocxBlockIframe{Component: &lark.DocxBlockIframeComponent{
+		IframeType: 11,
+		URL:        "https%3A%2F%2Fcodepen.io%2Fuser%2Fpen%2FabcDEF",
+	}}
+
+	config := core.NewConfig("", "").Output
+	config.IframeMode = core.IframeModeLink
+	parser := core.NewParser(config, nil)
+	assert.Equal(t, "\n[🔗 CodePen](https://codepen.io/user/pen/abcDEF)\n\n", parser.ParseDocxBlockIframe(iframe))
+
+	config.IframeMode = core.IframeModeEmbed
+	parser = core.NewParser(config, nil)
+	assert.Equal(t,
+		"\n<iframe src=\"https://codepen.io/user/embed/abcDEF?default-tab=result\" width=\"800\" height=\"450\" frameborder=\"0\" allowfullscreen></iframe>\n\n",
+		parser.ParseDocxBlockIframe(iframe))
+
+	iframe.Component.IframeType = 8
+	iframe.Component.URL = "https://www.figma.com/file/key/Design"
+	assert.Contains(t, parser.ParseDocxBlockIframe(iframe),
+		"https://www.figma.com/embed?embed_host=share&amp;url=https%3A%2F%2Fwww.figma.com%2Ffile%2Fkey%2FDesign")
+}
+
+func TestParseDocxBlockRecover(t *testing.T) {
+	page := &lark.DocxBlock{
+		BlockID:   "page",
+		BlockType: lark.DocxBlockTypeQuoteContainer,
+		Children:  []string{"todo", "missing", "text"},
+	}
+	// A todo block without its todo field
+	todo := &lark.DocxBlock{BlockID: "todo", ParentID: "page", BlockType: lark.DocxBlockTypeTodo}
+	text := &lark.DocxBlock{
+		BlockID:   "text",
+		ParentID:  "page",
+		BlockType: lark.DocxBlockTypeText,
+		Text: &lark.DocxBlockText{Elements: []*lark.DocxTextElement{{
+			TextRun: &lark.DocxTextElementTextRun{Content: "still rendered"},
+		}}},
+	}
+
+	parser := core.NewParser(core.NewConfig("", "").Output, nil)
+	parser.LoadDocxBlocks([]*lark.DocxBlock{page, todo, text})
+	md, err := parser.ParseSubtree("page")
+	assert.NoError(t, err)
+	assert.Contains(t, md, "此处的内容无法渲染（block todo）")
+	assert.Contains(t, md, "still rendered")
+	assert.Len(t, parser.Diagnostics, 1)
+}
+
+func TestParseDocxBlockTableHeader(t *testing.T) {
+	blocks := []*lark.DocxBlock{}
+	cells := []string{}
+	for i, content := range []string{"a", "b", "c", "d"} {
+		cellID := fmt.Sprintf("cell%d", i)
+		textID := fmt.Sprintf("text%d", i)
+		cells = append(cells, cellID)
+		blocks = append(blocks,
+			&lark.DocxBlock{BlockID: cellID, BlockType: lark.DocxBlockTypeTableCell, Children: []string{textID}},
+			&lark.DocxBlock{BlockID: textID, BlockType: lark.DocxBlockTypeText, Text: &lark.DocxBlockText{
+				Elements: []*lark.DocxTextElement{{TextRun: &lark.DocxTextElementTextRun{Content: content}}},
+			}},
+		)
+	}
+	table := &lark.DocxBlockTable{
+		Cells:    cells,
+		Property: &lark.DocxBlockTableProperty{RowSize: 2, ColumnSize: 2},
+	}
+
+	config := core.NewConfig("", "").Output
+	parser := core.NewParser(config, nil)
+	parser.LoadDocxBlocks(blocks)
+	md := parser.ParseDocxBlockTable(table, core.TableHeader{Column: true})
+	assert.Equal(t, "|       |   |\n|-------|---|\n| **a** | b |\n| **c** | d |\n", md)
+
+	config.Flavor = string(core.FlavorHTMLRich)
+	parser = core.NewParser(config, nil)
+	parser.LoadDocxBlocks(blocks)
+	html := parser.ParseDocxBlockTable(table, core.TableHeader{Row: true})
+	assert.Equal(t, "<table>\n<tr>\n<th>a</th><th>b</th></tr>\n<tr>\n<td>c</td><td>d</td></tr>\n</table>\n", html)
+}
+
+func TestParseDocxContentWithoutHTML(t *testing.T) {
+	blocks := []*lark.DocxBlock{
+		{BlockID: "doc", BlockType: lark.DocxBlockTypePage, Children: []string{"text"}, Page: &lark.DocxBlockText{
+			Elements: []*lark.DocxTextElement{{TextRun: &lark.DocxTextElementTextRun{Content: "Title"}}},
+		}},
+		{BlockID: "text", BlockType: lark.DocxBlockTypeText, Text: &lark.DocxBlockText{Elements: []*lark.DocxTextElement{
+			{TextRun: &lark.DocxTextElementTextRun{Content: "underlined",
+				TextElementStyle: &lark.DocxTextElementStyle{Underline: true, Bold: true}}},
+		}}},
+	}
+	doc := &lark.DocxDocument{DocumentID: "doc"}
+
+	config := core.NewConfig("", "").Output
+	config.Flavor = string(core.FlavorHTMLRich)
+	md := core.NewParser(config, nil).ParseDocxContent(doc, blocks)
+	assert.Contains(t, md, "<strong><u>underlined</u></strong>")
+
+	config.AllowHTML = false
+	md = core.NewParser(config, nil).ParseDocxContent(doc, blocks)
+	assert.Contains(t, md, "**_underlined_**")
+	assert.NotContains(t, md, "<")
+}
+
+func TestParseDocxContentTrailingBlocks(t *testing.T) {
+	text := func(id, content string) *lark.DocxBlock {
+		return &lark.DocxBlock{BlockID: id, BlockType: lark.DocxBlockTypeText, Text: &lark.DocxBlockText{
+			Elements: []*lark.DocxTextElement{{TextRun: &lark.DocxTextElementTextRun{Content: content}}},
+		}}
+	}
+	page := &lark.DocxBlock{
+		BlockID:   "doc",
+		BlockType: lark.DocxBlockTypePage,
+		Page:      &lark.DocxBlockText{Elements: []*lark.DocxTextElement{{TextRun: &lark.DocxTextElementTextRun{Content: "Title"}}}},
+		Children:  []string{"body", "divider", "notice", "blank"},
+	}
+	blocks := []*lark.DocxBlock{
+		page,
+		text("body", "正文"),
+		{BlockID: "divider", BlockType: lark.DocxBlockTypeDivider},
+		text("notice", "© 2024 版权所有，转载请注明出处"),
+		text("blank", ""),
+	}
+	doc := &lark.DocxDocument{DocumentID: "doc"}
+
+	config := core.NewConfig("", "").Output
+	md := core.NewParser(config, nil).ParseDocxContent(doc, blocks)
+	assert.Contains(t, md, "版权所有")
+
+	config.TrailingPatterns = []string{`^©.*版权所有`}
+	config.TrailingBlockTypes = []int{int(lark.DocxBlockTypeDivider)}
+	md = core.NewParser(config, nil).ParseDocxContent(doc, blocks)
+	assert.Equal(t, "# Title\n\n正文\n\n", md)
+}
+
+func TestParseDocxContentHeadingLinks(t *testing.T) {
+	run := func(content string, style *lark.DocxTextElementStyle) *lark.DocxBlockText {
+		return &lark.DocxBlockText{Elements: []*lark.DocxTextElement{
+			{TextRun: &lark.DocxTextElementTextRun{Content: content, TextElementStyle: style}},
+		}}
+	}
+	link := func(id string) *lark.DocxTextElementStyle {
+		return &lark.DocxTextElementStyle{Link: &lark.DocxTextElementStyleLink{
+			URL: "https%3A%2F%2Fexample.feishu.cn%2Fdocx%2Fdoc%23" + id,
+		}}
+	}
+	blocks := []*lark.DocxBlock{
+		{BlockID: "doc", BlockType: lark.DocxBlockTypePage, Page: run("Title", nil),
+			Children: []string{"h1", "h2", "h3", "jump"}},
+		{BlockID: "h1", BlockType: lark.DocxBlockTypeHeading2, Heading2: run("第1章 简介！", nil)},
+		{BlockID: "h2", BlockType: lark.DocxBlockTypeHeading2, Heading2: run("Usage", nil)},
+		{BlockID: "h3", BlockType: lark.DocxBlockTypeHeading3, Heading3: run("Usage", nil)},
+		{BlockID: "jump", BlockType: lark.DocxBlockTypeText, Text: &lark.DocxBlockText{Elements: []*lark.DocxTextElement{
+			{TextRun: &lark.DocxTextElementTextRun{Content: "a", TextElementStyle: link("h1")}},
+			{TextRun: &lark.DocxTextElementTextRun{Content: "b", TextElementStyle: link("share-h3")}},
+			{TextRun: &lark.DocxTextElementTextRun{Content: "c", TextElementStyle: link("other")}},
+		}}},
+	}
+	doc := &lark.DocxDocument{DocumentID: "doc"}
+	md := core.NewParser(core.NewConfig("", "").Output, nil).ParseDocxContent(doc, blocks)
+	assert.Contains(t, md, "[a](#第-1-章-简介)")
+	assert.Contains(t, md, "[b](#usage-1)")
+	assert.Contains(t, md, "[c](https://example.feishu.cn/docx/doc#other)")
+	assert.Equal(t, "第一章-简介", core.HeadingAnchor("第一章 简介"))
+	assert.Equal(t, "hello-world-v2", core.HeadingAnchor("Hello, World! v2"))
+}
+
+func TestParseDocxBlockImageAttributes(t *testing.T) {
+	config := core.NewConfig("", "").Output
+	config.ImageAttributes = true
+	parser := core.NewParser(config, nil)
+	assert.Equal(t, "<img src=\"boxcnA\" alt=\"\" loading=\"lazy\" width=\"640\" height=\"480\">\n",
+		parser.ParseDocxBlockImage(&lark.DocxBlockImage{Token: "boxcnA", Width: 640, Height: 480}))
+	md := parser.ParseDocxBlockImage(&lark.DocxBlockImage{Token: "boxcnB"})
+	assert.Equal(t, "<img src=\"boxcnB\" alt=\"\" loading=\"lazy\">\n", md)
+
+	md = core.FillImageSizes(md+"<img src=\"boxcnC\" alt=\"\" loading=\"lazy\">\n", func(src string) (int, int, bool) {
+		return 100, 50, src == "boxcnB"
+	})
+	assert.Equal(t, "<img src=\"boxcnB\" alt=\"\" loading=\"lazy\" width=\"100\" height=\"50\">\n"+
+		"<img src=\"boxcnC\" alt=\"\" loading=\"lazy\">\n", md)
+
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	buf := new(bytes.Buffer)
+	assert.NoError(t, png.Encode(buf, img))
+	width, height, ok := core.ImageSize(buf)
+	assert.True(t, ok)
+	assert.Equal(t, [2]int{3, 2}, [2]int{width, height})
+}
+
+func TestParseDocxContentExternalLinks(t *testing.T) {
+	link := func(content, url string) *lark.DocxTextElement {
+		return &lark.DocxTextElement{TextRun: &lark.DocxTextElementTextRun{Content: content,
+			TextElementStyle: &lark.DocxTextElementStyle{Link: &lark.DocxTextElementStyleLink{URL: url}}}}
+	}
+	blocks := []*lark.DocxBlock{
+		{BlockID: "doc", BlockType: lark.DocxBlockTypePage, Children: []string{"text"}, Page: &lark.DocxBlockText{
+			Elements: []*lark.DocxTextElement{{TextRun: &lark.DocxTextElementTextRun{Content: "Title"}}},
+		}},
+		{BlockID: "text", BlockType: lark.DocxBlockTypeText, Text: &lark.DocxBlockText{Elements: []*lark.DocxTextElement{
+			link("网盘", "https://pan.example.com/s/1"),
+			link("文档", "https://example.feishu.cn/docx/AbC"),
+			link("再次", "https://pan.example.com/s/1"),
+		}}},
+	}
+	doc := &lark.DocxDocument{DocumentID: "doc"}
+
+	config := core.NewConfig("", "").Output
+	config.ExternalLinks = core.ExternalLinksAnnotate
+	md := core.NewParser(config, nil).ParseDocxContent(doc, blocks)
+	assert.Contains(t, md, "[网盘](https://pan.example.com/s/1)（外部链接）[文档](https://example.feishu.cn/docx/AbC)[再次]")
+
+	config.ExternalLinks = core.ExternalLinksList
+	md = core.NewParser(config, nil).ParseDocxContent(doc, blocks)
+	assert.True(t, strings.HasSuffix(md, "## 外部资源\n\n- [网盘](https://pan.example.com/s/1)\n\n"), md)
+
+	assert.False(t, core.IsExternalLink("#heading"))
+	assert.False(t, core.IsExternalLink("https://open.larksuite.com/document"))
+	assert.True(t, core.IsExternalLink("https://note.youdao.com/s/abc"))
+}
+
+func TestParseDocxBlockSheetPlaceholder(t *testing.T) {
+	parser := core.NewParser(core.NewConfig("", "").Output, nil)
+	md := parser.ParseDocxBlockSheet(&lark.DocxBlockSheet{Token: "B3hasMxsshByaEtZxAwcVfWxnSe_Ml1QzO"})
+	assert.Contains(t, md, "> 所属应用: 电子表格 `B3hasMxsshByaEtZxAwcVfWxnSe`\n")
+	assert.Contains(t, md, "> 链接: [在飞书中打开](https://feishu.cn/sheets/B3hasMxsshByaEtZxAwcVfWxnSe?sheet=Ml1QzO)\n")
+
+	md = parser.ParseDocxBlockBitable(&lark.DocxBlockBitable{Token: "bascnAbc_tblXyz"})
+	assert.Contains(t, md, "> 链接: [在飞书中打开](https://feishu.cn/base/bascnAbc?table=tblXyz)\n")
+}
+
+func TestParseDocxBlockSheetContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+
+	parser := core.NewParser(core.NewConfig("", "").Output, core.NewClient("app_id", "app_secret"))
+	parser.SetContext(ctx)
+	md := parser.ParseDocxBlockSheet(&lark.DocxBlockSheet{Token: "B3hasMxsshByaEtZxAwcVfWxnSe_Ml1QzO"})
+	assert.Contains(t, md, context.DeadlineExceeded.Error())
+
+	md = parser.ParseDocxBlockBitable(&lark.DocxBlockBitable{Token: "bascnAbc_tblXyz"})
+	assert.Contains(t, md, context.DeadlineExceeded.Error())
+}
+
+func TestParseDocxContentBlockCount(t *testing.T) {
+	text := func(id, content string) *lark.DocxBlock {
+		return &lark.DocxBlock{BlockID: id, BlockType: lark.DocxBlockTypeText, Text: &lark.DocxBlockText{
+			Elements: []*lark.DocxTextElement{{TextRun: &lark.DocxTextElementTextRun{Content: content}}},
+		}}
+	}
+	blocks := []*lark.DocxBlock{
+		{BlockID: "doc", BlockType: lark.DocxBlockTypePage, Children: []string{"body", "unknown"}, Page: &lark.DocxBlockText{
+			Elements: []*lark.DocxTextElement{{TextRun: &lark.DocxTextElementTextRun{Content: "Title"}}},
+		}},
+		text("body", "正文"),
+		{BlockID: "unknown", BlockType: lark.DocxBlockType(999), Children: []string{"inner"}},
+		text("inner", "未知块中的文本"),
+	}
+	doc := &lark.DocxDocument{DocumentID: "doc"}
+
+	parser := core.NewParser(core.NewConfig("", "").Output, nil)
+	md := parser.ParseDocxContent(doc, blocks)
+	assert.Contains(t, md, "未知块中的文本")
+	count := parser.BlockCount()
+	assert.Equal(t, 4, count.Total)
+	assert.Equal(t, 3, count.Rendered)
+	assert.Equal(t, map[int64]int{999: 1}, count.IgnoredTypes)
+	assert.Equal(t, 0.25, count.IgnoredRatio())
+	assert.Equal(t, "rendered 3 of 4 blocks, 1 ignored (type 999 ×1)", count.String())
+}
+
+func TestParseDocxContentHeadingOffset(t *testing.T) {
+	run := func(content string) *lark.DocxBlockText {
+		return &lark.DocxBlockText{Elements: []*lark.DocxTextElement{{TextRun: &lark.DocxTextElementTextRun{Content: content}}}}
+	}
+	blocks := []*lark.DocxBlock{
+		{BlockID: "doc", BlockType: lark.DocxBlockTypePage, Children: []string{"h1", "h6"}, Page: run("Title")},
+		{BlockID: "h1", BlockType: lark.DocxBlockTypeHeading1, Heading1: run("Chapter")},
+		{BlockID: "h6", BlockType: lark.DocxBlockTypeHeading6, Heading6: run("Deep")},
+	}
+	doc := &lark.DocxDocument{DocumentID: "doc"}
+
+	config := core.NewConfig("", "").Output
+	md := core.NewParser(config, nil).ParseDocxContent(doc, blocks)
+	assert.Contains(t, md, "# Title\n")
+	assert.Contains(t, md, "\n# Chapter\n")
+
+	config.HeadingOffset = 1
+	md = core.NewParser(config, nil).ParseDocxContent(doc, blocks)
+	assert.True(t, strings.HasPrefix(md, "## Title\n"))
+	assert.Contains(t, md, "\n## Chapter\n")
+	assert.Contains(t, md, "\n###### Deep\n")
+	assert.NotContains(t, md, "#######")
+}
+
+func TestParseDocxContentOmitPageTitle(t *testing.T) {
+	run := func(content string) *lark.DocxBlockText {
+		return &lark.DocxBlockText{Elements: []*lark.DocxTextElement{{TextRun: &lark.DocxTextElementTextRun{Content: content}}}}
+	}
+	blocks := []*lark.DocxBlock{
+		{BlockID: "doc", BlockType: lark.DocxBlockTypePage, Children: []string{"text"}, Page: run("Title")},
+		{BlockID: "text", BlockType: lark.DocxBlockTypeText, Text: run("Body")},
+	}
+	config := core.NewConfig("", "").Output
+	config.OmitPageTitle = true
+	parser := core.NewParser(config, nil)
+	md := parser.ParseDocxContent(&lark.DocxDocument{DocumentID: "doc"}, blocks)
+	assert.Equal(t, "Body\n\n", md)
+	assert.Equal(t, 0, parser.BlockCount().Ignored())
+}
+
+func TestParseDocxContentLineBreak(t *testing.T) {
+	blocks := []*lark.DocxBlock{
+		{BlockID: "doc", BlockType: lark.DocxBlockTypePage, Children: []string{"text"}, Page: &lark.DocxBlockText{
+			Elements: []*lark.DocxTextElement{{TextRun: &lark.DocxTextElementTextRun{Content: "Title"}}},
+		}},
+		{BlockID: "text", BlockType: lark.DocxBlockTypeText, Text: &lark.DocxBlockText{Elements: []*lark.DocxTextElement{
+			{TextRun: &lark.DocxTextElementTextRun{Content: "first\nsecond\n第三行\n第四行"}},
+		}}},
+	}
+	doc := &lark.DocxDocument{DocumentID: "doc"}
+	for style, expected := range map[string]string{
+		core.LineBreakKeep:      "first\nsecond\n第三行\n第四行\n",
+		core.LineBreakSpaces:    "first  \nsecond  \n第三行  \n第四行\n",
+		core.LineBreakBackslash: "first\\\nsecond\\\n第三行\\\n第四行\n",
+		core.LineBreakHTML:      "first<br/>\nsecond<br/>\n第三行<br/>\n第四行\n",
+		core.LineBreakJoin:      "first second第三行第四行\n",
+	} {
+		config := core.NewConfig("", "").Output
+		config.LineBreak = style
+		md := core.NewParser(config, nil).ParseDocxContent(doc, blocks)
+		assert.Equal(t, "# Title\n\n"+expected+"\n", md, style)
+	}
+}
+
+func TestApplyLineBreakStyle(t *testing.T) {
+	markdown := "first\\\nsecond\\\\\nthird\n\n```sh\nmake \\\n  all\n```\n"
+	assert.Equal(t, "first  \nsecond\\\\\nthird\n\n```sh\nmake \\\n  all\n```\n",
+		core.ApplyLineBreakStyle(core.LineBreakSpaces, markdown))
+	assert.Equal(t, markdown, core.ApplyLineBreakStyle(core.LineBreakBackslash, markdown))
+}
+
+func TestParseDocxBlockCalloutQuotesChildren(t *testing.T) {
+	run := func(content string) *lark.DocxBlockText {
+		return &lark.DocxBlockText{Elements: []*lark.DocxTextElement{{TextRun: &lark.DocxTextElementTextRun{Content: content}}}}
+	}
+	blocks := []*lark.DocxBlock{
+		{BlockID: "callout", BlockType: lark.DocxBlockTypeCallout, Callout: &lark.DocxBlockCallout{},
+			Children: []string{"text", "item", "code"}},
+		{BlockID: "text", BlockType: lark.DocxBlockTypeText, Text: run("Note")},
+		{BlockID: "item", BlockType: lark.DocxBlockTypeBullet, Bullet: run("outer"), Children: []string{"nested"}},
+		{BlockID: "nested", BlockType: lark.DocxBlockTypeBullet, Bullet: run("inner")},
+		{BlockID: "code", BlockType: lark.DocxBlockTypeCode, Code: &lark.DocxBlockText{
+			Style:    &lark.DocxTextStyle{Language: lark.DocxCodeLanguageGo},
+			Elements: []*lark.DocxTextElement{{TextRun: &lark.DocxTextElementTextRun{Content: "fmt.Println()\n\nreturn"}}},
+		}},
+	}
+	parser := core.NewParser(core.NewConfig("", "").Output, nil)
+	parser.LoadDocxBlocks(blocks)
+	md, err := parser.ParseSubtree("callout")
+	assert.NoError(t, err)
+	for _, line := range strings.Split(strings.TrimRight(md, "\n"), "\n")[1:] {
+		assert.True(t, strings.HasPrefix(line, ">"), line)
+	}
+	assert.Contains(t, md, "> Note\n>\n> - outer\n>     - inner\n")
+	assert.Contains(t, md, "> ```go\n> fmt.Println()\n>\n> return\n> ```\n")
+
+	blocks[0].Callout.EmojiID = "bulb"
+	parser.LoadDocxBlocks(blocks)
+	md, err = parser.ParseSubtree("callout")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(md, ">[!TIP] \n> 💡\n> Note\n"), md)
+}
+
+func TestParseDocxSmartBlocks(t *testing.T) {
+	deadline := time.Date(2024, 7, 1, 15, 30, 0, 0, time.Local)
+	expire := strconv.FormatInt(deadline.UnixMilli(), 10)
+	blocks := []*lark.DocxBlock{
+		{BlockID: "doc", BlockType: lark.DocxBlockTypePage, Page: &lark.DocxBlockText{},
+			Children: []string{"text", "countdown", "poll"}},
+		{BlockID: "text", BlockType: lark.DocxBlockTypeText, Text: &lark.DocxBlockText{Elements: []*lark.DocxTextElement{
+			{TextRun: &lark.DocxTextElementTextRun{Content: "提交 "}},
+			{Reminder: &lark.DocxTextElementReminder{ExpireTime: expire, IsWholeDay: true}},
+			{TextRun: &lark.DocxTextElementTextRun{Content: "，评审 "}},
+			{Reminder: &lark.DocxTextElementReminder{ExpireTime: expire}},
+		}}},
+		{BlockID: "countdown", BlockType: lark.DocxBlockTypeISV, ISV: &lark.DocxBlockISV{ComponentID: "c1", ComponentTypeID: "blk_countdown"}},
+		{BlockID: "poll", BlockType: lark.DocxBlockTypeISV, ISV: &lark.DocxBlockISV{ComponentID: "c2", ComponentTypeID: "blk_poll"}},
+	}
+
+	doc := &lark.DocxDocument{DocumentID: "doc"}
+
+	parser := core.NewParser(core.NewConfig("", "").Output, nil)
+	md := parser.ParseDocxContent(doc, blocks)
+	assert.Contains(t, md, "提交 📅 2024-07-01，评审 📅 2024-07-01 15:30\n")
+	// The ISV blocks have no text unless configured
+	assert.NotContains(t, md, "blk_")
+	assert.Equal(t, map[int64]int{int64(lark.DocxBlockTypeISV): 2}, parser.BlockCount().IgnoredTypes)
+
+	config := core.NewConfig("", "").Output
+	config.SmartBlocks = map[string]string{
+		core.SmartBlockReminder: "{date} 截止",
+		"blk_countdown":         "⏳ 倒计时",
+		core.SmartBlockISV:      "[{component_type_id}]",
+	}
+	parser = core.NewParser(config, nil)
+	md = parser.ParseDocxContent(doc, blocks)
+	assert.Contains(t, md, "提交 2024-07-01 截止，评审 2024-07-01 截止\n")
+	assert.Contains(t, md, "⏳ 倒计时\n")
+	assert.Contains(t, md, "[blk_poll]\n")
+}
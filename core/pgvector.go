@@ -0,0 +1,104 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+var pgIdentifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// pgVectorStore stores the chunks in a postgres table with the pgvector extension
+type pgVectorStore struct {
+	db      *sql.DB
+	table   string
+	mu      sync.Mutex
+	created bool
+}
+
+func newPGVectorStore(url, table string) (*pgVectorStore, error) {
+	// The table is interpolated into the statements
+	if !pgIdentifierRegexp.MatchString(table) {
+		return nil, fmt.Errorf("invalid pgvector table name: %s", table)
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	return &pgVectorStore{db: db, table: table}, nil
+}
+
+// ensureTable creates the extension and the table if they do not exist yet
+func (s *pgVectorStore) ensureTable(ctx context.Context, dimension int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.created {
+		return nil
+	}
+	if _, err := s.db.ExecContext(ctx, `CREATE EXTENSION IF NOT EXISTS vector`); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id           TEXT PRIMARY KEY,
+	doc_id       TEXT NOT NULL,
+	heading_path TEXT[] NOT NULL,
+	text         TEXT NOT NULL,
+	url          TEXT NOT NULL,
+	embedding    vector(%d) NOT NULL
+)`, s.table, dimension)); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s_doc_id ON %s (doc_id)`, s.table, s.table)); err != nil {
+		return err
+	}
+	s.created = true
+	return nil
+}
+
+func (s *pgVectorStore) Replace(ctx context.Context, docID string, chunks []Chunk, vectors [][]float32) error {
+	if len(chunks) > 0 {
+		if err := s.ensureTable(ctx, len(vectors[0])); err != nil {
+			return err
+		}
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE doc_id = $1`, s.table), docID); err != nil {
+		if len(chunks) == 0 {
+			// The table does not exist yet
+			return nil
+		}
+		return err
+	}
+	for i, chunk := range chunks {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s (id, doc_id, heading_path, text, url, embedding)
+VALUES ($1, $2, $3, $4, $5, $6::vector)`, s.table),
+			chunkUUID(docID, i), chunk.DocID, pq.Array(chunk.HeadingPath), chunk.Text, chunk.URL, pgVector(vectors[i]),
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// pgVector formats a vector as the text input of pgvector, e.g. [1,2,3]
+func pgVector(vector []float32) string {
+	parts := make([]string, len(vector))
+	for i, v := range vector {
+		parts[i] = strconv.FormatFloat(float64(v), 'g', -1, 32)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+func (s *pgVectorStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/chyroc/lark"
+)
+
+// DocumentProperties are the properties of a document shown in its
+// information panel in feishu
+type DocumentProperties struct {
+	Owner         string
+	CreatedAt     time.Time
+	ModifiedAt    time.Time
+	LastEditor    string
+	SecurityLabel string
+}
+
+// GetDocumentProperties returns the properties of a document, docType is
+// "docx", "sheet" and so on
+func (c *Client) GetDocumentProperties(ctx context.Context, token, docType string) (*DocumentProperties, error) {
+	resp, _, err := c.lark().Drive.GetDriveFileMeta(ctx, &lark.GetDriveFileMetaReq{
+		RequestDocs: []*lark.GetDriveFileMetaReqRequestDocs{{DocToken: token, DocType: docType}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Metas) == 0 {
+		return nil, fmt.Errorf("failed to get the meta of %s %s", docType, token)
+	}
+	meta := resp.Metas[0]
+	return &DocumentProperties{
+		Owner:         meta.OwnerID,
+		CreatedAt:     unixTime(meta.CreateTime),
+		ModifiedAt:    unixTime(meta.LatestModifyTime),
+		LastEditor:    meta.LatestModifyUser,
+		SecurityLabel: meta.SecLabelName,
+	}, nil
+}
+
+// unixTime parses the unix seconds of the APIs in UTC, the zero time if
+// invalid
+func unixTime(seconds string) time.Time {
+	if n, err := strconv.ParseInt(seconds, 10, 64); err == nil && n > 0 {
+		return time.Unix(n, 0).UTC()
+	}
+	return time.Time{}
+}
+
+// FrontMatterFields returns the properties which are set as the front matter
+// fields, the times in RFC 3339
+func (d DocumentProperties) FrontMatterFields() []FrontMatterField {
+	fields := make([]FrontMatterField, 0)
+	add := func(key, value string) {
+		if value != "" {
+			fields = append(fields, FrontMatterField{Key: key, Value: value})
+		}
+	}
+	add("owner", d.Owner)
+	if !d.CreatedAt.IsZero() {
+		add("created", d.CreatedAt.Format(time.RFC3339))
+	}
+	if !d.ModifiedAt.IsZero() {
+		add("updated", d.ModifiedAt.Format(time.RFC3339))
+	}
+	add("last_editor", d.LastEditor)
+	add("security_label", d.SecurityLabel)
+	return fields
+}
+
+// FrontMatterFields returns the custom fields of the FrontMatter option
+// sorted by their keys
+func (o OutputConfig) FrontMatterFields() []FrontMatterField {
+	keys := make([]string, 0, len(o.FrontMatter))
+	for key := range o.FrontMatter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fields := make([]FrontMatterField, len(keys))
+	for i, key := range keys {
+		fields[i] = FrontMatterField{Key: key, Value: o.FrontMatter[key]}
+	}
+	return fields
+}
@@ -0,0 +1,22 @@
+package core_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentPropertiesFrontMatter(t *testing.T) {
+	properties := core.DocumentProperties{
+		Owner:      "ou_1",
+		CreatedAt:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		LastEditor: "ou_2",
+	}
+	assert.Equal(t, []core.FrontMatterField{
+		{Key: "owner", Value: "ou_1"},
+		{Key: "created", Value: "2024-01-02T03:04:05Z"},
+		{Key: "last_editor", Value: "ou_2"},
+	}, properties.FrontMatterFields())
+}
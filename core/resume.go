@@ -0,0 +1,219 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/chyroc/lark"
+)
+
+// resumeAttempts is the number of the attempts of a file download, every
+// retry resumes from the bytes already downloaded
+const resumeAttempts = 5
+
+// driveFileDownloadURL is the API to download a file of the drive, it
+// supports the Range header
+const driveFileDownloadURL = "https://open.feishu.cn/open-apis/drive/v1/files/%s/download"
+
+// downloadDriveFileAPI is the name of the API of the resumable downloads in
+// the stats
+const downloadDriveFileAPI = "Drive#DownloadDriveFile"
+
+// errDownloadRejected is returned when the API refuses the download, e.g.
+// the token is a media rather than a file, retrying does not help
+var errDownloadRejected = errors.New("download rejected")
+
+// downloadResumable downloads a file of the drive into outDir. The content is
+// written to a .part file first and an interrupted download, even of a
+// previous run, resumes from its end with a Range request.
+func (c *Client) downloadResumable(ctx context.Context, fileToken, outDir string) (string, error) {
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return "", err
+	}
+	partPath := filepath.Join(outDir, "."+fileToken+".part")
+	var err error
+	for attempt := 1; attempt <= resumeAttempts; attempt++ {
+		var filename string
+		filename, err = c.downloadPart(ctx, fileToken, partPath)
+		if err == nil {
+			if filename == "" {
+				filename = fileToken
+			}
+			filePath := filepath.Join(outDir, filepath.Base(filename))
+			if err := os.Rename(partPath, filePath); err != nil {
+				return "", err
+			}
+			os.Remove(partPath + validatorSuffix)
+			return filePath, nil
+		}
+		if errors.Is(err, errDownloadRejected) || ctx.Err() != nil {
+			return "", err
+		}
+		if attempt < resumeAttempts {
+			c.logf("Resuming the download of %s (attempt %d): %v\n", fileToken, attempt+1, err)
+			c.stats.recordRetry(downloadDriveFileAPI)
+		}
+	}
+	return "", err
+}
+
+// validatorSuffix is the suffix of the file next to a .part file keeping the
+// ETag or the Last-Modified of its content, a part file is resumed only if
+// the file is unchanged since
+const validatorSuffix = ".validator"
+
+// partDownload is the result of a request of a part of a file, it is recorded
+// in the trace
+type partDownload struct {
+	Offset   int64  `json:"offset"`
+	Written  int64  `json:"written"`
+	Filename string `json:"filename,omitempty"`
+}
+
+// downloadPart requests the rest of the file after the content of partPath
+// and appends it, the file name of the response is returned. The request
+// goes through the rate limit of the app, the stats and the trace as the
+// requests of the lark client.
+func (c *Client) downloadPart(ctx context.Context, fileToken, partPath string) (string, error) {
+	larkClient := c.lark()
+	download := func(ctx context.Context, req *lark.RawRequestReq, resp interface{}) (*lark.Response, error) {
+		return c.writePart(ctx, larkClient, req.URL, partPath, resp.(*partDownload))
+	}
+	endpoint := c.rateLimits[larkClient](c.stats.middleware(c.tracer.middleware(download)))
+	result := &partDownload{}
+	_, err := endpoint(ctx, &lark.RawRequestReq{
+		Scope:  "Drive",
+		API:    "DownloadDriveFile",
+		Method: http.MethodGet,
+		URL:    fmt.Sprintf(driveFileDownloadURL, fileToken),
+	}, result)
+	return result.Filename, err
+}
+
+// writePart makes the request of downloadPart. The Range request is sent
+// with If-Range so that a file changed since is downloaded again from its
+// start, and a partial content is appended only at the end of the part file.
+func (c *Client) writePart(ctx context.Context, larkClient *lark.Lark, url, partPath string, result *partDownload) (*lark.Response, error) {
+	validatorPath := partPath + validatorSuffix
+	validator, _ := os.ReadFile(validatorPath)
+	if info, err := os.Stat(partPath); err == nil && len(validator) > 0 {
+		result.Offset = info.Size()
+	}
+	token, err := c.accessToken(ctx, larkClient)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if result.Offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(result.Offset, 10)+"-")
+		req.Header.Set("If-Range", string(validator))
+	}
+	resp, err := c.httpClient.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	response := &lark.Response{
+		Method:        req.Method,
+		URL:           url,
+		RequestID:     resp.Header.Get("X-Request-Id"),
+		StatusCode:    resp.StatusCode,
+		Header:        resp.Header,
+		ContentLength: resp.ContentLength,
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || result.Offset == 0 || start != result.Offset {
+			// Not the rest of the part file, start over
+			os.Remove(partPath)
+			return response, fmt.Errorf("unexpected content range %q from %d", resp.Header.Get("Content-Range"), result.Offset)
+		}
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		// The whole file, the server ignored the Range header or the file
+		// changed since the part file was written
+		result.Offset = 0
+		flags |= os.O_TRUNC
+		if err := saveValidator(validatorPath, resp.Header); err != nil {
+			return response, err
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The part file is stale, start over
+		os.Remove(partPath)
+		return response, fmt.Errorf("invalid range from %d", result.Offset)
+	default:
+		return response, fmt.Errorf("%w: %s", errDownloadRejected, resp.Status)
+	}
+	file, err := os.OpenFile(partPath, flags, 0o644)
+	if err != nil {
+		return response, err
+	}
+	defer file.Close()
+	result.Written, err = io.Copy(file, resp.Body)
+	c.stats.addDownloadedBytes(result.Written)
+	if err != nil {
+		return response, err
+	}
+	_, params, _ := mime.ParseMediaType(resp.Header.Get("Content-Disposition"))
+	result.Filename = params["filename"]
+	return response, nil
+}
+
+// saveValidator keeps the strong ETag, or else the Last-Modified, of the
+// response for the If-Range of a later resume. Without a validator the part
+// file is never resumed.
+func saveValidator(validatorPath string, header http.Header) error {
+	validator := header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = header.Get("Last-Modified")
+	}
+	if validator == "" {
+		if err := os.Remove(validatorPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(validatorPath, []byte(validator), 0o644)
+}
+
+// contentRangeStart returns the first byte of a Content-Range, e.g. 100 of
+// "bytes 100-199/200"
+func contentRangeStart(contentRange string) (int64, bool) {
+	byteRange, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return 0, false
+	}
+	start, _, ok := strings.Cut(byteRange, "-")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(start, 10, 64)
+	return n, err == nil
+}
+
+// accessToken returns the token of the requests of the app made without the
+// lark client
+func (c *Client) accessToken(ctx context.Context, larkClient *lark.Lark) (string, error) {
+	if c.userAccessToken != "" {
+		return c.userAccessToken, nil
+	}
+	token, _, err := larkClient.Auth.GetTenantAccessToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	return token.Token, nil
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SearchIndexFileName is the name of the search index in the root of an export
+const SearchIndexFileName = "search-index.json"
+
+// SearchDocument is a document of the search index, the fields can be fed into
+// lunr.js or meilisearch directly
+type SearchDocument struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// SearchIndex collects the exported documents for a full text search.
+// It is safe to add documents concurrently.
+type SearchIndex struct {
+	mu        sync.Mutex
+	root      string
+	Documents []SearchDocument
+}
+
+func NewSearchIndex(root string) *SearchIndex {
+	return &SearchIndex{root: root, Documents: make([]SearchDocument, 0)}
+}
+
+// Root returns the root directory of the search index
+func (s *SearchIndex) Root() string {
+	return s.root
+}
+
+// Add records the markdown file at filePath, the path is set relative to the root
+func (s *SearchIndex) Add(id, title, filePath, markdown string) {
+	rel, err := filepath.Rel(s.root, filePath)
+	if err != nil {
+		rel = filePath
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Documents = append(s.Documents, SearchDocument{
+		ID:      id,
+		Title:   title,
+		Path:    filepath.ToSlash(rel),
+		Content: PlainText(markdown),
+	})
+}
+
+// Write saves the documents sorted by path as a json array into the root directory
+func (s *SearchIndex) Write() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sort.Slice(s.Documents, func(i, j int) bool {
+		if s.Documents[i].Path != s.Documents[j].Path {
+			return s.Documents[i].Path < s.Documents[j].Path
+		}
+		return s.Documents[i].ID < s.Documents[j].ID
+	})
+	data, err := json.MarshalIndent(s.Documents, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.root, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.root, SearchIndexFileName), data, 0o644)
+}
+
+var (
+	plainImageRegexp    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	plainLinkRegexp     = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	plainHTMLTagRegexp  = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	plainLinePrefix     = regexp.MustCompile(`(?m)^\s*(#{1,9}\s+|>\s?|[-*+]\s+(\[[ x]\]\s+)?|\d+\.\s+|\|?\s*-{3,}.*$|` + "```" + `.*$)`)
+	plainEmphasisRegexp = regexp.MustCompile("(\\*\\*|~~|`|\\$\\$)")
+	plainSpaceRegexp    = regexp.MustCompile(`\s+`)
+)
+
+// PlainText strips the markdown syntax, links and html tags of a document
+// and collapses the whitespaces, leaving the text to be indexed
+func PlainText(markdown string) string {
+	text := plainImageRegexp.ReplaceAllString(markdown, "$1")
+	text = plainLinkRegexp.ReplaceAllString(text, "$1")
+	text = plainHTMLTagRegexp.ReplaceAllString(text, " ")
+	text = plainLinePrefix.ReplaceAllString(text, "")
+	text = plainEmphasisRegexp.ReplaceAllString(text, "")
+	text = strings.ReplaceAll(text, "|", " ")
+	return strings.TrimSpace(plainSpaceRegexp.ReplaceAllString(text, " "))
+}
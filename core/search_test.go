@@ -0,0 +1,22 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlainText(t *testing.T) {
+	markdown := "# Title\n\n" +
+		"Some **bold** and `code` with a [link](https://example.com).\n\n" +
+		"![alt](static/img.png)\n\n" +
+		"- [x] done\n" +
+		"1. first\n\n" +
+		"> quote<br/>line\n\n" +
+		"| A | B |\n| --- | --- |\n| 1 | 2 |\n\n" +
+		"```go\nfmt.Println()\n```\n"
+	assert.Equal(t,
+		"Title Some bold and code with a link. alt done first quote line A B 1 2 fmt.Println()",
+		core.PlainText(markdown))
+}
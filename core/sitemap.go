@@ -0,0 +1,192 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// SitemapFileName is the name of the sitemap in the root of an export
+	SitemapFileName = "sitemap.md"
+	// LinkGraphFileName is the name of the graphviz link graph in the root of an export
+	LinkGraphFileName = "links.dot"
+)
+
+// sitemapDocument is an exported document and the tokens of the documents it references
+type sitemapDocument struct {
+	path      string
+	title     string
+	token     string
+	nodeToken string
+	links     []string
+}
+
+// Sitemap collects the hierarchy of the exported documents and the references
+// between them. It is safe to add documents concurrently.
+type Sitemap struct {
+	mu   sync.Mutex
+	root string
+	docs []sitemapDocument
+}
+
+func NewSitemap(root string) *Sitemap {
+	return &Sitemap{root: root}
+}
+
+// Root returns the root directory of the sitemap
+func (s *Sitemap) Root() string {
+	return s.root
+}
+
+// Add records the markdown file at filePath, links are the tokens of the
+// documents referenced by it
+func (s *Sitemap) Add(filePath, token, nodeToken, title string, links []string) {
+	rel, err := filepath.Rel(s.root, filePath)
+	if err != nil {
+		rel = filePath
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.docs = append(s.docs, sitemapDocument{
+		path:      filepath.ToSlash(rel),
+		title:     title,
+		token:     token,
+		nodeToken: nodeToken,
+		links:     links,
+	})
+}
+
+// edges returns the indexes of the exported documents referenced by each document
+func (s *Sitemap) edges() [][]int {
+	index := make(map[string]int, len(s.docs))
+	for i, doc := range s.docs {
+		index[doc.token] = i
+		if doc.nodeToken != "" {
+			index[doc.nodeToken] = i
+		}
+	}
+	edges := make([][]int, len(s.docs))
+	for i, doc := range s.docs {
+		seen := map[int]bool{i: true}
+		for _, link := range doc.links {
+			if j, ok := index[link]; ok && !seen[j] {
+				seen[j] = true
+				edges[i] = append(edges[i], j)
+			}
+		}
+		sort.Ints(edges[i])
+	}
+	return edges
+}
+
+// Write saves the sitemap and the link graph into the root directory
+func (s *Sitemap) Write() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sort.Slice(s.docs, func(i, j int) bool {
+		if s.docs[i].path != s.docs[j].path {
+			return s.docs[i].path < s.docs[j].path
+		}
+		return s.docs[i].token < s.docs[j].token
+	})
+	edges := s.edges()
+	inbound := make([]int, len(s.docs))
+	for _, targets := range edges {
+		for _, j := range targets {
+			inbound[j]++
+		}
+	}
+
+	if err := os.MkdirAll(s.root, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(s.root, SitemapFileName),
+		[]byte(s.renderMarkdown(edges, inbound)), 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.root, LinkGraphFileName), []byte(s.renderDOT(edges)), 0o644)
+}
+
+func (s *Sitemap) docLink(i int) string {
+	return fmt.Sprintf("[%s](<%s>)", s.docs[i].title, s.docs[i].path)
+}
+
+func (s *Sitemap) renderMarkdown(edges [][]int, inbound []int) string {
+	buf := new(strings.Builder)
+	buf.WriteString("# Sitemap\n\n")
+
+	// The hierarchy follows the directories of the export
+	var prevDirs []string
+	for i, doc := range s.docs {
+		dirs := strings.Split(doc.path, "/")
+		dirs = dirs[:len(dirs)-1]
+		common := 0
+		for common < len(dirs) && common < len(prevDirs) && dirs[common] == prevDirs[common] {
+			common++
+		}
+		for depth := common; depth < len(dirs); depth++ {
+			buf.WriteString(fmt.Sprintf("%s- %s/\n", strings.Repeat("  ", depth), dirs[depth]))
+		}
+		buf.WriteString(fmt.Sprintf("%s- %s\n", strings.Repeat("  ", len(dirs)), s.docLink(i)))
+		prevDirs = dirs
+	}
+
+	// Core documents are the ones referenced the most
+	order := make([]int, 0, len(s.docs))
+	for i := range s.docs {
+		if inbound[i] > 0 {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool { return inbound[order[a]] > inbound[order[b]] })
+	if len(order) > 0 {
+		buf.WriteString("\n## Most referenced\n\n")
+		for _, i := range order {
+			buf.WriteString(fmt.Sprintf("- %s (%d)\n", s.docLink(i), inbound[i]))
+		}
+	}
+
+	orphans := new(strings.Builder)
+	for i := range s.docs {
+		if inbound[i] == 0 {
+			orphans.WriteString(fmt.Sprintf("- %s\n", s.docLink(i)))
+		}
+	}
+	if orphans.Len() > 0 {
+		buf.WriteString("\n## Orphans\n\nDocuments not referenced by any other document.\n\n")
+		buf.WriteString(orphans.String())
+	}
+
+	buf.WriteString("\n## Link graph\n\n```mermaid\ngraph LR\n")
+	for i, doc := range s.docs {
+		label := strings.ReplaceAll(doc.title, `"`, "#quot;")
+		buf.WriteString(fmt.Sprintf("  d%d[\"%s\"]\n", i, label))
+	}
+	for i, targets := range edges {
+		for _, j := range targets {
+			buf.WriteString(fmt.Sprintf("  d%d --> d%d\n", i, j))
+		}
+	}
+	buf.WriteString("```\n")
+	return buf.String()
+}
+
+func (s *Sitemap) renderDOT(edges [][]int) string {
+	buf := new(strings.Builder)
+	buf.WriteString("digraph links {\n")
+	for _, doc := range s.docs {
+		buf.WriteString(fmt.Sprintf("  %s [label=%s];\n", strconv.Quote(doc.path), strconv.Quote(doc.title)))
+	}
+	for i, targets := range edges {
+		for _, j := range targets {
+			buf.WriteString(fmt.Sprintf("  %s -> %s;\n", strconv.Quote(s.docs[i].path), strconv.Quote(s.docs[j].path)))
+		}
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
@@ -0,0 +1,29 @@
+package core_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSitemapWrite(t *testing.T) {
+	root := t.TempDir()
+	sitemap := core.NewSitemap(root)
+	sitemap.Add(filepath.Join(root, "Home.md"), "doc1", "node1", "Home", []string{"node2", "external"})
+	sitemap.Add(filepath.Join(root, "Guide", "Intro.md"), "doc2", "node2", "Intro", []string{"doc1"})
+	sitemap.Add(filepath.Join(root, "Guide", "Draft.md"), "doc3", "", "Draft", nil)
+	assert.NoError(t, sitemap.Write())
+
+	md, err := os.ReadFile(filepath.Join(root, core.SitemapFileName))
+	assert.NoError(t, err)
+	assert.Contains(t, string(md), "- Guide/\n  - [Draft](<Guide/Draft.md>)\n  - [Intro](<Guide/Intro.md>)\n- [Home](<Home.md>)\n")
+	assert.Contains(t, string(md), "## Orphans\n\nDocuments not referenced by any other document.\n\n- [Draft](<Guide/Draft.md>)\n")
+	assert.Contains(t, string(md), "  d1 --> d2\n  d2 --> d1\n")
+
+	dot, err := os.ReadFile(filepath.Join(root, core.LinkGraphFileName))
+	assert.NoError(t, err)
+	assert.Contains(t, string(dot), "  \"Home.md\" -> \"Guide/Intro.md\";\n")
+}
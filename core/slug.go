@@ -0,0 +1,65 @@
+package core
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/Wsine/feishu2md/utils"
+	"github.com/mozillazg/go-pinyin"
+)
+
+// Supported values of OutputConfig.Slug
+const (
+	// SlugKeep keeps the titles, e.g. Chinese, in the names
+	SlugKeep = "keep"
+	// SlugPinyin transliterates the Chinese characters to pinyin, e.g.
+	// "产品 文档 v2" to "chan-pin-wen-dang-v2"
+	SlugPinyin = "pinyin"
+	// SlugToken names the files and the directories by their tokens
+	SlugToken = "token"
+)
+
+// Slugify returns the name of a file or a directory for a title, the token
+// is used when the slug is empty, e.g. a title of emojis only
+func Slugify(title, token, mode string) string {
+	switch mode {
+	case SlugToken:
+		return token
+	case SlugPinyin:
+		if slug := pinyinSlug(title); slug != "" {
+			return slug
+		}
+		return token
+	}
+	if title == "" {
+		return token
+	}
+	return utils.SanitizeFileName(title)
+}
+
+// pinyinSlug lowercases the letters and the digits of a title, converts the
+// Chinese characters to pinyin and joins the words with "-"
+func pinyinSlug(title string) string {
+	args := pinyin.NewArgs()
+	var words []string
+	word := new(strings.Builder)
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range title {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			word.WriteRune(unicode.ToLower(r))
+		case unicode.Is(unicode.Han, r):
+			flush()
+			words = append(words, pinyin.SinglePinyin(r, args)...)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return strings.Join(words, "-")
+}
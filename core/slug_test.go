@@ -0,0 +1,16 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlugify(t *testing.T) {
+	assert.Equal(t, "产品_文档 v2", core.Slugify("产品/文档 v2", "tok", core.SlugKeep))
+	assert.Equal(t, "chan-pin-wen-dang-v2", core.Slugify("产品/文档 V2", "tok", core.SlugPinyin))
+	assert.Equal(t, "api-she-ji", core.Slugify("API设计", "tok", core.SlugPinyin))
+	assert.Equal(t, "tok", core.Slugify("🎉", "tok", core.SlugPinyin))
+	assert.Equal(t, "tok", core.Slugify("产品文档", "tok", core.SlugToken))
+}
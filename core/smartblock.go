@@ -0,0 +1,72 @@
+package core
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chyroc/lark"
+)
+
+// Keys of OutputConfig.SmartBlocks besides the component type ids of the ISV
+// blocks
+const (
+	// SmartBlockReminder is the text of the date reminders, {date} is their
+	// date and {time} their date with the hour unless they last a whole day
+	SmartBlockReminder = "reminder"
+	// SmartBlockISV is the text of the ISV blocks, e.g. a countdown, whose
+	// component type id has no text of its own. {component_type_id} and
+	// {component_id} are the ids of the block.
+	SmartBlockISV = "isv"
+)
+
+// defaultSmartBlocks are the texts of the smart blocks unless configured, the
+// ISV blocks without a text are dropped as the API returns no content of them
+var defaultSmartBlocks = map[string]string{
+	SmartBlockReminder: "📅 {time}",
+}
+
+// smartBlockText returns the text of the smart block of the key, "" to drop it
+func (p *Parser) smartBlockText(key string) (string, bool) {
+	if text, ok := p.config.SmartBlocks[key]; ok {
+		return text, true
+	}
+	text, ok := defaultSmartBlocks[key]
+	return text, ok
+}
+
+// ParseDocxTextElementReminder renders a date reminder as a static text
+func (p *Parser) ParseDocxTextElementReminder(r *lark.DocxTextElementReminder) string {
+	text, _ := p.smartBlockText(SmartBlockReminder)
+	ms, err := strconv.ParseInt(r.ExpireTime, 10, 64)
+	if err != nil || text == "" {
+		return text
+	}
+	expire := time.UnixMilli(ms)
+	date := expire.Format("2006-01-02")
+	datetime := date
+	if !r.IsWholeDay {
+		datetime = expire.Format("2006-01-02 15:04")
+	}
+	return strings.NewReplacer("{date}", date, "{time}", datetime).Replace(text)
+}
+
+// ParseDocxBlockISV renders a block of a third party app by its component
+// type, false if there is no text for it
+func (p *Parser) ParseDocxBlockISV(isv *lark.DocxBlockISV) (string, bool) {
+	if isv == nil {
+		return "", false
+	}
+	text, ok := p.smartBlockText(isv.ComponentTypeID)
+	if !ok {
+		text, ok = p.smartBlockText(SmartBlockISV)
+	}
+	if !ok || text == "" {
+		return "", false
+	}
+	text = strings.NewReplacer(
+		"{component_type_id}", isv.ComponentTypeID,
+		"{component_id}", isv.ComponentID,
+	).Replace(text)
+	return text + "\n", true
+}
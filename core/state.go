@@ -0,0 +1,244 @@
+package core
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"time"
+
+	// The pure Go driver keeps the binaries free of cgo
+	_ "modernc.org/sqlite"
+)
+
+// stateSchema creates the tables of the state database, the statements are
+// idempotent so that they run on every open
+const stateSchema = `
+CREATE TABLE IF NOT EXISTS files (
+	root        TEXT NOT NULL,
+	path        TEXT NOT NULL,
+	node_token  TEXT NOT NULL DEFAULT '',
+	obj_token   TEXT NOT NULL DEFAULT '',
+	obj_type    TEXT NOT NULL DEFAULT '',
+	title       TEXT NOT NULL DEFAULT '',
+	revision    INTEGER NOT NULL DEFAULT 0,
+	sha256      TEXT NOT NULL DEFAULT '',
+	exported_at INTEGER NOT NULL,
+	PRIMARY KEY (root, path)
+);
+CREATE INDEX IF NOT EXISTS files_obj_token ON files (obj_token);
+CREATE TABLE IF NOT EXISTS runs (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	started_at  INTEGER NOT NULL,
+	finished_at INTEGER NOT NULL DEFAULT 0,
+	exported    INTEGER NOT NULL DEFAULT 0,
+	skipped     INTEGER NOT NULL DEFAULT 0,
+	failed      INTEGER NOT NULL DEFAULT 0,
+	error       TEXT NOT NULL DEFAULT ''
+);
+CREATE TABLE IF NOT EXISTS failures (
+	run_id INTEGER NOT NULL REFERENCES runs (id),
+	name   TEXT NOT NULL,
+	link   TEXT NOT NULL DEFAULT '',
+	reason TEXT NOT NULL,
+	denied INTEGER NOT NULL DEFAULT 0
+);
+`
+
+// StateDB is the SQLite database of the exported files and the history of
+// the runs, an alternative to the json files scattered in the outputs.
+// It is safe to use concurrently.
+type StateDB struct {
+	db *sql.DB
+}
+
+// StateFile is a file of an export, with the time its content last changed
+type StateFile struct {
+	Root string
+	ManifestEntry
+	ExportedAt time.Time
+}
+
+// StateRun is a download recorded in the state database
+type StateRun struct {
+	ID         int64
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Exported   int
+	Skipped    int
+	Failed     int
+	Error      string
+}
+
+// StateFailure is a document that failed to download in a run, Denied is
+// set for a document the app has no permission to read
+type StateFailure struct {
+	Name   string
+	Link   string
+	Reason string
+	Denied bool
+}
+
+// OpenStateDB opens the state database at path, it is created if missing
+func OpenStateDB(path string) (*StateDB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, the writes are serialized here rather
+	// than failing with "database is locked"
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(stateSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &StateDB{db: db}, nil
+}
+
+func (s *StateDB) Close() error {
+	return s.db.Close()
+}
+
+// SaveFiles replaces the files of the export in root by the entries of its
+// manifest. The export time of a file is kept unless its checksum changed,
+// the files missing from the entries, e.g. pruned, are removed.
+func (s *StateDB) SaveFiles(root string, entries []ManifestEntry, now time.Time) error {
+	root = filepath.Clean(root)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`CREATE TEMP TABLE IF NOT EXISTS current_paths (path TEXT PRIMARY KEY)`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM current_paths`); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if _, err := tx.Exec(`
+INSERT INTO files (root, path, node_token, obj_token, obj_type, title, revision, sha256, exported_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (root, path) DO UPDATE SET
+	node_token = excluded.node_token,
+	obj_token = excluded.obj_token,
+	obj_type = excluded.obj_type,
+	title = excluded.title,
+	revision = excluded.revision,
+	exported_at = CASE WHEN files.sha256 = excluded.sha256 THEN files.exported_at ELSE excluded.exported_at END,
+	sha256 = excluded.sha256`,
+			root, entry.Path, entry.NodeToken, entry.ObjToken, entry.ObjType, entry.Title,
+			entry.Revision, entry.SHA256, now.Unix(),
+		); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO current_paths (path) VALUES (?)`, entry.Path); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM files WHERE root = ? AND path NOT IN (SELECT path FROM current_paths)`, root); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Files returns the files of the export in root sorted by path, or the
+// files of all the exports if root is empty
+func (s *StateDB) Files(root string) ([]StateFile, error) {
+	query := `SELECT root, path, node_token, obj_token, obj_type, title, revision, sha256, exported_at FROM files`
+	args := []interface{}{}
+	if root != "" {
+		query += ` WHERE root = ?`
+		args = append(args, filepath.Clean(root))
+	}
+	rows, err := s.db.Query(query+` ORDER BY root, path`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	files := make([]StateFile, 0)
+	for rows.Next() {
+		var file StateFile
+		var exportedAt int64
+		if err := rows.Scan(&file.Root, &file.Path, &file.NodeToken, &file.ObjToken, &file.ObjType,
+			&file.Title, &file.Revision, &file.SHA256, &exportedAt); err != nil {
+			return nil, err
+		}
+		file.ExportedAt = time.Unix(exportedAt, 0)
+		files = append(files, file)
+	}
+	return files, rows.Err()
+}
+
+// StartRun records the start of a download and returns the id of the run
+func (s *StateDB) StartRun(startedAt time.Time) (int64, error) {
+	result, err := s.db.Exec(`INSERT INTO runs (started_at) VALUES (?)`, startedAt.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// FinishRun records the result and the failures of a run
+func (s *StateDB) FinishRun(run StateRun, failures []StateFailure) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`UPDATE runs SET finished_at = ?, exported = ?, skipped = ?, failed = ?, error = ? WHERE id = ?`,
+		run.FinishedAt.Unix(), run.Exported, run.Skipped, run.Failed, run.Error, run.ID); err != nil {
+		return err
+	}
+	for _, failure := range failures {
+		if _, err := tx.Exec(`INSERT INTO failures (run_id, name, link, reason, denied) VALUES (?, ?, ?, ?, ?)`,
+			run.ID, failure.Name, failure.Link, failure.Reason, failure.Denied); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Runs returns the latest runs, the newest first
+func (s *StateDB) Runs(limit int) ([]StateRun, error) {
+	rows, err := s.db.Query(`SELECT id, started_at, finished_at, exported, skipped, failed, error
+FROM runs ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	runs := make([]StateRun, 0)
+	for rows.Next() {
+		var run StateRun
+		var startedAt, finishedAt int64
+		if err := rows.Scan(&run.ID, &startedAt, &finishedAt, &run.Exported, &run.Skipped, &run.Failed, &run.Error); err != nil {
+			return nil, err
+		}
+		run.StartedAt = time.Unix(startedAt, 0)
+		if finishedAt > 0 {
+			run.FinishedAt = time.Unix(finishedAt, 0)
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// Failures returns the failures of a run
+func (s *StateDB) Failures(runID int64) ([]StateFailure, error) {
+	rows, err := s.db.Query(`SELECT name, link, reason, denied FROM failures WHERE run_id = ? ORDER BY rowid`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	failures := make([]StateFailure, 0)
+	for rows.Next() {
+		var failure StateFailure
+		if err := rows.Scan(&failure.Name, &failure.Link, &failure.Reason, &failure.Denied); err != nil {
+			return nil, err
+		}
+		failures = append(failures, failure)
+	}
+	return failures, rows.Err()
+}
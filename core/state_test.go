@@ -0,0 +1,60 @@
+package core_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateDB(t *testing.T) {
+	root := t.TempDir()
+	state, err := core.OpenStateDB(filepath.Join(root, "state", "feishu2md.db"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer state.Close()
+
+	first := time.Unix(1700000000, 0)
+	assert.NoError(t, state.SaveFiles(root, []core.ManifestEntry{
+		{Path: "a.md", ObjToken: "doxa", ObjType: "docx", Title: "A", Revision: 3, SHA256: "1"},
+		{Path: "b.md", ObjToken: "doxb", ObjType: "docx", Title: "B", SHA256: "2"},
+	}, first))
+	second := first.Add(time.Hour)
+	assert.NoError(t, state.SaveFiles(root, []core.ManifestEntry{
+		{Path: "a.md", ObjToken: "doxa", ObjType: "docx", Title: "A", Revision: 3, SHA256: "1"},
+		{Path: "c.md", ObjToken: "doxc", ObjType: "docx", Title: "C", SHA256: "3"},
+	}, second))
+
+	files, err := state.Files(root)
+	assert.NoError(t, err)
+	if !assert.Len(t, files, 2) {
+		return
+	}
+	assert.Equal(t, "a.md", files[0].Path)
+	assert.Equal(t, int64(3), files[0].Revision)
+	// Unchanged files keep the time of their export
+	assert.Equal(t, first, files[0].ExportedAt)
+	assert.Equal(t, "c.md", files[1].Path)
+	assert.Equal(t, second, files[1].ExportedAt)
+
+	id, err := state.StartRun(first)
+	assert.NoError(t, err)
+	assert.NoError(t, state.FinishRun(core.StateRun{
+		ID: id, FinishedAt: second, Exported: 2, Failed: 1, Error: "1 document(s) failed",
+	}, []core.StateFailure{{Name: "D", Link: "https://sample.feishu.cn/docx/doxd", Reason: "timeout"}}))
+
+	runs, err := state.Runs(10)
+	assert.NoError(t, err)
+	if !assert.Len(t, runs, 1) {
+		return
+	}
+	assert.Equal(t, 2, runs[0].Exported)
+	assert.Equal(t, 1, runs[0].Failed)
+	assert.Equal(t, second, runs[0].FinishedAt)
+	failures, err := state.Failures(id)
+	assert.NoError(t, err)
+	assert.Equal(t, []core.StateFailure{{Name: "D", Link: "https://sample.feishu.cn/docx/doxd", Reason: "timeout"}}, failures)
+}
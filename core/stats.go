@@ -0,0 +1,144 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chyroc/lark"
+)
+
+// Stats collects the metrics of the OPEN API calls made by a client
+type Stats struct {
+	mu              sync.Mutex
+	apis            map[string]*apiStat
+	downloadedBytes int64
+}
+
+type apiStat struct {
+	count int
+	// retries are the calls repeated after a failure, e.g. to resume a download
+	retries   int
+	errors    int
+	durations []time.Duration
+}
+
+func newStats() *Stats {
+	return &Stats{apis: make(map[string]*apiStat)}
+}
+
+// middleware records the count, error and latency of every API call
+func (s *Stats) middleware(next lark.ApiEndpoint) lark.ApiEndpoint {
+	return func(ctx context.Context, req *lark.RawRequestReq, resp interface{}) (*lark.Response, error) {
+		start := time.Now()
+		response, err := next(ctx, req, resp)
+		s.recordCall(req.Scope+"#"+req.API, time.Since(start), err)
+		return response, err
+	}
+}
+
+func (s *Stats) recordCall(api string, elapsed time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stat := s.apiStat(api)
+	stat.count++
+	if err != nil {
+		stat.errors++
+	}
+	stat.durations = append(stat.durations, elapsed)
+}
+
+// recordRetry counts a call of the API repeated after a failure
+func (s *Stats) recordRetry(api string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiStat(api).retries++
+}
+
+// apiStat returns the metrics of the API, the lock must be held
+func (s *Stats) apiStat(api string) *apiStat {
+	stat, ok := s.apis[api]
+	if !ok {
+		stat = &apiStat{}
+		s.apis[api] = stat
+	}
+	return stat
+}
+
+func (s *Stats) addDownloadedBytes(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.downloadedBytes += n
+}
+
+// TotalCalls returns the number of API calls made so far
+func (s *Stats) TotalCalls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	total := 0
+	for _, stat := range s.apis {
+		total += stat.count
+	}
+	return total
+}
+
+// TotalRetries returns the number of API calls repeated after a failure so far
+func (s *Stats) TotalRetries() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	total := 0
+	for _, stat := range s.apis {
+		total += stat.retries
+	}
+	return total
+}
+
+// DownloadedBytes returns the number of bytes of the downloaded images and files
+func (s *Stats) DownloadedBytes() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.downloadedBytes
+}
+
+// String renders the metrics as a plain text table sorted by API name
+func (s *Stats) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.apis))
+	for name := range s.apis {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := new(strings.Builder)
+	buf.WriteString(fmt.Sprintf("%-40s %8s %8s %8s %10s %10s %10s\n", "API", "CALLS", "RETRIES", "ERRORS", "P50", "P95", "MAX"))
+	total, retries := 0, 0
+	for _, name := range names {
+		stat := s.apis[name]
+		total += stat.count
+		retries += stat.retries
+		durations := append([]time.Duration(nil), stat.durations...)
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		buf.WriteString(fmt.Sprintf("%-40s %8d %8d %8d %10s %10s %10s\n",
+			name, stat.count, stat.retries, stat.errors,
+			percentile(durations, 0.5).Round(time.Millisecond),
+			percentile(durations, 0.95).Round(time.Millisecond),
+			percentile(durations, 1).Round(time.Millisecond),
+		))
+	}
+	buf.WriteString(fmt.Sprintf("Total API calls: %d, retries: %d, downloaded bytes: %d\n", total, retries, s.downloadedBytes))
+	return buf.String()
+}
+
+// percentile returns the p-th percentile of the sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	index := int(float64(len(sorted)-1) * p)
+	return sorted[index]
+}
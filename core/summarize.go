@@ -0,0 +1,115 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// summaryMaxRunes limits the length of the document sent to the LLM
+const summaryMaxRunes = 12000
+
+const summaryPrompt = `You summarize documents for the index page of a knowledge base.
+Reply with a json object only, in the language of the document:
+{"summary": "<a summary within 100 words>", "tags": ["<3 to 5 short tags>"]}`
+
+// Summary is the summary and tags generated for a document
+type Summary struct {
+	Summary string   `json:"summary"`
+	Tags    []string `json:"tags"`
+}
+
+// Summarizer generates the summaries of documents with an OpenAI compatible
+// chat completions API
+type Summarizer struct {
+	endpoint   string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+func NewSummarizer(config LLMConfig) *Summarizer {
+	return &Summarizer{
+		endpoint:   strings.TrimSuffix(config.Endpoint, "/"),
+		apiKey:     config.APIKey,
+		model:      config.Model,
+		httpClient: &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatCompletionReq struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+}
+
+type chatCompletionResp struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// Summarize asks the LLM for the summary and tags of a markdown document
+func (s *Summarizer) Summarize(ctx context.Context, title, markdown string) (*Summary, error) {
+	if runes := []rune(markdown); len(runes) > summaryMaxRunes {
+		markdown = string(runes[:summaryMaxRunes])
+	}
+	body, err := json.Marshal(chatCompletionReq{
+		Model: s.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: summaryPrompt},
+			{Role: "user", Content: "Title: " + title + "\n\n" + markdown},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("llm endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	completion := chatCompletionResp{}
+	if err := json.Unmarshal(data, &completion); err != nil {
+		return nil, err
+	}
+	if len(completion.Choices) == 0 {
+		return nil, fmt.Errorf("llm endpoint returned no choices")
+	}
+	// Some models wrap the json in a code fence
+	content := strings.TrimSpace(completion.Choices[0].Message.Content)
+	content = strings.TrimPrefix(strings.TrimPrefix(content, "```json"), "```")
+	content = strings.TrimSuffix(strings.TrimSpace(content), "```")
+
+	summary := Summary{}
+	if err := json.Unmarshal([]byte(content), &summary); err != nil {
+		return nil, fmt.Errorf("failed to parse the summary: %v", err)
+	}
+	return &summary, nil
+}
@@ -0,0 +1,52 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{
+				"message": map[string]string{
+					"role":    "assistant",
+					"content": "```json\n{\"summary\": \"A summary\", \"tags\": [\"go\", \"feishu\"]}\n```",
+				},
+			}},
+		})
+	}))
+	defer server.Close()
+
+	summarizer := core.NewSummarizer(core.LLMConfig{Endpoint: server.URL + "/v1/", APIKey: "key", Model: "gpt"})
+	summary, err := summarizer.Summarize(context.Background(), "Title", "# Title\n\ncontent")
+	assert.NoError(t, err)
+	assert.Equal(t, &core.Summary{Summary: "A summary", Tags: []string{"go", "feishu"}}, summary)
+}
+
+func TestFrontMatter(t *testing.T) {
+	assert.Equal(t,
+		"---\ntitle: \"Say \\\"hi\\\"\"\ntags: [\"a\",\"b\"]\n---\n\n",
+		core.FrontMatter(
+			core.FrontMatterField{Key: "title", Value: `Say "hi"`},
+			core.FrontMatterField{Key: "tags", Value: []string{"a", "b"}},
+		))
+
+	// The later field of a key takes precedence
+	assert.Equal(t,
+		"---\ntitle: \"Custom\"\nupdated: \"2024-01-02\"\n---\n\n",
+		core.FrontMatter(
+			core.FrontMatterField{Key: "title", Value: "Title"},
+			core.FrontMatterField{Key: "updated", Value: "2024-01-02T03:04:05Z"},
+			core.FrontMatterField{Key: "title", Value: "Custom"},
+			core.FrontMatterField{Key: "updated", Value: "2024-01-02"},
+		))
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chyroc/lark"
+)
+
+// TableMeta is the metadata of the spreadsheet or the bitable app of an
+// embedded table, shown in its placeholder when the content is not available
+type TableMeta struct {
+	Title      string
+	URL        string
+	ModifiedAt time.Time
+}
+
+// GetTableMeta returns the metadata of a spreadsheet or a bitable app,
+// docType is "sheet" or "bitable"
+func (c *Client) GetTableMeta(ctx context.Context, token, docType string) (*TableMeta, error) {
+	withURL := true
+	resp, _, err := c.lark().Drive.GetDriveFileMeta(ctx, &lark.GetDriveFileMetaReq{
+		RequestDocs: []*lark.GetDriveFileMetaReqRequestDocs{{DocToken: token, DocType: docType}},
+		WithURL:     &withURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Metas) == 0 {
+		return nil, fmt.Errorf("failed to get the meta of %s %s", docType, token)
+	}
+	meta := &TableMeta{Title: resp.Metas[0].Title, URL: resp.Metas[0].URL}
+	if seconds, err := strconv.ParseInt(resp.Metas[0].LatestModifyTime, 10, 64); err == nil && seconds > 0 {
+		meta.ModifiedAt = time.Unix(seconds, 0)
+	}
+	return meta, nil
+}
+
+// tablePlaceholder renders the placeholder of an embedded sheet or bitable
+// with the metadata of its spreadsheet or bitable app if available, and a
+// link to open it in feishu
+func (p *Parser) tablePlaceholder(heading, docType, token, link, note string) string {
+	buf := new(strings.Builder)
+	buf.WriteString("\n\n")
+	buf.WriteString(fmt.Sprintf("> **📊 %s**\n", heading))
+	buf.WriteString(">\n")
+	if token != "" {
+		appToken, _, _ := strings.Cut(token, "_")
+		appName := "电子表格"
+		if docType == "bitable" {
+			appName = "多维表格"
+		}
+		if p.client != nil {
+			if meta, err := p.client.GetTableMeta(p.ctx, appToken, docType); err == nil {
+				buf.WriteString(fmt.Sprintf("> 名称: %s\n", meta.Title))
+				if !meta.ModifiedAt.IsZero() {
+					buf.WriteString(fmt.Sprintf("> 最后更新: %s\n", meta.ModifiedAt.Format("2006-01-02 15:04")))
+				}
+			}
+		}
+		buf.WriteString(fmt.Sprintf("> 所属应用: %s `%s`\n", appName, appToken))
+		buf.WriteString(fmt.Sprintf("> Token: `%s`\n", token))
+		buf.WriteString(fmt.Sprintf("> 链接: [在飞书中打开](%s)\n", link))
+	}
+	buf.WriteString(">\n")
+	buf.WriteString(fmt.Sprintf("> *%s*\n", note))
+	buf.WriteString("\n\n")
+	return buf.String()
+}
@@ -0,0 +1,48 @@
+package core_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeCSV(t *testing.T) {
+	data, err := core.EncodeCSV([][]string{{"名称", "备注"}, {"a,b", "say \"hi\"\nbye"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "\ufeff名称,备注\n\"a,b\",\"say \"\"hi\"\"\nbye\"\n", string(data))
+}
+
+func TestEncodeXLSX(t *testing.T) {
+	rows := make([][]string, 2)
+	rows[0] = make([]string, 28)
+	rows[0][0], rows[0][27] = "名称", "<&>"
+	rows[1] = []string{"  x"}
+	data, err := core.EncodeXLSX(rows)
+	if !assert.NoError(t, err) {
+		return
+	}
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if !assert.NoError(t, err) {
+		return
+	}
+	parts := map[string]string{}
+	for _, f := range reader.File {
+		rc, err := f.Open()
+		if !assert.NoError(t, err) {
+			return
+		}
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(content)
+	}
+	assert.Contains(t, parts, "[Content_Types].xml")
+	assert.Contains(t, parts, "xl/workbook.xml")
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	assert.Contains(t, sheet, `<c r="A1" t="inlineStr"><is><t xml:space="preserve">名称</t></is></c>`)
+	assert.Contains(t, sheet, `<c r="AB1" t="inlineStr"><is><t xml:space="preserve">&lt;&amp;&gt;</t></is></c>`)
+	assert.Contains(t, sheet, `<row r="2"><c r="A2" t="inlineStr"><is><t xml:space="preserve">  x</t></is></c></row>`)
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"strings"
+	"text/template"
+)
+
+// DocumentVars are the variables available to the header and footer templates
+type DocumentVars struct {
+	Title string
+	Token string
+	URL   string
+	// Path is the path of the markdown file relative to the root of the export
+	Path string
+	// RootPath is the relative path from the markdown file to the root of the
+	// export, e.g. "../.." to link back to an index page
+	RootPath   string
+	ExportTime string
+}
+
+// DocumentTemplate injects the user defined header and footer into the documents
+type DocumentTemplate struct {
+	header *template.Template
+	footer *template.Template
+}
+
+// NewDocumentTemplate parses the header and footer templates written in the
+// text/template syntax, e.g. "> Exported at {{.ExportTime}}"
+func NewDocumentTemplate(header, footer string) (*DocumentTemplate, error) {
+	t := &DocumentTemplate{}
+	var err error
+	if header != "" {
+		if t.header, err = template.New("header").Parse(header); err != nil {
+			return nil, err
+		}
+	}
+	if footer != "" {
+		if t.footer, err = template.New("footer").Parse(footer); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Wrap returns the markdown with the rendered header and footer
+func (t *DocumentTemplate) Wrap(markdown string, vars DocumentVars) (string, error) {
+	buf := new(strings.Builder)
+	if t.header != nil {
+		if err := t.header.Execute(buf, vars); err != nil {
+			return "", err
+		}
+		buf.WriteString("\n\n")
+	}
+	buf.WriteString(markdown)
+	if t.footer != nil {
+		if !strings.HasSuffix(markdown, "\n") {
+			buf.WriteString("\n")
+		}
+		buf.WriteString("\n")
+		if err := t.footer.Execute(buf, vars); err != nil {
+			return "", err
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,29 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentTemplateWrap(t *testing.T) {
+	tmpl, err := core.NewDocumentTemplate(
+		"> 本文导出自 [{{.Title}}]({{.URL}})",
+		"[返回目录]({{.RootPath}}/sitemap.md)",
+	)
+	assert.NoError(t, err)
+
+	md, err := tmpl.Wrap("# Title\n\ncontent\n", core.DocumentVars{
+		Title:    "Title",
+		URL:      "https://example.feishu.cn/docx/token",
+		RootPath: "..",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"> 本文导出自 [Title](https://example.feishu.cn/docx/token)\n\n# Title\n\ncontent\n\n[返回目录](../sitemap.md)\n",
+		md)
+
+	_, err = core.NewDocumentTemplate("{{.Title", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chyroc/lark"
+)
+
+type traceDocumentKey struct{}
+
+// WithTraceDocument associates the API calls made with the context to a document token
+func WithTraceDocument(ctx context.Context, docToken string) context.Context {
+	return context.WithValue(ctx, traceDocumentKey{}, docToken)
+}
+
+// traceRecord is a line of the trace file
+type traceRecord struct {
+	Time      string      `json:"time"`
+	Document  string      `json:"document,omitempty"`
+	API       string      `json:"api"`
+	Method    string      `json:"method"`
+	URL       string      `json:"url"`
+	Request   interface{} `json:"request,omitempty"`
+	Response  interface{} `json:"response,omitempty"`
+	Status    int         `json:"status,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Elapsed   string      `json:"elapsed"`
+}
+
+// tracer writes every API interaction as a json line once a writer is set
+type tracer struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+// SetTraceWriter enables writing the redacted API requests and responses into w as json lines
+func (c *Client) SetTraceWriter(w io.Writer) {
+	c.tracer.mu.Lock()
+	defer c.tracer.mu.Unlock()
+	c.tracer.writer = w
+}
+
+func (t *tracer) middleware(next lark.ApiEndpoint) lark.ApiEndpoint {
+	return func(ctx context.Context, req *lark.RawRequestReq, resp interface{}) (*lark.Response, error) {
+		start := time.Now()
+		response, err := next(ctx, req, resp)
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.writer == nil {
+			return response, err
+		}
+
+		record := traceRecord{
+			Time:     start.Format(time.RFC3339Nano),
+			API:      req.Scope + "#" + req.API,
+			Method:   req.Method,
+			URL:      req.URL,
+			Request:  redact(req.Body),
+			Response: redact(resp),
+			Elapsed:  time.Since(start).String(),
+		}
+		if docToken, ok := ctx.Value(traceDocumentKey{}).(string); ok {
+			record.Document = docToken
+		}
+		if response != nil {
+			record.Status = response.StatusCode
+			record.RequestID = response.RequestID
+		}
+		if err != nil {
+			record.Error = err.Error()
+		}
+		if line, merr := json.Marshal(record); merr == nil {
+			t.writer.Write(append(line, '\n'))
+		}
+		return response, err
+	}
+}
+
+// sensitiveTraceKeys are the fields whose values must never be written into the trace file
+var sensitiveTraceKeys = []string{"secret", "access_token", "refresh_token", "app_ticket", "password"}
+
+// redact converts v into plain json values with the credentials masked
+func redact(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var plain interface{}
+	if err := json.Unmarshal(data, &plain); err != nil {
+		return nil
+	}
+	return redactValue(plain)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for key, item := range val {
+			lowerKey := strings.ToLower(key)
+			masked := false
+			for _, sensitive := range sensitiveTraceKeys {
+				if strings.Contains(lowerKey, sensitive) {
+					val[key] = "***"
+					masked = true
+					break
+				}
+			}
+			if !masked {
+				val[key] = redactValue(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = redactValue(item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/chyroc/lark"
+)
+
+// compileTrailingPatterns compiles OutputConfig.TrailingPatterns
+func compileTrailingPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trailing pattern %q: %v", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// blockPlainText returns the text of a block and its children without any style
+func (p *Parser) blockPlainText(b *lark.DocxBlock) string {
+	buf := new(strings.Builder)
+	texts := []*lark.DocxBlockText{
+		b.Text, b.Heading1, b.Heading2, b.Heading3, b.Heading4, b.Heading5,
+		b.Heading6, b.Heading7, b.Heading8, b.Heading9, b.Bullet, b.Ordered,
+		b.Code, b.Quote, b.Equation, b.Todo,
+	}
+	for _, text := range texts {
+		if text == nil {
+			continue
+		}
+		for _, e := range text.Elements {
+			if e != nil && e.TextRun != nil {
+				buf.WriteString(e.TextRun.Content)
+			}
+		}
+	}
+	for _, childId := range b.Children {
+		if child := p.blockMap[childId]; child != nil {
+			buf.WriteString(p.blockPlainText(child))
+		}
+	}
+	return buf.String()
+}
+
+// isTrailingNoise reports whether a block at the end of a document should be
+// dropped according to OutputConfig.TrailingBlockTypes and TrailingPatterns
+func (p *Parser) isTrailingNoise(b *lark.DocxBlock) bool {
+	for _, blockType := range p.config.TrailingBlockTypes {
+		if b.BlockType == lark.DocxBlockType(blockType) {
+			return true
+		}
+	}
+	if len(p.trailingPatterns) == 0 {
+		return false
+	}
+	text := p.blockPlainText(b)
+	for _, re := range p.trailingPatterns {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// trimTrailingBlocks drops the noise blocks at the end of the children of a
+// page, together with the empty paragraphs around them
+func (p *Parser) trimTrailingBlocks(children []string) []string {
+	if len(p.config.TrailingBlockTypes) == 0 && len(p.trailingPatterns) == 0 {
+		return children
+	}
+	end := len(children)
+	for i := len(children) - 1; i >= 0; i-- {
+		b := p.blockMap[children[i]]
+		if b == nil {
+			continue
+		}
+		if b.BlockType == lark.DocxBlockTypeText && len(b.Children) == 0 &&
+			strings.TrimSpace(p.blockPlainText(b)) == "" {
+			// An empty paragraph is dropped only before a noise block
+			continue
+		}
+		if !p.isTrailingNoise(b) {
+			break
+		}
+		end = i
+	}
+	return children[:end]
+}
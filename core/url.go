@@ -0,0 +1,75 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Resource types recognized by ParseFeishuURL, the types of the documents
+// are the object types of the OPEN API
+const (
+	ResourceDocx      = "docx"
+	ResourceDocs      = "docs"
+	ResourceWiki      = "wiki"
+	ResourceWikiSpace = "wiki_space"
+	ResourceFolder    = "folder"
+	ResourceSheet     = "sheet"
+	ResourceBitable   = "bitable"
+	ResourceMindnote  = "mindnote"
+	ResourceFile      = "file"
+	// ResourceShortLink is a share link such as https://xxx.feishu.cn/s/abc,
+	// see Client.ExpandShortLink for its target
+	ResourceShortLink = "short_link"
+)
+
+// Resource is a feishu or larksuite resource identified by a URL
+type Resource struct {
+	Type  string
+	Token string
+	// Host is the scheme and the host of the URL, e.g. https://xxx.feishu.cn
+	Host string
+	// Query keeps the parameters such as the sheet or the table of the URL
+	Query url.Values
+}
+
+// resourcePaths maps the first segment of the paths to the resource types
+var resourcePaths = map[string]string{
+	"docx":      ResourceDocx,
+	"docs":      ResourceDocs,
+	"wiki":      ResourceWiki,
+	"sheets":    ResourceSheet,
+	"base":      ResourceBitable,
+	"mindnotes": ResourceMindnote,
+	"file":      ResourceFile,
+	"s":         ResourceShortLink,
+}
+
+var resourceTokenRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ParseFeishuURL recognizes the type and the token of a feishu or larksuite
+// URL, such as a document, a wiki node or space, a folder or a share link.
+// The query and the fragment of the URL are allowed.
+func ParseFeishuURL(rawURL string) (Resource, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return Resource{}, fmt.Errorf("invalid feishu/larksuite URL: %s", rawURL)
+	}
+	resource := Resource{Host: u.Scheme + "://" + u.Host, Query: u.Query()}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch {
+	case len(segments) == 3 && segments[0] == "wiki" && (segments[1] == "settings" || segments[1] == "space"):
+		resource.Type, resource.Token = ResourceWikiSpace, segments[2]
+	case len(segments) == 3 && segments[0] == "drive" && segments[1] == "folder":
+		resource.Type, resource.Token = ResourceFolder, segments[2]
+	case len(segments) == 2 && resourcePaths[segments[0]] != "":
+		resource.Type, resource.Token = resourcePaths[segments[0]], segments[1]
+	default:
+		return Resource{}, fmt.Errorf("unsupported feishu/larksuite URL: %s", rawURL)
+	}
+	if !resourceTokenRegexp.MatchString(resource.Token) {
+		return Resource{}, fmt.Errorf("invalid token in feishu/larksuite URL: %s", rawURL)
+	}
+	return resource, nil
+}
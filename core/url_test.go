@@ -0,0 +1,41 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFeishuURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		resType string
+		token   string
+	}{
+		{"https://sample.feishu.cn/docx/doccnByZP6puODElAYySJkPIfUb", core.ResourceDocx, "doccnByZP6puODElAYySJkPIfUb"},
+		{"https://sample.sg.larksuite.com/wiki/wikcnKQ1k3p?from=from_copylink#share", core.ResourceWiki, "wikcnKQ1k3p"},
+		{"https://sample.feishu.cn/wiki/settings/7075377271827264924", core.ResourceWikiSpace, "7075377271827264924"},
+		{"https://sample.feishu.cn/wiki/space/7075377271827264924/", core.ResourceWikiSpace, "7075377271827264924"},
+		{"https://sample.feishu.cn/drive/folder/fldcnAbC123?from=space", core.ResourceFolder, "fldcnAbC123"},
+		{"https://sample.feishu.cn/sheets/shtcnAbC?sheet=Ml1QzO", core.ResourceSheet, "shtcnAbC"},
+		{"https://sample.feishu.cn/base/bascnAbC?table=tblXyz", core.ResourceBitable, "bascnAbC"},
+		{"https://sample.feishu.cn/s/Ab-c_1", core.ResourceShortLink, "Ab-c_1"},
+	}
+	for _, tt := range tests {
+		resource, err := core.ParseFeishuURL(tt.url)
+		if assert.NoError(t, err, tt.url) {
+			assert.Equal(t, tt.resType, resource.Type, tt.url)
+			assert.Equal(t, tt.token, resource.Token, tt.url)
+		}
+	}
+
+	resource, _ := core.ParseFeishuURL("https://sample.feishu.cn/sheets/shtcnAbC?sheet=Ml1QzO")
+	assert.Equal(t, "https://sample.feishu.cn", resource.Host)
+	assert.Equal(t, "Ml1QzO", resource.Query.Get("sheet"))
+
+	for _, url := range []string{"https://google.com", "ftp://sample.feishu.cn/docx/abc", "https://sample.feishu.cn/docx/a%20b", "https://sample.feishu.cn/drive/home/"} {
+		_, err := core.ParseFeishuURL(url)
+		assert.Error(t, err, url)
+	}
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// shortLinkRegexp matches the short links of feishu and larksuite
+var shortLinkRegexp = regexp.MustCompile(`^https://([\w-]+\.)*(feishu\.cn|larksuite\.com)/s/[A-Za-z0-9_-]+`)
+
+// CleanURL removes the query parameters of a link, a name ending with * removes
+// all the parameters with the prefix, e.g. utm_*. The order of the others is kept.
+func CleanURL(rawURL string, params []string) string {
+	if len(params) == 0 {
+		return rawURL
+	}
+	base, fragment, hasFragment := strings.Cut(rawURL, "#")
+	path, query, hasQuery := strings.Cut(base, "?")
+	if !hasQuery {
+		return rawURL
+	}
+	kept := make([]string, 0)
+	for _, pair := range strings.Split(query, "&") {
+		key, _, _ := strings.Cut(pair, "=")
+		if name, err := url.QueryUnescape(key); err == nil {
+			key = name
+		}
+		if pair == "" || matchParam(key, params) {
+			continue
+		}
+		kept = append(kept, pair)
+	}
+	result := path
+	if len(kept) > 0 {
+		result += "?" + strings.Join(kept, "&")
+	}
+	if hasFragment {
+		result += "#" + fragment
+	}
+	return result
+}
+
+func matchParam(key string, params []string) bool {
+	for _, param := range params {
+		if prefix, ok := strings.CutSuffix(param, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == param {
+			return true
+		}
+	}
+	return false
+}
+
+// IsShortLink reports whether the link is a feishu short link
+func IsShortLink(link string) bool {
+	return shortLinkRegexp.MatchString(link)
+}
+
+// ExpandShortLink returns the link a feishu short link redirects to, the
+// results are cached by the client
+func (c *Client) ExpandShortLink(ctx context.Context, link string) (string, error) {
+	if target, ok := c.shortLinks.Load(link); ok {
+		return target.(string), nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, link, nil)
+	if err != nil {
+		return "", err
+	}
+	httpClient := &http.Client{
+		Timeout: defaultTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	target := link
+	if location, err := resp.Location(); err == nil {
+		target = location.String()
+	}
+	c.shortLinks.Store(link, target)
+	return target, nil
+}
+
+// cleanLink expands the short link and removes the configured query parameters
+func (p *Parser) cleanLink(link string) string {
+	if p.config.ExpandShortLinks && p.client != nil && IsShortLink(link) {
+		if target, err := p.client.ExpandShortLink(p.ctx, link); err == nil {
+			link = target
+		}
+	}
+	return CleanURL(link, p.config.LinkStripParams)
+}
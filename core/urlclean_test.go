@@ -0,0 +1,22 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanURL(t *testing.T) {
+	params := []string{"from", "utm_*"}
+	assert.Equal(t, "https://example.com/a?id=1&b=2#top",
+		core.CleanURL("https://example.com/a?from=wiki&id=1&utm_source=x&b=2#top", params))
+	assert.Equal(t, "https://example.com/a",
+		core.CleanURL("https://example.com/a?from=wiki", params))
+	assert.Equal(t, "https://example.com/a?utm=1",
+		core.CleanURL("https://example.com/a?utm=1", params))
+	assert.Equal(t, "https://example.com/a?from=wiki", core.CleanURL("https://example.com/a?from=wiki", nil))
+
+	assert.True(t, core.IsShortLink("https://example.feishu.cn/s/AbC123"))
+	assert.False(t, core.IsShortLink("https://example.feishu.cn/docx/AbC123"))
+}
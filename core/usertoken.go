@@ -0,0 +1,24 @@
+package core
+
+import (
+	"context"
+
+	"github.com/chyroc/lark"
+)
+
+// SetUserAccessToken makes the requests on behalf of a user rather than the
+// app, e.g. to read the documents in the personal space of the user. The APIs
+// without the support of a user token still use the tenant access token.
+func (c *Client) SetUserAccessToken(token string) {
+	c.userAccessToken = token
+}
+
+// userTokenMiddleware sets the user access token of the client on every request
+func (c *Client) userTokenMiddleware(next lark.ApiEndpoint) lark.ApiEndpoint {
+	return func(ctx context.Context, req *lark.RawRequestReq, resp interface{}) (*lark.Response, error) {
+		if c.userAccessToken != "" && req.MethodOption != nil {
+			lark.WithUserAccessToken(c.userAccessToken)(req.MethodOption)
+		}
+		return next(ctx, req, resp)
+	}
+}
@@ -0,0 +1,260 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Supported values of VectorConfig.Backend
+const (
+	VectorBackendQdrant   = "qdrant"
+	VectorBackendMilvus   = "milvus"
+	VectorBackendPGVector = "pgvector"
+)
+
+// VectorConfig configures the vector database the chunks of the documents
+// are pushed to, with the embedding API computing their vectors
+type VectorConfig struct {
+	Backend string `json:"backend"`
+	// URL is the REST endpoint of qdrant or milvus, e.g.
+	// http://localhost:6333, or the connection string of postgres
+	URL    string `json:"url"`
+	APIKey string `json:"api_key"`
+	// Collection is the collection of qdrant or milvus, or the table of pgvector
+	Collection string    `json:"collection"`
+	Embedding  LLMConfig `json:"embedding"`
+}
+
+// VectorStore keeps the chunks of the documents with their vectors. The
+// collection is created with the dimension of the first vectors.
+type VectorStore interface {
+	// Replace replaces the chunks of a document
+	Replace(ctx context.Context, docID string, chunks []Chunk, vectors [][]float32) error
+	Close() error
+}
+
+// NewVectorStore connects to the vector database of the config
+func NewVectorStore(config VectorConfig) (VectorStore, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("the url of the vector database is required")
+	}
+	collection := config.Collection
+	if collection == "" {
+		collection = "feishu2md"
+	}
+	switch config.Backend {
+	case VectorBackendQdrant, VectorBackendMilvus:
+		return &restVectorStore{
+			backend:    config.Backend,
+			url:        strings.TrimSuffix(config.URL, "/"),
+			apiKey:     config.APIKey,
+			collection: collection,
+			httpClient: &http.Client{Timeout: time.Minute},
+		}, nil
+	case VectorBackendPGVector:
+		return newPGVectorStore(config.URL, collection)
+	}
+	return nil, fmt.Errorf("unsupported vector backend: %s", config.Backend)
+}
+
+// chunkID returns a stable id of the i-th chunk of a document, a re-export
+// overwrites the same points
+func chunkID(docID string, i int) [16]byte {
+	var id [16]byte
+	sum := sha1.Sum([]byte(docID + "#" + strconv.Itoa(i)))
+	copy(id[:], sum[:16])
+	// A version 5 UUID as required by qdrant
+	id[6] = id[6]&0x0f | 0x50
+	id[8] = id[8]&0x3f | 0x80
+	return id
+}
+
+func chunkUUID(docID string, i int) string {
+	id := chunkID(docID, i)
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])
+}
+
+// chunkInt64 returns the id as a positive int64, the primary key of milvus
+func chunkInt64(docID string, i int) int64 {
+	id := chunkID(docID, i)
+	return int64(binary.BigEndian.Uint64(id[:8]) &^ (1 << 63))
+}
+
+// restVectorStore stores the chunks with the REST API of qdrant or milvus
+type restVectorStore struct {
+	backend    string
+	url        string
+	apiKey     string
+	collection string
+	httpClient *http.Client
+	mu         sync.Mutex
+	created    bool
+}
+
+func (s *restVectorStore) Replace(ctx context.Context, docID string, chunks []Chunk, vectors [][]float32) error {
+	if len(chunks) > 0 {
+		if err := s.ensureCollection(ctx, len(vectors[0])); err != nil {
+			return err
+		}
+	}
+	if s.backend == VectorBackendQdrant {
+		return s.replaceQdrant(ctx, docID, chunks, vectors)
+	}
+	return s.replaceMilvus(ctx, docID, chunks, vectors)
+}
+
+// ensureCollection creates the collection if it does not exist yet
+func (s *restVectorStore) ensureCollection(ctx context.Context, dimension int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.created {
+		return nil
+	}
+	if s.backend == VectorBackendQdrant {
+		exists := struct {
+			Result struct {
+				Exists bool `json:"exists"`
+			} `json:"result"`
+		}{}
+		if err := s.do(ctx, http.MethodGet, "/collections/"+s.collection+"/exists", nil, &exists); err != nil {
+			return err
+		}
+		if !exists.Result.Exists {
+			if err := s.do(ctx, http.MethodPut, "/collections/"+s.collection, map[string]interface{}{
+				"vectors": map[string]interface{}{"size": dimension, "distance": "Cosine"},
+			}, nil); err != nil {
+				return err
+			}
+		}
+	} else {
+		has := struct {
+			Data struct {
+				Has bool `json:"has"`
+			} `json:"data"`
+		}{}
+		if err := s.do(ctx, http.MethodPost, "/v2/vectordb/collections/has",
+			map[string]interface{}{"collectionName": s.collection}, &has); err != nil {
+			return err
+		}
+		if !has.Data.Has {
+			// The quick setup has an int64 "id" and a "vector" field, the
+			// other fields are dynamic
+			if err := s.do(ctx, http.MethodPost, "/v2/vectordb/collections/create", map[string]interface{}{
+				"collectionName": s.collection,
+				"dimension":      dimension,
+				"metricType":     "COSINE",
+			}, nil); err != nil {
+				return err
+			}
+		}
+	}
+	s.created = true
+	return nil
+}
+
+func (s *restVectorStore) replaceQdrant(ctx context.Context, docID string, chunks []Chunk, vectors [][]float32) error {
+	if err := s.do(ctx, http.MethodPost, "/collections/"+s.collection+"/points/delete?wait=true", map[string]interface{}{
+		"filter": map[string]interface{}{
+			"must": []interface{}{map[string]interface{}{"key": "doc_id", "match": map[string]string{"value": docID}}},
+		},
+	}, nil); err != nil && len(chunks) > 0 {
+		return err
+	}
+	if len(chunks) == 0 {
+		return nil
+	}
+	points := make([]interface{}, len(chunks))
+	for i, chunk := range chunks {
+		points[i] = map[string]interface{}{"id": chunkUUID(docID, i), "vector": vectors[i], "payload": chunk}
+	}
+	return s.do(ctx, http.MethodPut, "/collections/"+s.collection+"/points?wait=true",
+		map[string]interface{}{"points": points}, nil)
+}
+
+func (s *restVectorStore) replaceMilvus(ctx context.Context, docID string, chunks []Chunk, vectors [][]float32) error {
+	filter, _ := json.Marshal(docID)
+	if err := s.do(ctx, http.MethodPost, "/v2/vectordb/entities/delete", map[string]interface{}{
+		"collectionName": s.collection,
+		"filter":         "doc_id == " + string(filter),
+	}, nil); err != nil && len(chunks) > 0 {
+		return err
+	}
+	if len(chunks) == 0 {
+		return nil
+	}
+	data := make([]interface{}, len(chunks))
+	for i, chunk := range chunks {
+		data[i] = map[string]interface{}{
+			"id":           chunkInt64(docID, i),
+			"vector":       vectors[i],
+			"doc_id":       chunk.DocID,
+			"heading_path": chunk.HeadingPath,
+			"text":         chunk.Text,
+			"url":          chunk.URL,
+		}
+	}
+	return s.do(ctx, http.MethodPost, "/v2/vectordb/entities/upsert",
+		map[string]interface{}{"collectionName": s.collection, "data": data}, nil)
+}
+
+// do sends a json request to the REST API, the errors of milvus are reported
+// by the code in the body
+func (s *restVectorStore) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.url+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		if s.backend == VectorBackendQdrant {
+			req.Header.Set("api-key", s.apiKey)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+s.apiKey)
+		}
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s: %s", s.backend, resp.Status, strings.TrimSpace(string(data)))
+	}
+	status := struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}{}
+	if json.Unmarshal(data, &status) == nil && status.Code != 0 {
+		return fmt.Errorf("%s returned an error: %s", s.backend, status.Message)
+	}
+	if result != nil {
+		return json.Unmarshal(data, result)
+	}
+	return nil
+}
+
+func (s *restVectorStore) Close() error {
+	return nil
+}
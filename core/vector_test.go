@@ -0,0 +1,82 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmbedder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/embeddings", r.URL.Path)
+		req := struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&req)
+		assert.Equal(t, "text-embedding-3-small", req.Model)
+		// Out of order on purpose
+		w.Write([]byte(`{"data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}]}`))
+	}))
+	defer server.Close()
+
+	embedder := core.NewEmbedder(core.LLMConfig{Endpoint: server.URL + "/v1/", Model: "text-embedding-3-small"})
+	vectors, err := embedder.Embed(context.Background(), []string{"a", "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]float32{{1, 0}, {0, 1}}, vectors)
+}
+
+func TestVectorStoreQdrant(t *testing.T) {
+	requests := make([]string, 0)
+	var points []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get("api-key"))
+		switch r.URL.Path {
+		case "/collections/wiki/exists":
+			w.Write([]byte(`{"result":{"exists":false}}`))
+			return
+		case "/collections/wiki/points":
+			body := struct {
+				Points []map[string]interface{} `json:"points"`
+			}{}
+			json.NewDecoder(r.Body).Decode(&body)
+			points = body.Points
+		}
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer server.Close()
+
+	store, err := core.NewVectorStore(core.VectorConfig{
+		Backend: core.VectorBackendQdrant, URL: server.URL, APIKey: "secret", Collection: "wiki",
+	})
+	assert.NoError(t, err)
+	defer store.Close()
+	chunks := []core.Chunk{{DocID: "doxcn", HeadingPath: []string{"标题"}, Text: "正文", URL: "https://sample.feishu.cn/docx/doxcn"}}
+	assert.NoError(t, store.Replace(context.Background(), "doxcn", chunks, [][]float32{{1, 0}}))
+	assert.NoError(t, store.Replace(context.Background(), "doxcn", chunks, [][]float32{{1, 0}}))
+
+	// The collection is created once, the old chunks are deleted first
+	assert.Equal(t, []string{
+		"GET /collections/wiki/exists",
+		"PUT /collections/wiki",
+		"POST /collections/wiki/points/delete",
+		"PUT /collections/wiki/points",
+		"POST /collections/wiki/points/delete",
+		"PUT /collections/wiki/points",
+	}, requests)
+	if assert.Len(t, points, 1) {
+		assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, points[0]["id"])
+		assert.Equal(t, map[string]interface{}{
+			"doc_id": "doxcn", "heading_path": []interface{}{"标题"}, "text": "正文", "url": "https://sample.feishu.cn/docx/doxcn",
+		}, points[0]["payload"])
+	}
+
+	_, err = core.NewVectorStore(core.VectorConfig{Backend: "chroma", URL: server.URL})
+	assert.Error(t, err)
+}
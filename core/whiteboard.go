@@ -0,0 +1,72 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/chyroc/lark"
+)
+
+// IsWhiteboard reports whether the object type of a wiki node is a whiteboard
+func IsWhiteboard(objType string) bool {
+	return objType == "board" || objType == "whiteboard"
+}
+
+// whiteboardImageResp receives the image of the whiteboard download API,
+// lark sets the reader and the filename of a file response
+type whiteboardImageResp struct {
+	Code     int64  `json:"code,omitempty"`
+	Msg      string `json:"msg,omitempty"`
+	File     io.Reader
+	Filename string
+}
+
+func (r *whiteboardImageResp) SetReader(file io.Reader) {
+	r.File = file
+}
+
+func (r *whiteboardImageResp) SetFilename(filename string) {
+	r.Filename = filename
+}
+
+// DownloadWhiteboard exports a whiteboard to a png image named after its title,
+// a placeholder markdown file is written when the export is not available
+func (c *Client) DownloadWhiteboard(ctx context.Context, token, outDir, objType, title string) (string, error) {
+	resp := new(whiteboardImageResp)
+	_, err := c.lark().RawRequest(ctx, &lark.RawRequestReq{
+		Scope:  "Board",
+		API:    "DownloadWhiteboardAsImage",
+		Method: http.MethodGet,
+		URL:    "https://open.feishu.cn/open-apis/board/v1/whiteboards/:whiteboard_id/download_as_image",
+		Body: &struct {
+			WhiteboardID string `path:"whiteboard_id" json:"-"`
+		}{WhiteboardID: token},
+		MethodOption:          &lark.MethodOption{},
+		NeedTenantAccessToken: true,
+	}, resp)
+	if err != nil || resp.File == nil {
+		if err != nil {
+			c.logf("Failed to export whiteboard %s: %v\n", title, err)
+		}
+		return c.createFilePlaceholder(ctx, token, outDir, objType, title)
+	}
+
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return "", err
+	}
+	imgPath := filepath.Join(outDir, title+".png")
+	f, err := os.Create(imgPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	written, err := io.Copy(f, resp.File)
+	if err != nil {
+		return "", err
+	}
+	c.stats.addDownloadedBytes(written)
+	return imgPath, nil
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/chyroc/lark"
+)
+
+// Error codes of the OPEN API that need a fix of the app settings
+const (
+	errCodeInvalidCredential = 10014
+	errCodeAppNotFound       = 10003
+	errCodeMissingScope      = 99991672
+	errCodeMissingUserScope  = 99991679
+	errCodeNoPermission      = 1770032
+	errCodeWikiForbidden     = 131006
+	errCodeSheetForbidden    = 91403
+	errCodeBitableForbidden  = 1254302
+	errCodeDriveForbidden    = 1061004
+)
+
+// IsPermissionError reports whether the error is returned by the OPEN API
+// because the app has no permission to read the document or the wiki node
+func IsPermissionError(err error) bool {
+	var larkErr *lark.Error
+	if !errors.As(err, &larkErr) {
+		return false
+	}
+	switch larkErr.Code {
+	case errCodeNoPermission, errCodeWikiForbidden, errCodeSheetForbidden, errCodeBitableForbidden, errCodeDriveForbidden:
+		return true
+	}
+	return false
+}
+
+// IsScopeError reports whether the error is returned by the OPEN API because
+// a scope of the app or of the user access token is not granted
+func IsScopeError(err error) bool {
+	code := wrappedErrorCode(err)
+	return code == errCodeMissingScope || code == errCodeMissingUserScope
+}
+
+// IsCredentialError reports whether the error is returned by the OPEN API
+// because the app_id or the app_secret is wrong
+func IsCredentialError(err error) bool {
+	code := wrappedErrorCode(err)
+	return code == errCodeInvalidCredential || code == errCodeAppNotFound
+}
+
+// wrappedErrorCode returns the code of the OPEN API error wrapped in err, 0
+// for the other errors
+func wrappedErrorCode(err error) int64 {
+	var larkErr *lark.Error
+	if errors.As(err, &larkErr) {
+		return larkErr.Code
+	}
+	return 0
+}
+
+// IsNetworkError reports whether the request failed to reach the OPEN API
+// or timed out, a response with an error code is not a network error
+func IsNetworkError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// Check is the result of a check of the credentials and the permissions
+type Check struct {
+	Name   string
+	OK     bool
+	Detail string
+	// Hint tells how to fix a failed check
+	Hint string
+}
+
+// CheckCredentials verifies that the app credentials are valid and the common
+// scopes are granted. The access to a document is checked as well if docToken
+// of a docx is given.
+func (c *Client) CheckCredentials(ctx context.Context, docToken string) []Check {
+	checks := make([]Check, 0)
+
+	token, _, err := c.larkClient.Auth.GetTenantAccessToken(ctx)
+	if err != nil {
+		hint := "Check the app_id and app_secret with `feishu2md config`"
+		switch lark.GetErrorCode(err) {
+		case errCodeInvalidCredential:
+			hint = "The app_secret is wrong, copy it again from the credentials page of the app"
+		case errCodeAppNotFound:
+			hint = "The app_id does not exist, copy it again from the credentials page of the app"
+		}
+		return append(checks, Check{Name: "tenant_access_token", Detail: err.Error(), Hint: hint})
+	}
+	checks = append(checks, Check{
+		Name:   "tenant_access_token",
+		OK:     true,
+		Detail: fmt.Sprintf("expires in %ds", token.Expire),
+	})
+
+	if bot, _, err := c.larkClient.Bot.GetBotInfo(ctx, &lark.GetBotInfoReq{}); err == nil {
+		checks = append(checks, Check{Name: "app", OK: true, Detail: bot.AppName})
+	}
+
+	_, _, err = c.larkClient.Drive.GetDriveRootFolderMeta(ctx, &lark.GetDriveRootFolderMetaReq{})
+	checks = append(checks, scopeCheck("drive", "drive:drive:readonly", err))
+
+	pageSize := int64(1)
+	_, _, err = c.larkClient.Drive.GetWikiSpaceList(ctx, &lark.GetWikiSpaceListReq{PageSize: &pageSize})
+	checks = append(checks, scopeCheck("wiki", "wiki:wiki:readonly", err))
+
+	if docToken != "" {
+		_, _, err = c.larkClient.Drive.GetDocxDocument(ctx, &lark.GetDocxDocumentReq{DocumentID: docToken})
+		checks = append(checks, scopeCheck("docx "+docToken, "docx:document:readonly", err))
+	}
+	return checks
+}
+
+// scopeCheck turns the error of an API call into a check of its scope
+func scopeCheck(name, scope string, err error) Check {
+	check := Check{Name: name, OK: err == nil, Detail: scope}
+	if err == nil {
+		return check
+	}
+	check.Detail = err.Error()
+	switch lark.GetErrorCode(err) {
+	case errCodeMissingScope, errCodeMissingUserScope:
+		check.Hint = fmt.Sprintf("Add the scope %s to the app and publish a new version of it", scope)
+	case errCodeNoPermission, errCodeWikiForbidden:
+		check.Hint = "Share the document or the wiki space with the app, or add the app as a collaborator"
+	}
+	return check
+}
@@ -0,0 +1,35 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/chyroc/lark"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPermissionError(t *testing.T) {
+	forbidden := &lark.Error{Code: 131006, Msg: "permission denied: wiki space permission denied"}
+	assert.True(t, core.IsPermissionError(forbidden))
+	assert.True(t, core.IsPermissionError(fmt.Errorf("GetDocxContent err: %w", forbidden)))
+	assert.False(t, core.IsPermissionError(&lark.Error{Code: 99991400, Msg: "request trigger frequency limit"}))
+	assert.False(t, core.IsPermissionError(errors.New("permission denied")))
+}
+
+func TestErrorKinds(t *testing.T) {
+	missingScope := fmt.Errorf("GetDriveFileList err: %w", &lark.Error{Code: 99991672, Msg: "Access denied"})
+	assert.True(t, core.IsScopeError(missingScope))
+	assert.False(t, core.IsCredentialError(missingScope))
+	assert.True(t, core.IsCredentialError(&lark.Error{Code: 10014, Msg: "app secret invalid"}))
+
+	refused := &url.Error{Op: "Get", URL: "https://open.feishu.cn", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}
+	assert.True(t, core.IsNetworkError(fmt.Errorf("failed to list the wiki spaces: %w", refused)))
+	assert.True(t, core.IsNetworkError(context.DeadlineExceeded))
+	assert.False(t, core.IsNetworkError(&lark.Error{Code: 99991400, Msg: "request trigger frequency limit"}))
+	assert.False(t, core.IsNetworkError(errors.New("connection refused")))
+}
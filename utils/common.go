@@ -9,6 +9,8 @@ import (
 
 var StopWhenErr = true
 
+// CheckErr prints and panics on the error, it is meant for tests and scripts.
+// The commands and the core package return their errors instead.
 func CheckErr(e error) error {
 	if e != nil {
 		fmt.Fprintln(os.Stderr, e)
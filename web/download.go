@@ -76,7 +76,7 @@ func downloadHandler(c *gin.Context) {
 			log.Panicf("error: %s", err)
 			return
 		}
-		markdown = strings.Replace(markdown, imgToken, localLink, 1)
+		markdown = strings.Replace(markdown, imgToken, config.Output.ImageLink(".", localLink), 1)
 		f, err := writer.Create(localLink)
 		if err != nil {
 			c.String(http.StatusInternalServerError, "Internal error: zipWriter.Create")